@@ -0,0 +1,127 @@
+// Package adopt brings pull requests opened outside jj-github, e.g. with
+// `gh pr create` or on the web, under management by matching them to local
+// revisions.
+package adopt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/journal"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// GitHub is the GitHub functionality used to find pull requests to adopt.
+type GitHub interface {
+	ListOpenPullRequests(ctx context.Context, repo github.Repo) ([]*gogithub.PullRequest, error)
+}
+
+// Repo is the jj functionality used to adopt pull requests.
+type Repo interface {
+	GetChanges(revsets ...string) ([]jj.Change, error)
+	GitFetchBranches(branches []string) error
+	TrackBookmarks(branches []string) error
+}
+
+// Match is a revision and the pull request opened for it.
+type Match struct {
+	Change   jj.Change
+	PR       *gogithub.PullRequest
+	ByCommit bool // The PR's head is the revision's commit, rather than one of its bookmarks
+}
+
+// Find matches the mutable revisions in revset to open pull requests whose
+// head is the revision's commit or one of its local bookmarks. Revisions that
+// are already managed, either through their push bookmark or an earlier
+// adoption recorded in branches, are skipped.
+func Find(ctx context.Context, gh GitHub, repo Repo, ghRepo github.Repo, revset string, branches journal.Branches) ([]Match, error) {
+	changes, err := repo.GetChanges(fmt.Sprintf("::(%s) & mutable() & ~empty()", revset))
+	if err != nil {
+		return nil, fmt.Errorf("get revisions: %w", err)
+	}
+
+	prs, err := gh.ListOpenPullRequests(ctx, ghRepo)
+	if err != nil {
+		return nil, fmt.Errorf("list pull requests: %w", err)
+	}
+
+	byCommit := make(map[string]*gogithub.PullRequest)
+	byBranch := make(map[string]*gogithub.PullRequest)
+	for _, pr := range prs {
+		if github.IsFork(pr, ghRepo) {
+			continue
+		}
+		byCommit[pr.GetHead().GetSHA()] = pr
+		byBranch[pr.GetHead().GetRef()] = pr
+	}
+
+	adopted := make(map[int]bool)
+	var matches []Match
+	for _, change := range changes {
+		if _, ok := branches[change.ID]; ok {
+			continue
+		}
+		if _, ok := byBranch[change.GitPushBookmark]; ok {
+			continue
+		}
+
+		match := Match{Change: change, PR: byCommit[change.CommitID], ByCommit: true}
+		if match.PR == nil {
+			match.ByCommit = false
+			for _, bookmark := range change.Bookmarks {
+				if pr, ok := byBranch[bookmark.Name]; ok && !strings.Contains(bookmark.Name, "@") {
+					match.PR = pr
+					break
+				}
+			}
+		}
+		if match.PR == nil || adopted[match.PR.GetNumber()] {
+			continue
+		}
+
+		adopted[match.PR.GetNumber()] = true
+		matches = append(matches, match)
+	}
+
+	return matches, nil
+}
+
+// Describe lists the matches for confirmation.
+func Describe(matches []Match) string {
+	var sb strings.Builder
+	for _, match := range matches {
+		how := "bookmark"
+		if match.ByCommit {
+			how = "same commit"
+		}
+		title, _, _ := strings.Cut(match.Change.Description, "\n")
+		fmt.Fprintf(&sb, "  %s %s\n    → #%d %s (branch %s, %s)\n",
+			match.Change.ShortID, title, match.PR.GetNumber(), match.PR.GetTitle(), match.PR.GetHead().GetRef(), how)
+	}
+	return sb.String()
+}
+
+// Adopt tracks the branches of the matched pull requests and records them in
+// branches, which is saved to stateDir. Submit then pushes each revision to
+// its pull request's branch.
+func Adopt(repo Repo, stateDir string, branches journal.Branches, matches []Match) error {
+	names := make([]string, len(matches))
+	for i, match := range matches {
+		names[i] = match.PR.GetHead().GetRef()
+	}
+
+	if err := repo.GitFetchBranches(names); err != nil {
+		return fmt.Errorf("fetch branches: %w", err)
+	}
+	if err := repo.TrackBookmarks(names); err != nil {
+		return fmt.Errorf("track bookmarks: %w", err)
+	}
+
+	for i, match := range matches {
+		branches[match.Change.ID] = names[i]
+	}
+	return branches.Save(stateDir)
+}
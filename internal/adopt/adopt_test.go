@@ -0,0 +1,83 @@
+package adopt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+func TestFind(t *testing.T) {
+	ctx := context.Background()
+
+	a := jjtest.Change("aaaaaaaa", "Fix login", "trunk")
+	b := jjtest.Change("bbbbbbbb", "Add logout", "aaaaaaaa")
+	b.Bookmarks = []jj.BookmarkRef{{Name: "logout"}}
+	c := jjtest.Change("cccccccc", "Managed", "bbbbbbbb")
+	d := jjtest.Change("dddddddd", "Adopted before", "cccccccc")
+	e := jjtest.Change("eeeeeeee", "No PR", "dddddddd")
+
+	repo, runner := jjtest.NewClient()
+	runner.On("log", "-r", "::(@) & mutable() & ~empty()").ReturnChanges(a, b, c, d, e)
+
+	gh := githubtest.NewFake()
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Login", Branch: "hand-named", Base: "main"}, "commit-aaaaaaaa")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Logout", Branch: "logout", Base: "hand-named"}, "stale")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Managed", Branch: "push-cccccccc", Base: "logout"}, "commit-cccccccc")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Before", Branch: "old", Base: "main"}, "commit-dddddddd")
+
+	matches, err := Find(ctx, gh, repo, testRepo, "@", journal.Branches{"dddddddd": "old"})
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+
+	assert.Equal(t, "aaaaaaaa", matches[0].Change.ID)
+	assert.Equal(t, 1, matches[0].PR.GetNumber())
+	assert.True(t, matches[0].ByCommit)
+
+	assert.Equal(t, "bbbbbbbb", matches[1].Change.ID)
+	assert.Equal(t, 2, matches[1].PR.GetNumber())
+	assert.False(t, matches[1].ByCommit)
+
+	desc := Describe(matches)
+	assert.Contains(t, desc, "aaa Fix login\n    → #1 Login (branch hand-named, same commit)")
+	assert.Contains(t, desc, "bbb Add logout\n    → #2 Logout (branch logout, bookmark)")
+}
+
+func TestAdopt(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	repo, runner := jjtest.NewClient()
+	runner.On("log").ReturnChanges(jjtest.Change("aaaaaaaa", "Fix login", "trunk"))
+	runner.On("git", "fetch")
+	runner.On("bookmark", "track")
+
+	gh := githubtest.NewFake()
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Login", Branch: "hand-named", Base: "main"}, "commit-aaaaaaaa")
+
+	branches, err := journal.LoadBranches(dir)
+	require.NoError(t, err)
+	matches, err := Find(ctx, gh, repo, testRepo, "@", branches)
+	require.NoError(t, err)
+	require.NoError(t, Adopt(repo, dir, branches, matches))
+
+	assert.Equal(t, 1, runner.Called("git", "fetch", "--branch", "hand-named"))
+	assert.Equal(t, 1, runner.Called("bookmark", "track", "hand-named@origin"))
+
+	loaded, err := journal.LoadBranches(dir)
+	require.NoError(t, err)
+	assert.Equal(t, journal.Branches{"aaaaaaaa": "hand-named"}, loaded)
+
+	// Once adopted, the revision isn't offered again.
+	matches, err = Find(ctx, gh, repo, testRepo, "@", loaded)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
@@ -0,0 +1,178 @@
+// Package browse finds the pull requests for revisions so they can be opened
+// in a web browser.
+package browse
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/journal"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// GitHub is the GitHub functionality used to look pull requests up.
+type GitHub interface {
+	LoadStackState(ctx context.Context, repo github.Repo, branches []string, marker string) (*github.StackState, error)
+}
+
+// Repo is the jj functionality used to find revisions.
+type Repo interface {
+	GetChanges(revsets ...string) ([]jj.Change, error)
+	GetTrunkName() (string, error)
+}
+
+// Target is a revision to open.
+type Target struct {
+	Change jj.Change
+	Branch string                // Branch the revision is pushed to
+	Base   string                // Branch a pull request for the revision merges into
+	PR     *gogithub.PullRequest // Nil if the revision has no pull request
+
+	Checks    *github.Checks // Checks on the pull request's head commit; nil without a pull request
+	Mergeable string         // MERGEABLE, CONFLICTING or UNKNOWN; empty without a pull request
+}
+
+// Resolve returns the revisions in revset with their pull requests, bottom
+// first. If revset only has empty revisions, such as a fresh working copy,
+// the closest revisions below it are used. With stack, every revision in the
+// stacks containing revset is returned.
+//
+// Branches are looked up the way submit does: the revision's push bookmark,
+// or the branch recorded when its pull request was adopted. The pull requests
+// are loaded with their checks and mergeability in one query.
+func Resolve(ctx context.Context, gh GitHub, repo Repo, ghRepo github.Repo, revset string, stack bool, branches journal.Branches) ([]Target, error) {
+	query := fmt.Sprintf("(%s) & mutable() & ~empty()", revset)
+	if stack {
+		query = fmt.Sprintf("(::(%s) | (%s)::) & mutable() & ~empty()", revset, revset)
+	}
+	targets, err := repo.GetChanges(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 && !stack {
+		targets, err = repo.GetChanges(fmt.Sprintf("heads(::(%s) & mutable() & ~empty())", revset))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no revisions with changes in %q", revset)
+	}
+
+	// Load the revisions below the targets too, to find their bases.
+	ids := make([]string, len(targets))
+	for i, change := range targets {
+		ids[i] = change.ID
+	}
+	set := strings.Join(ids, " | ")
+	changes, err := repo.GetChanges(fmt.Sprintf("(roots(::(%s) & mutable())- | ::(%s) & mutable()) & ~empty()", set, set))
+	if err != nil {
+		return nil, err
+	}
+
+	trunkName, err := repo.GetTrunkName()
+	if err != nil {
+		return nil, fmt.Errorf("get trunk name: %w", err)
+	}
+
+	byID := make(map[string]jj.Change, len(changes))
+	for _, change := range changes {
+		if branch, ok := branches[change.ID]; ok {
+			change.GitPushBookmark = branch
+		}
+		byID[change.ID] = change
+	}
+
+	result := make([]Target, len(targets))
+	names := make([]string, len(targets))
+	for i, change := range targets {
+		change = byID[change.ID]
+		result[i] = Target{Change: change, Branch: change.GitPushBookmark, Base: trunkName}
+		names[i] = change.GitPushBookmark
+
+		if len(change.Parents) == 0 {
+			continue
+		}
+		parent, ok := byID[change.Parents[0].ChangeID]
+		switch {
+		case !ok:
+		case !parent.Immutable:
+			result[i].Base = parent.GitPushBookmark
+		case len(parent.Bookmarks) > 0:
+			result[i].Base = parent.Bookmarks[0].Name
+		}
+	}
+
+	state, err := gh.LoadStackState(ctx, ghRepo, names, "")
+	if err != nil {
+		return nil, fmt.Errorf("get pull requests: %w", err)
+	}
+	for i := range result {
+		pr := state.PullRequests[result[i].Branch]
+		if pr == nil {
+			continue
+		}
+		result[i].PR = pr
+		result[i].Checks = state.Checks[pr.GetNumber()]
+		result[i].Mergeable = state.Mergeable[pr.GetNumber()]
+	}
+
+	return result, nil
+}
+
+// CompareURL returns the GitHub page for creating a pull request for target,
+// pre-filled from its description.
+func CompareURL(repo github.Repo, target Target) string {
+	title, body, _ := strings.Cut(target.Change.Description, "\n")
+
+	query := url.Values{}
+	query.Set("expand", "1")
+	query.Set("title", title)
+	if body = strings.TrimSpace(body); body != "" {
+		query.Set("body", body)
+	}
+
+	return fmt.Sprintf("https://github.com/%s/%s/compare/%s...%s?%s",
+		repo.Owner, repo.Name, url.PathEscape(target.Base), url.PathEscape(target.Branch), query.Encode())
+}
+
+// Browser returns the command that opens URLs: $BROWSER if it is set, or
+// the platform's opener, e.g. xdg-open. Returns false if none is available.
+func Browser() ([]string, bool) {
+	if browser := os.Getenv("BROWSER"); browser != "" {
+		// $BROWSER can be a list of browsers to try, separated by colons.
+		first, _, _ := strings.Cut(browser, ":")
+		command := strings.Fields(first)
+		if len(command) > 0 {
+			if _, err := exec.LookPath(command[0]); err == nil {
+				return command, true
+			}
+		}
+	}
+
+	opener := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+	if _, err := exec.LookPath(opener); err != nil {
+		return nil, false
+	}
+	return []string{opener}, true
+}
+
+// Open opens url with browser, as returned by Browser, without waiting for
+// the browser to exit.
+func Open(browser []string, url string) error {
+	cmd := exec.Command(browser[0], append(browser[1:], url)...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,76 @@
+package browse
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+
+	trunk := jjtest.Trunk("trunk", "main")
+	a := jjtest.Change("aaaaaaaa", "Add feature A", "trunk")
+	b := jjtest.Change("bbbbbbbb", "Add feature B\n\nDetails", "aaaaaaaa")
+
+	repo, runner := jjtest.NewClient()
+	runner.On("log", "-r", "trunk()").ReturnChanges(trunk)
+	runner.On("log", "-r", "(@) & mutable() & ~empty()")
+	runner.On("log", "-r", "heads(::(@) & mutable() & ~empty())").ReturnChanges(b)
+	runner.On("log", "-r", "(roots(::(bbbbbbbb) & mutable())- | ::(bbbbbbbb) & mutable()) & ~empty()").ReturnChanges(trunk, a, b)
+
+	gh := githubtest.NewFake()
+	gh.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "hand-named", Base: "main"}, "commit-aaaaaaaa")
+
+	// An empty working copy resolves to the revision below it.
+	targets, err := Resolve(ctx, gh, repo, testRepo, "@", false, journal.Branches{"aaaaaaaa": "hand-named"})
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	assert.Equal(t, "bbbbbbbb", targets[0].Change.ID)
+	assert.Equal(t, "push-bbbbbbbb", targets[0].Branch)
+	assert.Equal(t, "hand-named", targets[0].Base, "adopted branches are used")
+	assert.Nil(t, targets[0].PR)
+
+	assert.Equal(t,
+		"https://github.com/owner/repo/compare/hand-named...push-bbbbbbbb?body=Details&expand=1&title=Add+feature+B",
+		CompareURL(testRepo, targets[0]))
+
+	runner.On("log", "-r", "(::(@) | (@)::) & mutable() & ~empty()").ReturnChanges(a, b)
+	runner.On("log", "-r", "(roots(::(aaaaaaaa | bbbbbbbb) & mutable())- | ::(aaaaaaaa | bbbbbbbb) & mutable()) & ~empty()").ReturnChanges(trunk, a, b)
+
+	targets, err = Resolve(ctx, gh, repo, testRepo, "@", true, journal.Branches{"aaaaaaaa": "hand-named"})
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	assert.Equal(t, "main", targets[0].Base)
+	require.NotNil(t, targets[0].PR)
+	assert.Equal(t, 1, targets[0].PR.GetNumber())
+	assert.Nil(t, targets[1].PR)
+}
+
+func TestResolveNothing(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("log")
+
+	_, err := Resolve(context.Background(), githubtest.NewFake(), repo, testRepo, "@", false, nil)
+	assert.ErrorContains(t, err, `no revisions with changes in "@"`)
+}
+
+func TestBrowser(t *testing.T) {
+	t.Setenv("BROWSER", "true --new-window:firefox")
+	browser, ok := Browser()
+	require.True(t, ok)
+	assert.Equal(t, []string{"true", "--new-window"}, browser)
+
+	t.Setenv("BROWSER", "")
+	t.Setenv("PATH", t.TempDir())
+	_, ok = Browser()
+	assert.False(t, ok, "URLs are printed instead")
+}
@@ -0,0 +1,153 @@
+// Package checkout brings a stack of pull requests from GitHub into the local
+// repository, so that someone else's stack can be reviewed or built on.
+package checkout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// GitHub is the GitHub functionality used to find a stack.
+type GitHub interface {
+	GetPullRequest(ctx context.Context, repo github.Repo, number int) (*gogithub.PullRequest, error)
+	GetPullRequestsForBranches(ctx context.Context, repo github.Repo, branches []string) (map[string]*gogithub.PullRequest, error)
+	ListPullRequestsWithBase(ctx context.Context, repo github.Repo, base string) ([]*gogithub.PullRequest, error)
+}
+
+// Repo is the jj functionality used to check a stack out.
+type Repo interface {
+	GitFetchBranches(branches []string) error
+	TrackBookmarks(branches []string) error
+	GetChanges(revsets ...string) ([]jj.Change, error)
+}
+
+// FindStack returns the open pull requests in the same stack as pull request
+// number, bottom first. The stack is found by following each pull request's
+// base branch down to the first branch without an open pull request, usually
+// trunk, and by following the pull requests based on each branch up.
+// Pull requests from forks are left out, since their branches can't be
+// fetched from the repository.
+func FindStack(ctx context.Context, gh GitHub, repo github.Repo, number int) ([]*gogithub.PullRequest, error) {
+	pr, err := gh.GetPullRequest(ctx, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("get pull request #%d: %w", number, err)
+	}
+	if pr.GetState() != "open" {
+		return nil, fmt.Errorf("pull request #%d is %s", number, pr.GetState())
+	}
+	if github.IsFork(pr, repo) {
+		return nil, fmt.Errorf("pull request #%d is from a fork, so its branch can't be fetched from %s/%s", number, repo.Owner, repo.Name)
+	}
+
+	stack := []*gogithub.PullRequest{pr}
+	seen := map[string]bool{pr.GetHead().GetRef(): true}
+
+	// Follow the bases down.
+	base := pr.GetBase().GetRef()
+	for !seen[base] {
+		prs, err := gh.GetPullRequestsForBranches(ctx, repo, []string{base})
+		if err != nil {
+			return nil, fmt.Errorf("get pull request for %s: %w", base, err)
+		}
+		parent := prs[base]
+		if parent == nil || parent.GetState() != "open" {
+			break
+		}
+
+		seen[base] = true
+		stack = append([]*gogithub.PullRequest{parent}, stack...)
+		base = parent.GetBase().GetRef()
+	}
+
+	// Follow the pull requests based on each branch up.
+	heads := []string{pr.GetHead().GetRef()}
+	for len(heads) > 0 {
+		head := heads[0]
+		heads = heads[1:]
+
+		children, err := gh.ListPullRequestsWithBase(ctx, repo, head)
+		if err != nil {
+			return nil, fmt.Errorf("list pull requests based on %s: %w", head, err)
+		}
+		for _, child := range children {
+			ref := child.GetHead().GetRef()
+			if seen[ref] || github.IsFork(child, repo) {
+				continue
+			}
+			seen[ref] = true
+			stack = append(stack, child)
+			heads = append(heads, ref)
+		}
+	}
+
+	return stack, nil
+}
+
+// Branches returns the head branches of the pull requests in stack.
+func Branches(stack []*gogithub.PullRequest) []string {
+	branches := make([]string, len(stack))
+	for i, pr := range stack {
+		branches[i] = pr.GetHead().GetRef()
+	}
+	return branches
+}
+
+// Top returns the branch to start new work on: the top of the stack, or the
+// branch of pull request number if the stack splits into several.
+func Top(stack []*gogithub.PullRequest, number int) string {
+	bases := make(map[string]bool, len(stack))
+	for _, pr := range stack {
+		bases[pr.GetBase().GetRef()] = true
+	}
+
+	var tops []*gogithub.PullRequest
+	for _, pr := range stack {
+		if !bases[pr.GetHead().GetRef()] {
+			tops = append(tops, pr)
+		}
+	}
+	if len(tops) == 1 {
+		return tops[0].GetHead().GetRef()
+	}
+
+	for _, pr := range stack {
+		if pr.GetNumber() == number {
+			return pr.GetHead().GetRef()
+		}
+	}
+	return stack[len(stack)-1].GetHead().GetRef()
+}
+
+// Checkout fetches the branches of stack and tracks them as local bookmarks.
+// It returns the stack's mutable revisions in topological order.
+func Checkout(repo Repo, stack []*gogithub.PullRequest) ([]jj.Change, error) {
+	branches := Branches(stack)
+	if err := repo.GitFetchBranches(branches); err != nil {
+		return nil, fmt.Errorf("fetch branches: %w", err)
+	}
+	if err := repo.TrackBookmarks(branches); err != nil {
+		return nil, fmt.Errorf("track bookmarks: %w", err)
+	}
+
+	changes, err := repo.GetChanges(fmt.Sprintf("mutable() & ::(%s)", Revset(branches...)))
+	if err != nil {
+		return nil, fmt.Errorf("get stack: %w", err)
+	}
+	return changes, nil
+}
+
+// Revset returns a revset for the bookmarks named branches. Names are quoted,
+// since branch names can contain characters that are operators in revsets.
+func Revset(branches ...string) string {
+	quoted := make([]string, len(branches))
+	for i, branch := range branches {
+		quoted[i] = strconv.Quote(branch)
+	}
+	return strings.Join(quoted, " | ")
+}
@@ -0,0 +1,90 @@
+package checkout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+func numbers(stack []*gogithub.PullRequest) []int {
+	var result []int
+	for _, pr := range stack {
+		result = append(result, pr.GetNumber())
+	}
+	return result
+}
+
+func TestFindStack(t *testing.T) {
+	ctx := context.Background()
+	gh := githubtest.NewFake()
+	gh.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-b", Base: "push-a"}, "sha-b")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "C", Branch: "push-c", Base: "push-b"}, "sha-c")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Other", Branch: "push-o", Base: "main"}, "sha-o")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Abandoned", Branch: "push-x", Base: "push-c"}, "sha-x")
+	gh.ClosePullRequest(5, false)
+
+	for _, number := range []int{1, 2, 3} {
+		stack, err := FindStack(ctx, gh, testRepo, number)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2, 3}, numbers(stack), "from #%d", number)
+		assert.Equal(t, "push-c", Top(stack, number))
+	}
+
+	_, err := FindStack(ctx, gh, testRepo, 5)
+	assert.ErrorContains(t, err, "pull request #5 is closed")
+
+	_, err = FindStack(ctx, gh, testRepo, 99)
+	assert.ErrorContains(t, err, "get pull request #99")
+}
+
+func TestFindStackBranches(t *testing.T) {
+	ctx := context.Background()
+	gh := githubtest.NewFake()
+	gh.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-b", Base: "push-a"}, "sha-b")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "C", Branch: "push-c", Base: "push-a"}, "sha-c")
+
+	stack, err := FindStack(ctx, gh, testRepo, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, numbers(stack))
+
+	// With more than one top, new work goes on the requested pull request.
+	assert.Equal(t, "push-c", Top(stack, 3))
+	assert.Equal(t, "push-b", Top(stack, 2))
+}
+
+func TestCheckout(t *testing.T) {
+	ctx := context.Background()
+	gh := githubtest.NewFake()
+	gh.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "feature/b", Base: "push-a"}, "sha-b")
+	stack, err := FindStack(ctx, gh, testRepo, 2)
+	require.NoError(t, err)
+
+	repo, runner := jjtest.NewClient()
+	runner.On("git", "fetch")
+	runner.On("bookmark", "track")
+	runner.On("log", "-r", `mutable() & ::("push-a" | "feature/b")`).ReturnChanges(
+		jjtest.Change("aaaaaaaa", "A", "trunk"),
+		jjtest.Change("bbbbbbbb", "B", "aaaaaaaa"),
+	)
+
+	changes, err := Checkout(repo, stack)
+	require.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, 1, runner.Called("git", "fetch", "--branch", "push-a", "--branch", "feature/b"))
+	assert.Equal(t, 1, runner.Called("bookmark", "track", "push-a@origin", "feature/b@origin"))
+
+	runner.On("git", "fetch").Fail("Error: failed to connect to remote")
+	_, err = Checkout(repo, stack)
+	assert.ErrorContains(t, err, "fetch branches")
+}
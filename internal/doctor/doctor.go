@@ -0,0 +1,236 @@
+// Package doctor checks that jj-github's dependencies are installed and
+// configured, reporting everything that's wrong in one go.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/tui/components"
+)
+
+// Status is the outcome of a check.
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusWarn
+	StatusFail
+)
+
+// Check is the result of checking one dependency.
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+	Hint   string // How to fix a warning or failure
+}
+
+// Repo is the jj functionality checked by Run.
+type Repo interface {
+	CheckCompatibility() (jj.Compatibility, error)
+	GetTemplate(name string) (string, error)
+	GetRemote(name string) (string, error)
+	GetChanges(revsets ...string) ([]jj.Change, error)
+}
+
+// Auth reports how the GitHub client is authenticated.
+type Auth interface {
+	AuthStatus(ctx context.Context) (github.AuthStatus, error)
+}
+
+// Run checks jj, GitHub authentication and the repository configuration.
+// newAuth is called to create the GitHub client, so failing to get a token
+// is reported as a check rather than stopping the run.
+func Run(ctx context.Context, repo Repo, newAuth func() (Auth, error)) []Check {
+	return []Check{
+		checkJJ(repo),
+		checkPushBookmark(repo),
+		CheckAuth(ctx, newAuth),
+		checkRemote(repo),
+		checkTrunk(repo),
+	}
+}
+
+// Failed returns true if any check failed.
+func Failed(checks []Check) bool {
+	return slices.ContainsFunc(checks, func(c Check) bool {
+		return c.Status == StatusFail
+	})
+}
+
+func checkJJ(repo Repo) Check {
+	check := Check{Name: "jj"}
+
+	compat, err := repo.CheckCompatibility()
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		check.Hint = "Make sure jj is installed and on your PATH, and that this is a jj repository."
+		return check
+	}
+
+	check.Detail = compat.Version.String()
+	if err := compat.Err(); err != nil {
+		check.Status = StatusFail
+		check.Detail += ": " + strings.ReplaceAll(err.Error(), "\n", "; ")
+		check.Hint = fmt.Sprintf("Install jj %s or newer.", jj.MinVersion)
+	} else if warning := compat.Warning(); warning != "" {
+		check.Status = StatusWarn
+		check.Detail = warning
+	}
+	return check
+}
+
+func checkPushBookmark(repo Repo) Check {
+	check := Check{Name: "templates.git_push_bookmark"}
+
+	template, err := repo.GetTemplate("git_push_bookmark")
+	if err != nil && !errors.Is(err, jj.ErrNotSet) {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		check.Hint = jj.Hint(err)
+		return check
+	}
+	if err != nil {
+		check.Status = StatusWarn
+		check.Detail = "not set, using the default " + jj.DefaultGitPushBookmark
+		check.Hint = fmt.Sprintf("Run `jj config set --user templates.git_push_bookmark '%s'` to make the branch names explicit.", jj.DefaultGitPushBookmark)
+		return check
+	}
+
+	check.Detail = template
+	return check
+}
+
+// CheckAuth checks that a GitHub token can be found and has the scopes
+// jj-github needs.
+func CheckAuth(ctx context.Context, newAuth func() (Auth, error)) Check {
+	check := Check{Name: "GitHub auth"}
+
+	auth, err := newAuth()
+	if err == nil {
+		var status github.AuthStatus
+		status, err = auth.AuthStatus(ctx)
+		if err == nil {
+			return authCheck(check, status)
+		}
+	}
+
+	check.Status = StatusFail
+	check.Detail = err.Error()
+	check.Hint = "Set GH_TOKEN, run `gh auth login`, or store a github.com token with a git credential helper."
+	return check
+}
+
+// authCheck reports where the token came from and the scopes of a classic
+// token. Fine-grained tokens don't report scopes, so their permissions can't
+// be checked up front.
+func authCheck(check Check, status github.AuthStatus) Check {
+	check.Detail = "logged in as " + status.Login
+	if status.Source != "" {
+		check.Detail += " via " + status.Source
+	}
+	if len(status.Scopes) == 0 {
+		check.Detail += " (token scopes not reported)"
+		return check
+	}
+	check.Detail += fmt.Sprintf(" (scopes: %s)", strings.Join(status.Scopes, ", "))
+
+	missing := github.MissingScopes(status.Scopes)
+	if len(missing) == 0 {
+		return check
+	}
+	check.Status = StatusWarn
+	var reasons []string
+	for _, scope := range missing {
+		switch scope {
+		case "repo":
+			reasons = append(reasons, "without repo it can't push or open pull requests")
+		case "workflow":
+			reasons = append(reasons, "without workflow it can't push changes to .github/workflows")
+		}
+	}
+	check.Hint = fmt.Sprintf("The token is missing scopes, %s. Run `gh auth refresh -s %s`, or create a token with them.",
+		strings.Join(reasons, " and "), strings.Join(missing, ","))
+	return check
+}
+
+func checkRemote(repo Repo) Check {
+	check := Check{Name: "origin remote"}
+
+	remote, err := repo.GetRemote("origin")
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		check.Hint = "Add the GitHub repository as a remote with `jj git remote add origin <url>`."
+		return check
+	}
+
+	ghRepo, err := github.GetRepoFromRemote(remote)
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = fmt.Sprintf("%s: %v", remote, err)
+		check.Hint = "The origin remote must be a github.com SSH or HTTPS URL."
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("%s/%s", ghRepo.Owner, ghRepo.Name)
+	return check
+}
+
+func checkTrunk(repo Repo) Check {
+	check := Check{Name: "trunk()"}
+
+	changes, err := repo.GetChanges("trunk()")
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		check.Hint = jj.Hint(err)
+		return check
+	}
+
+	// trunk() falls back to root() when it can't find a trunk bookmark.
+	if len(changes) == 0 || len(changes[0].Bookmarks) == 0 {
+		check.Status = StatusWarn
+		check.Detail = "does not resolve to a bookmark"
+		check.Hint = `Run ` + "`jj git fetch`" + `, or point trunk() at your main branch with ` +
+			"`jj config set --repo 'revset-aliases.\"trunk()\"' main@origin`."
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("%s (%s)", changes[0].Bookmarks[0].Name, changes[0].ShortID)
+	return check
+}
+
+// Render formats checks as a list, one per line, with hints underneath.
+func Render(checks []Check) string {
+	width := 0
+	for _, check := range checks {
+		width = max(width, len(check.Name))
+	}
+
+	var sb strings.Builder
+	for _, check := range checks {
+		var mark string
+		switch check.Status {
+		case StatusOK:
+			mark = components.SuccessStyle.Render(components.GraphSuccess)
+		case StatusWarn:
+			mark = components.YellowStyle.Render("!")
+		case StatusFail:
+			mark = components.ErrorStyle.Render(components.GraphError)
+		}
+
+		fmt.Fprintf(&sb, "%s %-*s  %s\n", mark, width, check.Name, check.Detail)
+		if check.Hint != "" {
+			sb.WriteString("  " + components.MutedStyle.Render(check.Hint) + "\n")
+		}
+	}
+	return sb.String()
+}
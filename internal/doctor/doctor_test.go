@@ -0,0 +1,136 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepo returns a fake jj repository where every check passes.
+func newTestRepo() (*jj.Client, *jjtest.Runner) {
+	repo, runner := jjtest.NewClient()
+	runner.On("--version").Return("jj 0.33.0\n")
+	runner.On("log", "-T", "json(change_id)").Return(`"zzzzzzzz"`)
+	runner.On("rebase", "--help").Return("      --skip-emptied")
+	runner.On("git", "remote", "list").Return("origin git@github.com:owner/repo.git\n")
+	runner.On("log", "-r", "trunk()").ReturnChanges(jjtest.Trunk("trunkabc", "main"))
+	return repo, runner
+}
+
+func authWith(fake *githubtest.Fake) func() (Auth, error) {
+	return func() (Auth, error) { return fake, nil }
+}
+
+func statuses(checks []Check) map[string]Status {
+	result := make(map[string]Status)
+	for _, check := range checks {
+		result[check.Name] = check.Status
+	}
+	return result
+}
+
+func TestRunHealthy(t *testing.T) {
+	repo, _ := newTestRepo()
+
+	checks := Run(context.Background(), repo, authWith(githubtest.NewFake()))
+	require.Len(t, checks, 5)
+	assert.False(t, Failed(checks))
+	for _, check := range checks {
+		assert.Equal(t, StatusOK, check.Status, "%s: %s", check.Name, check.Detail)
+	}
+
+	out := Render(checks)
+	assert.Contains(t, out, "0.33.0")
+	assert.Contains(t, out, "logged in as octocat (scopes: repo, workflow, read:org)")
+	assert.Contains(t, out, "owner/repo")
+	assert.Contains(t, out, "main (tru)")
+	assert.Contains(t, out, jjtest.PushBookmarkTemplate)
+}
+
+func TestRunProblems(t *testing.T) {
+	repo, runner := newTestRepo()
+	runner.On("--version").Return("jj 0.20.0\n")
+	runner.On("config", "get", "templates.git_push_bookmark").Fail("Config error: Value not found")
+	runner.On("git", "remote", "list").Return("origin https://gitlab.com/owner/repo.git\n")
+	runner.On("log", "-r", "trunk()").ReturnChanges(jjtest.Change("zzzzzzzz", "", ""))
+
+	fake := githubtest.NewFake()
+	fake.SetAuth(github.AuthStatus{Login: "someone", Scopes: []string{"read:org"}})
+
+	checks := Run(context.Background(), repo, authWith(fake))
+	assert.True(t, Failed(checks))
+	assert.Equal(t, map[string]Status{
+		"jj":                          StatusFail,
+		"templates.git_push_bookmark": StatusWarn,
+		"GitHub auth":                 StatusWarn,
+		"origin remote":               StatusFail,
+		"trunk()":                     StatusWarn,
+	}, statuses(checks))
+
+	out := Render(checks)
+	assert.Contains(t, out, "older than the oldest supported version")
+	assert.Contains(t, out, "gh auth refresh -s repo")
+}
+
+func TestRunNoGitHubToken(t *testing.T) {
+	repo, _ := newTestRepo()
+
+	checks := Run(context.Background(), repo, func() (Auth, error) {
+		return nil, errors.New("gh auth token: exit status 1")
+	})
+	assert.True(t, Failed(checks))
+	assert.Equal(t, StatusFail, statuses(checks)["GitHub auth"])
+	assert.Contains(t, Render(checks), "gh auth login")
+}
+
+func TestCheckAuth(t *testing.T) {
+	for _, tc := range []struct {
+		Name   string
+		Auth   github.AuthStatus
+		Status Status
+		Detail string
+		Hint   string
+	}{
+		{
+			Name:   "all scopes",
+			Auth:   github.AuthStatus{Login: "octocat", Source: "GH_TOKEN", Scopes: []string{"repo", "workflow"}},
+			Detail: "logged in as octocat via GH_TOKEN (scopes: repo, workflow)",
+		},
+		{
+			Name:   "no workflow",
+			Auth:   github.AuthStatus{Login: "octocat", Source: "git credential", Scopes: []string{"repo"}},
+			Status: StatusWarn,
+			Detail: "logged in as octocat via git credential (scopes: repo)",
+			Hint:   "The token is missing scopes, without workflow it can't push changes to .github/workflows. Run `gh auth refresh -s workflow`, or create a token with them.",
+		},
+		{
+			Name:   "fine-grained",
+			Auth:   github.AuthStatus{Login: "octocat", Source: "GITHUB_TOKEN"},
+			Detail: "logged in as octocat via GITHUB_TOKEN (token scopes not reported)",
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			fake := githubtest.NewFake()
+			fake.SetAuth(tc.Auth)
+
+			check := CheckAuth(context.Background(), authWith(fake))
+			assert.Equal(t, Check{Name: "GitHub auth", Status: tc.Status, Detail: tc.Detail, Hint: tc.Hint}, check)
+		})
+	}
+}
+
+func TestPushBookmarkConfigError(t *testing.T) {
+	repo, runner := newTestRepo()
+	runner.On("config", "get", "templates.git_push_bookmark").Fail("Config error: Failed to parse file")
+
+	checks := Run(context.Background(), repo, authWith(githubtest.NewFake()))
+	assert.Equal(t, StatusFail, statuses(checks)["templates.git_push_bookmark"])
+	assert.Contains(t, Render(checks), "Failed to parse file")
+}
@@ -0,0 +1,288 @@
+// Package e2e runs submit and sync against a real jj repository, a bare Git
+// remote and a fake GitHub API. Tests are skipped if jj is not installed.
+package e2e
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/tui/submit"
+	"github.com/cbrewster/jj-github/internal/tui/sync"
+	"github.com/cbrewster/jj-github/internal/tui/tuitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stackCommentMarker = "<!-- managed-by: jj-github -->"
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+// env is a jj workspace cloned from a bare remote, plus a plain Git clone of
+// the same remote used to simulate changes landing on main.
+type env struct {
+	t      *testing.T
+	remote string
+	seed   string
+	work   string
+	repo   *jj.Client
+	server *githubtest.Server
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+
+	if _, err := exec.LookPath("jj"); err != nil {
+		t.Skip("jj not found on PATH")
+	}
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "jj-config.toml")
+	require.NoError(t, os.WriteFile(config, []byte(`
+[user]
+name = "Test User"
+email = "test@example.com"
+`), 0o644))
+	t.Setenv("JJ_CONFIG", config)
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test User")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test User")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	e := &env{
+		t:      t,
+		remote: filepath.Join(dir, "remote.git"),
+		seed:   filepath.Join(dir, "seed"),
+		work:   filepath.Join(dir, "work"),
+	}
+
+	e.git(dir, "init", "--bare", "--initial-branch=main", e.remote)
+	e.git(dir, "clone", e.remote, e.seed)
+	e.commitToMain("README.md", "# repo\n", "Initial commit")
+
+	e.jj(dir, "git", "clone", e.remote, e.work)
+	e.repo = jj.NewClient(jj.ExecRunner{Dir: e.work})
+
+	e.server = githubtest.NewServer(t, testRepo)
+	e.server.HeadSHA = e.branchSHA
+
+	return e
+}
+
+// git runs git in dir and returns its trimmed output.
+func (e *env) git(dir string, args ...string) string {
+	e.t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(e.t, err, "git %s: %s", strings.Join(args, " "), out)
+	return strings.TrimSpace(string(out))
+}
+
+// jj runs jj in dir and returns its trimmed stdout.
+func (e *env) jj(dir string, args ...string) string {
+	e.t.Helper()
+
+	stdout, stderr, err := jj.ExecRunner{Dir: dir}.Run(args...)
+	require.NoError(e.t, err, "jj %s: %s", strings.Join(args, " "), stderr)
+	return strings.TrimSpace(string(stdout))
+}
+
+// write writes a file in the jj workspace.
+func (e *env) write(name, contents string) {
+	e.t.Helper()
+	require.NoError(e.t, os.WriteFile(filepath.Join(e.work, name), []byte(contents), 0o644))
+}
+
+// commitToMain commits a file on main in the seed clone and pushes it.
+func (e *env) commitToMain(name, contents, message string) {
+	e.t.Helper()
+
+	require.NoError(e.t, os.WriteFile(filepath.Join(e.seed, name), []byte(contents), 0o644))
+	e.git(e.seed, "add", name)
+	e.git(e.seed, "commit", "-m", message)
+	e.git(e.seed, "push", "origin", "HEAD:main")
+}
+
+// squashMergeToMain lands branch on main as a single squashed commit, the way
+// GitHub's squash merge does.
+func (e *env) squashMergeToMain(branch, message string) {
+	e.t.Helper()
+
+	e.git(e.seed, "pull", "--ff-only", "origin", "main")
+	e.git(e.seed, "fetch", "origin", branch)
+	e.git(e.seed, "merge", "--squash", "FETCH_HEAD")
+	e.git(e.seed, "commit", "-m", message)
+	e.git(e.seed, "push", "origin", "HEAD:main")
+}
+
+// branchSHA returns the commit branch points at on the remote, or "" if it doesn't exist.
+func (e *env) branchSHA(branch string) string {
+	cmd := exec.Command("git", "--git-dir", e.remote, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// change returns the single change matching revset.
+func (e *env) change(revset string) jj.Change {
+	e.t.Helper()
+
+	changes, err := e.repo.GetChanges(revset)
+	require.NoError(e.t, err)
+	require.Len(e.t, changes, 1, "revset %q", revset)
+	return changes[0]
+}
+
+// submit runs submit for revset headlessly, confirming if asked, and returns the final view.
+func (e *env) submit(revset string) string {
+	e.t.Helper()
+
+	model := submit.NewModel(context.Background(), e.repo, e.server.Client(e.t), testRepo, revset, submit.Options{})
+	d := tuitest.New(e.t, model).Init()
+	if !d.Quit() {
+		d.Key("enter")
+	}
+	require.True(e.t, d.Quit(), "submit did not finish:\n%s", d.View())
+	return d.View()
+}
+
+// sync runs sync headlessly and returns the final view.
+func (e *env) sync() string {
+	e.t.Helper()
+
+	d := tuitest.New(e.t, sync.NewModel(context.Background(), e.repo, sync.Options{})).Init()
+	require.True(e.t, d.Quit(), "sync did not finish:\n%s", d.View())
+	return d.View()
+}
+
+// makeStack creates main <- A <- B with an empty working copy on top.
+func (e *env) makeStack() (a, b jj.Change) {
+	e.t.Helper()
+
+	e.write("a.txt", "a\n")
+	e.jj(e.work, "describe", "-m", "Add A\n\nBody of A")
+	e.jj(e.work, "new", "-m", "Add B")
+	e.write("b.txt", "b\n")
+	e.jj(e.work, "new")
+
+	return e.change("@--"), e.change("@-")
+}
+
+func TestSubmitStack(t *testing.T) {
+	e := newEnv(t)
+	a, b := e.makeStack()
+
+	view := e.submit("@")
+	assert.Contains(t, view, "2 pull request(s) synced successfully.")
+
+	prs := e.server.PullRequests()
+	require.Len(t, prs, 2)
+
+	prA, prB := prs[0], prs[1]
+	assert.Equal(t, "Add A", prA.GetTitle())
+	assert.Equal(t, "Body of A", strings.TrimSpace(prA.GetBody()))
+	assert.Equal(t, a.GitPushBookmark, prA.GetHead().GetRef())
+	assert.Equal(t, "main", prA.GetBase().GetRef())
+
+	assert.Equal(t, "Add B", prB.GetTitle())
+	assert.Equal(t, b.GitPushBookmark, prB.GetHead().GetRef())
+	assert.Equal(t, a.GitPushBookmark, prB.GetBase().GetRef())
+
+	// Both branches were pushed at the revisions' commits.
+	assert.Equal(t, a.CommitID, e.branchSHA(a.GitPushBookmark))
+	assert.Equal(t, b.CommitID, e.branchSHA(b.GitPushBookmark))
+
+	for _, pr := range prs {
+		comments := e.server.Comments(pr.GetNumber())
+		require.Len(t, comments, 1)
+		body := comments[0].GetBody()
+		assert.Contains(t, body, stackCommentMarker)
+		assert.Contains(t, body, "#1")
+		assert.Contains(t, body, "#2")
+	}
+
+	// Nothing changed, so a second submit is a no-op.
+	assert.Contains(t, e.submit("@"), "All PRs are up to date!")
+	assert.Len(t, e.server.PullRequests(), 2)
+}
+
+func TestSubmitUpdatesStack(t *testing.T) {
+	e := newEnv(t)
+	a, b := e.makeStack()
+	e.submit("@")
+
+	// Rewording A rewrites B too, so both branches are pushed again.
+	e.jj(e.work, "describe", "-r", a.ID, "-m", "Add A, reworded")
+	view := e.submit("@")
+	assert.Contains(t, view, "synced successfully")
+
+	prs := e.server.PullRequests()
+	require.Len(t, prs, 2, "existing PRs are updated rather than recreated")
+	assert.Equal(t, "Add A, reworded", prs[0].GetTitle())
+	assert.Equal(t, "", strings.TrimSpace(prs[0].GetBody()))
+	assert.Equal(t, e.change(b.ID).CommitID, e.branchSHA(b.GitPushBookmark))
+
+	// The stack comment is edited in place.
+	assert.Len(t, e.server.Comments(prs[0].GetNumber()), 1)
+}
+
+func TestSyncRebasesOntoMain(t *testing.T) {
+	e := newEnv(t)
+	a, _ := e.makeStack()
+	e.submit("@")
+
+	e.commitToMain("other.txt", "other\n", "Unrelated change")
+
+	view := e.sync()
+	assert.Contains(t, view, "1 stack(s) rebased successfully.")
+
+	trunk := e.change("trunk()")
+	rebased := e.change(a.ID)
+	require.Len(t, rebased.Parents, 1)
+	assert.Equal(t, trunk.CommitID, rebased.Parents[0].CommitID)
+
+	// The rebased stack is pushed and the PRs follow it.
+	e.submit("@")
+	assert.Equal(t, rebased.CommitID, e.branchSHA(a.GitPushBookmark))
+}
+
+func TestSyncAfterSquashMerge(t *testing.T) {
+	e := newEnv(t)
+	a, b := e.makeStack()
+	e.submit("@")
+
+	e.squashMergeToMain(a.GitPushBookmark, "Add A (#1)")
+	e.server.ClosePullRequest(1, true)
+
+	assert.NotContains(t, e.sync(), "Sync failed")
+
+	// A became empty once rebased onto main and was abandoned, leaving B on top of trunk.
+	trunk := e.change("trunk()")
+	rebased := e.change(b.ID)
+	require.Len(t, rebased.Parents, 1)
+	assert.Equal(t, trunk.CommitID, rebased.Parents[0].CommitID)
+
+	changes, err := e.repo.GetChanges("mutable()")
+	require.NoError(t, err)
+	for _, change := range changes {
+		assert.NotEqual(t, a.ID, change.ID, "A should have been abandoned")
+	}
+
+	// Submitting again retargets B's PR at main.
+	e.submit("@")
+	prs := e.server.PullRequests()
+	require.Len(t, prs, 2)
+	assert.Equal(t, "main", prs[1].GetBase().GetRef())
+}
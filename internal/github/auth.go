@@ -0,0 +1,190 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// host is the GitHub host tokens are looked up for.
+const host = "github.com"
+
+// RequiredScopes are the classic token scopes jj-github needs: repo to push
+// and open pull requests, and workflow to push commits that change GitHub
+// Actions workflows.
+var RequiredScopes = []string{"repo", "workflow"}
+
+// MissingScopes returns the RequiredScopes that scopes lacks. public_repo is
+// enough in place of repo for public repositories. Returns nil if scopes is
+// empty, since fine-grained and app tokens don't report scopes.
+func MissingScopes(scopes []string) []string {
+	if len(scopes) == 0 {
+		return nil
+	}
+
+	var missing []string
+	for _, scope := range RequiredScopes {
+		if slices.Contains(scopes, scope) || (scope == "repo" && slices.Contains(scopes, "public_repo")) {
+			continue
+		}
+		missing = append(missing, scope)
+	}
+	return missing
+}
+
+// Token is a GitHub auth token and the source it was found in.
+type Token struct {
+	Value  string
+	Source string
+}
+
+// TokenSource is a place to look for a token. Lookup returns "" if the source
+// has no token, and an error only if looking failed.
+type TokenSource struct {
+	Name   string
+	Lookup func() (string, error)
+}
+
+// DefaultTokenSources returns the sources NewClient tries, in order: the
+// GH_TOKEN and GITHUB_TOKEN environment variables, gh's hosts.yml, git's
+// credential helpers and finally the gh CLI, which also reads tokens gh keeps
+// in the system keyring.
+func DefaultTokenSources() []TokenSource {
+	return []TokenSource{
+		EnvTokenSource("GH_TOKEN"),
+		EnvTokenSource("GITHUB_TOKEN"),
+		HostsFileTokenSource(ghHostsPath()),
+		GitCredentialTokenSource(),
+		GHTokenSource(),
+	}
+}
+
+// FindToken returns the token from the first source that has one.
+func FindToken(sources []TokenSource) (Token, error) {
+	var names []string
+	var errs []string
+	for _, source := range sources {
+		token, err := source.Lookup()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", source.Name, err))
+			continue
+		}
+		if token != "" {
+			return Token{Value: token, Source: source.Name}, nil
+		}
+		names = append(names, source.Name)
+	}
+
+	msg := "no GitHub token found"
+	if len(names) > 0 {
+		msg += " in " + strings.Join(names, ", ")
+	}
+	if len(errs) > 0 {
+		msg += " (" + strings.Join(errs, "; ") + ")"
+	}
+	return Token{}, errors.New(msg)
+}
+
+// EnvTokenSource reads a token from the environment variable name.
+func EnvTokenSource(name string) TokenSource {
+	return TokenSource{
+		Name: name,
+		Lookup: func() (string, error) {
+			return strings.TrimSpace(os.Getenv(name)), nil
+		},
+	}
+}
+
+// HostsFileTokenSource reads the github.com token from gh's hosts.yml at
+// path. Recent versions of gh keep the token in the system keyring instead,
+// in which case the file has none.
+func HostsFileTokenSource(path string) TokenSource {
+	return TokenSource{
+		Name: path,
+		Lookup: func() (string, error) {
+			data, err := os.ReadFile(path)
+			if errors.Is(err, os.ErrNotExist) {
+				return "", nil
+			}
+			if err != nil {
+				return "", err
+			}
+
+			var hosts map[string]struct {
+				OAuthToken string `yaml:"oauth_token"`
+			}
+			if err := yaml.Unmarshal(data, &hosts); err != nil {
+				return "", fmt.Errorf("parse: %w", err)
+			}
+			return hosts[host].OAuthToken, nil
+		},
+	}
+}
+
+// ghHostsPath returns where gh keeps hosts.yml, following gh's own lookup.
+func ghHostsPath() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml")
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml")
+	}
+	if dir := os.Getenv("AppData"); runtime.GOOS == "windows" && dir != "" {
+		return filepath.Join(dir, "GitHub CLI", "hosts.yml")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".config", "gh", "hosts.yml")
+	}
+	return filepath.Join(home, ".config", "gh", "hosts.yml")
+}
+
+// GitCredentialTokenSource asks git's credential helpers for a github.com
+// password, which is the token for helpers such as Git Credential Manager.
+// git is told not to prompt, so a missing credential is reported as no token.
+func GitCredentialTokenSource() TokenSource {
+	return TokenSource{
+		Name: "git credential",
+		Lookup: func() (string, error) {
+			cmd := exec.Command("git", "credential", "fill")
+			cmd.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\n\n")
+			cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+			out, err := cmd.Output()
+			if err != nil {
+				return "", nil
+			}
+			return parseCredential(string(out)), nil
+		},
+	}
+}
+
+// parseCredential returns the password from `git credential fill` output.
+func parseCredential(out string) string {
+	for line := range strings.SplitSeq(out, "\n") {
+		if password, ok := strings.CutPrefix(line, "password="); ok {
+			return strings.TrimSpace(password)
+		}
+	}
+	return ""
+}
+
+// GHTokenSource gets a token from the gh CLI.
+func GHTokenSource() TokenSource {
+	return TokenSource{
+		Name: "gh auth token",
+		Lookup: func() (string, error) {
+			out, err := exec.Command("gh", "auth", "token").Output()
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimSpace(string(out)), nil
+		},
+	}
+}
@@ -0,0 +1,157 @@
+package github
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func staticSource(name, token string, err error) TokenSource {
+	return TokenSource{Name: name, Lookup: func() (string, error) { return token, err }}
+}
+
+func TestFindToken(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Sources  []TokenSource
+		Expected Token
+		Err      string
+	}{
+		{
+			Name: "first with a token wins",
+			Sources: []TokenSource{
+				staticSource("GH_TOKEN", "", nil),
+				staticSource("GITHUB_TOKEN", "env-token", nil),
+				staticSource("gh auth token", "gh-token", nil),
+			},
+			Expected: Token{Value: "env-token", Source: "GITHUB_TOKEN"},
+		},
+		{
+			Name: "errors are skipped",
+			Sources: []TokenSource{
+				staticSource("hosts.yml", "", errors.New("permission denied")),
+				staticSource("gh auth token", "gh-token", nil),
+			},
+			Expected: Token{Value: "gh-token", Source: "gh auth token"},
+		},
+		{
+			Name: "none",
+			Sources: []TokenSource{
+				staticSource("GH_TOKEN", "", nil),
+				staticSource("git credential", "", nil),
+				staticSource("gh auth token", "", errors.New(`exec: "gh": executable file not found in $PATH`)),
+			},
+			Err: `no GitHub token found in GH_TOKEN, git credential (gh auth token: exec: "gh": executable file not found in $PATH)`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			token, err := FindToken(tc.Sources)
+			if tc.Err != "" {
+				assert.EqualError(t, err, tc.Err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, token)
+		})
+	}
+}
+
+func TestEnvTokenSource(t *testing.T) {
+	t.Setenv("JJ_GITHUB_TEST_TOKEN", " token\n")
+	token, err := EnvTokenSource("JJ_GITHUB_TEST_TOKEN").Lookup()
+	require.NoError(t, err)
+	assert.Equal(t, "token", token)
+}
+
+func TestHostsFileTokenSource(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Contents string
+		Expected string
+		Err      string
+	}{
+		{
+			Name: "token",
+			Contents: `github.com:
+    users:
+        octocat:
+            oauth_token: gho_users
+    git_protocol: ssh
+    user: octocat
+    oauth_token: gho_token
+`,
+			Expected: "gho_token",
+		},
+		{
+			Name: "keyring",
+			Contents: `github.com:
+    git_protocol: https
+    user: octocat
+`,
+		},
+		{
+			Name: "other host",
+			Contents: `github.example.com:
+    oauth_token: gho_token
+`,
+		},
+		{
+			Name:     "invalid",
+			Contents: "github.com: [",
+			Err:      "parse: yaml: line 1: did not find expected node content",
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hosts.yml")
+			require.NoError(t, os.WriteFile(path, []byte(tc.Contents), 0o600))
+
+			token, err := HostsFileTokenSource(path).Lookup()
+			if tc.Err != "" {
+				assert.EqualError(t, err, tc.Err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, token)
+		})
+	}
+
+	token, err := HostsFileTokenSource(filepath.Join(t.TempDir(), "missing.yml")).Lookup()
+	require.NoError(t, err)
+	assert.Empty(t, token)
+}
+
+func TestGHHostsPath(t *testing.T) {
+	t.Setenv("GH_CONFIG_DIR", "/gh")
+	assert.Equal(t, filepath.Join("/gh", "hosts.yml"), ghHostsPath())
+
+	t.Setenv("GH_CONFIG_DIR", "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	assert.Equal(t, filepath.Join("/xdg", "gh", "hosts.yml"), ghHostsPath())
+}
+
+func TestParseCredential(t *testing.T) {
+	assert.Equal(t, "gho_token", parseCredential("protocol=https\nhost=github.com\nusername=octocat\npassword=gho_token\n"))
+	assert.Empty(t, parseCredential("protocol=https\nhost=github.com\n"))
+}
+
+func TestMissingScopes(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Scopes   []string
+		Expected []string
+	}{
+		{Name: "all", Scopes: []string{"repo", "workflow", "read:org"}},
+		{Name: "public_repo", Scopes: []string{"public_repo", "workflow"}},
+		{Name: "no workflow", Scopes: []string{"repo"}, Expected: []string{"workflow"}},
+		{Name: "neither", Scopes: []string{"read:org"}, Expected: []string{"repo", "workflow"}},
+		{Name: "not reported"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, MissingScopes(tc.Scopes))
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package github
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/v80/github"
+)
+
+// MergeMethods are the merge methods GitHub supports.
+var MergeMethods = []string{"merge", "squash", "rebase"}
+
+// AutoMergeMethod returns the merge method pr will be merged with once its
+// requirements are met, or "" if auto-merge is off.
+func AutoMergeMethod(pr *github.PullRequest) string {
+	return strings.ToLower(pr.GetAutoMerge().GetMergeMethod())
+}
+
+// EnableAutoMerge turns on auto-merge for pr, so GitHub merges it with
+// method, one of MergeMethods, once its reviews and checks pass. On branches
+// with a merge queue, the PR is added to the queue instead.
+func (c *Client) EnableAutoMerge(ctx context.Context, repo Repo, pr *github.PullRequest, method string) error {
+	const mutation = `mutation EnableAutoMerge($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) { clientMutationId }
+}`
+	var data struct{}
+	return c.graphQL(ctx, "EnableAutoMerge", mutation, map[string]any{
+		"id":     pr.GetNodeID(),
+		"method": strings.ToUpper(method),
+	}, &data)
+}
+
+// DisableAutoMerge turns off auto-merge for pr.
+func (c *Client) DisableAutoMerge(ctx context.Context, repo Repo, pr *github.PullRequest) error {
+	const mutation = `mutation DisableAutoMerge($id: ID!) {
+  disablePullRequestAutoMerge(input: {pullRequestId: $id}) { clientMutationId }
+}`
+	var data struct{}
+	return c.graphQL(ctx, "DisableAutoMerge", mutation, map[string]any{"id": pr.GetNodeID()}, &data)
+}
@@ -0,0 +1,188 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// checkContextPageSize is the number of checks fetched per PR.
+const checkContextPageSize = 100
+
+// CheckStatus is the status of a CI check, or the combined status of all
+// checks on a commit.
+type CheckStatus int
+
+const (
+	CheckNone CheckStatus = iota // No checks have been reported
+	CheckPending
+	CheckSuccess
+	CheckFailure
+	CheckSkipped
+)
+
+// Check is a single check run or commit status.
+type Check struct {
+	Name   string
+	Status CheckStatus
+}
+
+// Checks are the checks on a pull request's head commit.
+type Checks struct {
+	// Status combines the checks: pending until every check finishes, then
+	// failure if any failed, skipped if all were skipped, and success otherwise.
+	Status CheckStatus
+	Runs   []Check
+}
+
+// NewChecks combines the status of runs.
+func NewChecks(runs []Check) *Checks {
+	checks := &Checks{Runs: runs}
+	if len(runs) == 0 {
+		return checks
+	}
+
+	checks.Status = CheckSkipped
+	for _, run := range runs {
+		switch {
+		case run.Status == CheckPending:
+			checks.Status = CheckPending
+		case run.Status == CheckFailure && checks.Status != CheckPending:
+			checks.Status = CheckFailure
+		case run.Status == CheckSuccess && checks.Status == CheckSkipped:
+			checks.Status = CheckSuccess
+		}
+	}
+	return checks
+}
+
+// Failed returns the names of the failed checks.
+func (c *Checks) Failed() []string {
+	var names []string
+	for _, run := range c.Runs {
+		if run.Status == CheckFailure {
+			names = append(names, run.Name)
+		}
+	}
+	return names
+}
+
+// gqlCheckContext is a CheckRun or StatusContext in a status check rollup.
+type gqlCheckContext struct {
+	Typename string `json:"__typename"`
+	// CheckRun fields
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	// StatusContext fields
+	Context string `json:"context"`
+	State   string `json:"state"`
+}
+
+// toCheck converts a check run's conclusion or a commit status's state.
+func (c gqlCheckContext) toCheck() Check {
+	if c.Typename == "StatusContext" {
+		check := Check{Name: c.Context}
+		switch c.State {
+		case "SUCCESS":
+			check.Status = CheckSuccess
+		case "FAILURE", "ERROR":
+			check.Status = CheckFailure
+		default:
+			check.Status = CheckPending
+		}
+		return check
+	}
+
+	check := Check{Name: c.Name}
+	switch {
+	case c.Status != "COMPLETED":
+		check.Status = CheckPending
+	case c.Conclusion == "SUCCESS" || c.Conclusion == "NEUTRAL":
+		check.Status = CheckSuccess
+	case c.Conclusion == "SKIPPED":
+		check.Status = CheckSkipped
+	default:
+		check.Status = CheckFailure
+	}
+	return check
+}
+
+// headCommitChecksFields selects the checks on a pull request's head commit,
+// formatted with the number of checks to fetch.
+const headCommitChecksFields = `commits(last: 1) { nodes { commit { statusCheckRollup { contexts(first: %d) { nodes {
+      __typename
+      ... on CheckRun { name status conclusion }
+      ... on StatusContext { context state }
+    } } } } } }`
+
+// gqlHeadCommit is the head commit of a pull request, selected by
+// headCommitChecksFields.
+type gqlHeadCommit struct {
+	Nodes []struct {
+		Commit struct {
+			StatusCheckRollup *struct {
+				Contexts struct {
+					Nodes []gqlCheckContext `json:"nodes"`
+				} `json:"contexts"`
+			} `json:"statusCheckRollup"`
+		} `json:"commit"`
+	} `json:"nodes"`
+}
+
+// checks converts the checks on the head commit.
+func (c gqlHeadCommit) checks() *Checks {
+	var runs []Check
+	for _, commit := range c.Nodes {
+		if commit.Commit.StatusCheckRollup == nil {
+			continue
+		}
+		for _, check := range commit.Commit.StatusCheckRollup.Contexts.Nodes {
+			runs = append(runs, check.toCheck())
+		}
+	}
+	return NewChecks(runs)
+}
+
+// LoadChecks loads the checks on the head commit of the given pull requests,
+// keyed by PR number. PRs are looked up in batches using a single GraphQL
+// query per batch. Only the first 100 checks of each PR are considered.
+func (c *Client) LoadChecks(ctx context.Context, repo Repo, numbers []int) (map[int]*Checks, error) {
+	result := make(map[int]*Checks, len(numbers))
+
+	for batch := range slices.Chunk(numbers, stackBatchSize) {
+		var query strings.Builder
+		query.WriteString("query Checks($owner: String!, $name: String!")
+		variables := map[string]any{
+			"owner": repo.Owner,
+			"name":  repo.Name,
+		}
+		for i, number := range batch {
+			fmt.Fprintf(&query, ", $n%d: Int!", i)
+			variables[fmt.Sprintf("n%d", i)] = number
+		}
+		query.WriteString(") {\n  repository(owner: $owner, name: $name) {\n")
+		for i := range batch {
+			fmt.Fprintf(&query, "    p%d: pullRequest(number: $n%d) { "+headCommitChecksFields+" }\n", i, i, checkContextPageSize)
+		}
+		query.WriteString("  }\n}\n")
+
+		var data struct {
+			Repository map[string]*struct {
+				Commits gqlHeadCommit `json:"commits"`
+			} `json:"repository"`
+		}
+		if err := c.graphQL(ctx, "Checks", query.String(), variables, &data); err != nil {
+			return nil, err
+		}
+
+		for i, number := range batch {
+			if pr := data.Repository[fmt.Sprintf("p%d", i)]; pr != nil {
+				result[number] = pr.Commits.checks()
+			}
+		}
+	}
+
+	return result, nil
+}
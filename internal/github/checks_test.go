@@ -0,0 +1,44 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewChecks(t *testing.T) {
+	for _, tc := range []struct {
+		Name   string
+		Runs   []Check
+		Status CheckStatus
+	}{
+		{Name: "none", Status: CheckNone},
+		{Name: "success", Runs: []Check{{"build", CheckSuccess}, {"lint", CheckSkipped}}, Status: CheckSuccess},
+		{Name: "skipped", Runs: []Check{{"deploy", CheckSkipped}}, Status: CheckSkipped},
+		{Name: "failure", Runs: []Check{{"build", CheckFailure}, {"lint", CheckSuccess}}, Status: CheckFailure},
+		{Name: "pending until finished", Runs: []Check{{"build", CheckFailure}, {"test", CheckPending}}, Status: CheckPending},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Status, NewChecks(tc.Runs).Status)
+		})
+	}
+
+	checks := NewChecks([]Check{{"build", CheckFailure}, {"lint", CheckSuccess}, {"test", CheckFailure}})
+	assert.Equal(t, []string{"build", "test"}, checks.Failed())
+}
+
+func TestCheckContextToCheck(t *testing.T) {
+	for _, tc := range []struct {
+		Context gqlCheckContext
+		Want    Check
+	}{
+		{gqlCheckContext{Typename: "CheckRun", Name: "build", Status: "QUEUED"}, Check{"build", CheckPending}},
+		{gqlCheckContext{Typename: "CheckRun", Name: "build", Status: "COMPLETED", Conclusion: "NEUTRAL"}, Check{"build", CheckSuccess}},
+		{gqlCheckContext{Typename: "CheckRun", Name: "build", Status: "COMPLETED", Conclusion: "SKIPPED"}, Check{"build", CheckSkipped}},
+		{gqlCheckContext{Typename: "CheckRun", Name: "build", Status: "COMPLETED", Conclusion: "TIMED_OUT"}, Check{"build", CheckFailure}},
+		{gqlCheckContext{Typename: "StatusContext", Context: "ci/jenkins", State: "EXPECTED"}, Check{"ci/jenkins", CheckPending}},
+		{gqlCheckContext{Typename: "StatusContext", Context: "ci/jenkins", State: "ERROR"}, Check{"ci/jenkins", CheckFailure}},
+	} {
+		assert.Equal(t, tc.Want, tc.Context.toCheck())
+	}
+}
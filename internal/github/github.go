@@ -6,8 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"os/exec"
-	"slices"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -19,25 +18,112 @@ const (
 	ghConcurrency = 8
 )
 
+// API is the GitHub functionality used by jj-github. It is implemented by
+// *Client and can be replaced with a fake in tests.
+type API interface {
+	LoadStackState(ctx context.Context, repo Repo, branches []string, marker string) (*StackState, error)
+	GetPullRequestsForBranches(ctx context.Context, repo Repo, branches []string) (map[string]*github.PullRequest, error)
+	CreatePullRequest(ctx context.Context, repo Repo, opts PullRequestOptions) (*github.PullRequest, error)
+	UpdatePullRequest(ctx context.Context, repo Repo, number int, opts PullRequestOptions) (*github.PullRequest, error)
+	CreatePullRequestComment(ctx context.Context, repo Repo, prNumber int, body string) error
+	UpdatePullRequestComment(ctx context.Context, repo Repo, commentID int64, body string) error
+	LoadChecks(ctx context.Context, repo Repo, numbers []int) (map[int]*Checks, error)
+	EnableAutoMerge(ctx context.Context, repo Repo, pr *github.PullRequest, method string) error
+	DisableAutoMerge(ctx context.Context, repo Repo, pr *github.PullRequest) error
+	RateLimit() (RateLimit, bool)
+}
+
+var _ API = (*Client)(nil)
+
 // Client wraps the GitHub API client with authentication.
 type Client struct {
-	client *github.Client
+	client    *github.Client
+	transport *retryTransport
+	// source is where the token came from, see TokenSource.
+	source string
 }
 
-// NewClient creates a new GitHub client authenticated via the gh CLI.
+// NewClient creates a new GitHub client authenticated with the token from the
+// first of DefaultTokenSources that has one.
 func NewClient() (*Client, error) {
-	token, err := GetGHAuthToken()
+	token, err := FindToken(DefaultTokenSources())
 	if err != nil {
-		return nil, fmt.Errorf("get auth token from gh cli: %w", err)
+		return nil, err
 	}
 
+	c := newClient(token.Value, http.DefaultTransport)
+	c.source = token.Source
+	return c, nil
+}
+
+// NewClientWithBaseURL creates a client authenticated with token that talks to
+// the GitHub API at baseURL instead of https://api.github.com/. GraphQL
+// requests are sent to baseURL + "graphql".
+func NewClientWithBaseURL(token, baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	c := newClient(token, http.DefaultTransport)
+	c.client.BaseURL = u
+	return c, nil
+}
+
+// newClient creates a client that retries transient failures on top of base.
+func newClient(token string, base http.RoundTripper) *Client {
+	transport := newRetryTransport(base)
+	httpClient := &http.Client{Transport: transport}
+
 	return &Client{
-		client: github.NewClient(nil).WithAuthToken(token),
-	}, nil
+		client:    github.NewClient(httpClient).WithAuthToken(token),
+		transport: transport,
+	}
 }
 
-// GetPullRequestsForBranches gets all the open pull requests for the specified branches.
-// This expects only a single pull request to be open per branch.
+// RateLimit returns the most depleted API rate limit seen so far, across
+// the REST and GraphQL budgets. Returns false if no request has been made.
+func (c *Client) RateLimit() (RateLimit, bool) {
+	if c.transport == nil {
+		return RateLimit{}, false
+	}
+	return c.transport.lowestRateLimit()
+}
+
+// AuthStatus describes the account the client is authenticated as.
+type AuthStatus struct {
+	Login string
+	// Source is where the token came from, e.g. GH_TOKEN. Empty if the
+	// client was given a token directly.
+	Source string
+	// Scopes granted to a classic OAuth token. Empty for fine-grained and
+	// app tokens, which don't report scopes.
+	Scopes []string
+}
+
+// AuthStatus returns the authenticated user and the token's OAuth scopes.
+func (c *Client) AuthStatus(ctx context.Context) (AuthStatus, error) {
+	user, resp, err := c.client.Users.Get(ctx, "")
+	if err != nil {
+		return AuthStatus{}, fmt.Errorf("get authenticated user: %w", err)
+	}
+
+	status := AuthStatus{Login: user.GetLogin(), Source: c.source}
+	for scope := range strings.SplitSeq(resp.Header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			status.Scopes = append(status.Scopes, scope)
+		}
+	}
+	return status, nil
+}
+
+// GetPullRequestsForBranches gets the pull request to manage for each of the specified branches.
+// Branches are matched by head ref within the repository, so PRs from forks are ignored.
+// The returned PR may be closed; see SelectPullRequest for how it is chosen.
+// Branches without a usable PR are absent from the result.
 func (c *Client) GetPullRequestsForBranches(
 	ctx context.Context,
 	repo Repo,
@@ -51,32 +137,31 @@ func (c *Client) GetPullRequestsForBranches(
 
 	for _, branch := range branches {
 		eg.Go(func() error {
-			prs, _, err := c.client.PullRequests.ListPullRequestsWithCommit(ctx, repo.Owner, repo.Name, branch, nil)
-			if err != nil {
-				var ghErr *github.ErrorResponse
-				// This error indicates that the branch has not been pushed yet.
-				if errors.As(err, &ghErr) &&
-					ghErr.Response.StatusCode == http.StatusUnprocessableEntity {
-					return nil
-				}
-				return err
+			var prs []*github.PullRequest
+			opts := &github.PullRequestListOptions{
+				Head:        repo.Owner + ":" + branch,
+				State:       "all",
+				ListOptions: github.ListOptions{PerPage: 100},
 			}
-
-			// Filter out closed PRs.
-			prs = slices.DeleteFunc(prs, func(pr *github.PullRequest) bool {
-				return pr.ClosedAt != nil || *pr.Head.Ref != branch
-			})
-
-			if len(prs) == 0 {
-				return nil
+			for {
+				page, resp, err := c.client.PullRequests.List(ctx, repo.Owner, repo.Name, opts)
+				if err != nil {
+					return err
+				}
+				prs = append(prs, page...)
+				if resp.NextPage == 0 {
+					break
+				}
+				opts.Page = resp.NextPage
 			}
 
-			if len(prs) > 1 {
-				return fmt.Errorf("branch %q unexpectedly has %d open pull requests", branch, len(prs))
+			pr, err := SelectPullRequest(branch, prs)
+			if err != nil || pr == nil {
+				return err
 			}
 
 			mu.Lock()
-			result[branch] = prs[0]
+			result[branch] = pr
 			mu.Unlock()
 
 			return nil
@@ -90,6 +175,57 @@ func (c *Client) GetPullRequestsForBranches(
 	return result, nil
 }
 
+// SelectPullRequest picks which of a branch's pull requests jj-github should manage:
+//   - An open PR is always used. More than one open PR for a branch is an error.
+//   - Otherwise, the most recently closed PR that was never merged is used, and will be
+//     reopened on submit so that its review history is kept.
+//   - Merged PRs are never reused. If a branch only has merged PRs, nil is returned
+//     and a new PR will be created.
+func SelectPullRequest(branch string, prs []*github.PullRequest) (*github.PullRequest, error) {
+	var open []*github.PullRequest
+	var closed *github.PullRequest
+	for _, pr := range prs {
+		if pr.GetHead().GetRef() != branch {
+			continue
+		}
+
+		switch {
+		case pr.GetState() == "open":
+			open = append(open, pr)
+		case pr.MergedAt != nil || pr.GetMerged():
+			// Merged PRs can't be reopened.
+		case closed == nil || pr.GetClosedAt().After(closed.GetClosedAt().Time):
+			closed = pr
+		}
+	}
+
+	if len(open) > 1 {
+		return nil, fmt.Errorf("branch %q unexpectedly has %d open pull requests", branch, len(open))
+	}
+	if len(open) == 1 {
+		return open[0], nil
+	}
+
+	return closed, nil
+}
+
+// IsFork returns true if pr's head branch is in another repository than repo.
+// Pull requests without a head repository are treated as being from repo.
+func IsFork(pr *github.PullRequest, repo Repo) bool {
+	headRepo := pr.GetHead().GetRepo()
+	return headRepo != nil && !strings.EqualFold(headRepo.GetOwner().GetLogin(), repo.Owner)
+}
+
+// IsUnprocessable returns true if err is a 422 response from GitHub, which is
+// returned for requests that are well-formed but not allowed, such as
+// reopening a pull request whose branch was recreated.
+func IsUnprocessable(err error) bool {
+	var ghErr *github.ErrorResponse
+	return errors.As(err, &ghErr) &&
+		ghErr.Response != nil &&
+		ghErr.Response.StatusCode == http.StatusUnprocessableEntity
+}
+
 // PullRequestOptions specifies options for creating or updating a pull request.
 type PullRequestOptions struct {
 	Title  string
@@ -97,6 +233,7 @@ type PullRequestOptions struct {
 	Branch string
 	Base   string
 	Draft  bool
+	Reopen bool // Reopen a closed pull request when updating
 }
 
 // CreatePullRequest creates a new pull request.
@@ -121,8 +258,14 @@ func (c *Client) UpdatePullRequest(
 	repo Repo,
 	number int,
 	opts PullRequestOptions,
-) error {
-	_, _, err := c.client.PullRequests.Edit(ctx, repo.Owner, repo.Name, number, &github.PullRequest{
+) (*github.PullRequest, error) {
+	var state *string
+	if opts.Reopen {
+		state = github.Ptr("open")
+	}
+
+	pr, _, err := c.client.PullRequests.Edit(ctx, repo.Owner, repo.Name, number, &github.PullRequest{
+		State: state,
 		Title: &opts.Title,
 		Head: &github.PullRequestBranch{
 			Ref: &opts.Branch,
@@ -133,6 +276,93 @@ func (c *Client) UpdatePullRequest(
 		Body:  &opts.Body,
 		Draft: &opts.Draft,
 	})
+	return pr, err
+}
+
+// GetPullRequest returns the pull request with the given number.
+func (c *Client) GetPullRequest(ctx context.Context, repo Repo, number int) (*github.PullRequest, error) {
+	pr, _, err := c.client.PullRequests.Get(ctx, repo.Owner, repo.Name, number)
+	return pr, err
+}
+
+// ListPullRequestsWithBase returns the open pull requests that merge into base.
+func (c *Client) ListPullRequestsWithBase(ctx context.Context, repo Repo, base string) ([]*github.PullRequest, error) {
+	return c.listOpenPullRequests(ctx, repo, base)
+}
+
+// ListOpenPullRequests returns every open pull request in the repository.
+func (c *Client) ListOpenPullRequests(ctx context.Context, repo Repo) ([]*github.PullRequest, error) {
+	return c.listOpenPullRequests(ctx, repo, "")
+}
+
+// listOpenPullRequests returns the open pull requests that merge into base,
+// or into any branch if base is empty.
+func (c *Client) listOpenPullRequests(ctx context.Context, repo Repo, base string) ([]*github.PullRequest, error) {
+	var prs []*github.PullRequest
+	opts := &github.PullRequestListOptions{
+		Base:        base,
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		page, resp, err := c.client.PullRequests.List(ctx, repo.Owner, repo.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		prs = append(prs, page...)
+		if resp.NextPage == 0 {
+			return prs, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// ParsePullRequestRef parses a pull request given on the command line, either
+// as a number ("123" or "#123") or a URL such as
+// https://github.com/cbrewster/jj-github/pull/123. The repo is only returned
+// for URLs.
+func ParsePullRequestRef(ref string) (Repo, int, error) {
+	if number, err := strconv.Atoi(strings.TrimPrefix(ref, "#")); err == nil && number > 0 {
+		return Repo{}, number, nil
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil || u.Host != "github.com" {
+		return Repo{}, 0, fmt.Errorf("%q is not a pull request number or GitHub URL", ref)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 4 || parts[2] != "pull" {
+		return Repo{}, 0, fmt.Errorf("%q is not a pull request URL", ref)
+	}
+	number, err := strconv.Atoi(parts[3])
+	if err != nil || number <= 0 {
+		return Repo{}, 0, fmt.Errorf("%q is not a pull request URL", ref)
+	}
+
+	return Repo{Owner: parts[0], Name: parts[1]}, number, nil
+}
+
+// ClosePullRequest closes a pull request without merging it.
+func (c *Client) ClosePullRequest(ctx context.Context, repo Repo, number int) error {
+	_, _, err := c.client.PullRequests.Edit(ctx, repo.Owner, repo.Name, number, &github.PullRequest{
+		State: github.Ptr("closed"),
+	})
+	return err
+}
+
+// MergePullRequest merges a pull request with the given merge method
+// ("merge", "squash" or "rebase").
+func (c *Client) MergePullRequest(ctx context.Context, repo Repo, number int, method string) error {
+	_, _, err := c.client.PullRequests.Merge(ctx, repo.Owner, repo.Name, number, "", &github.PullRequestOptions{
+		MergeMethod: method,
+	})
+	return err
+}
+
+// DeleteBranch deletes a branch from the repository.
+func (c *Client) DeleteBranch(ctx context.Context, repo Repo, branch string) error {
+	_, err := c.client.Git.DeleteRef(ctx, repo.Owner, repo.Name, "heads/"+branch)
 	return err
 }
 
@@ -162,49 +392,6 @@ func (c *Client) UpdatePullRequestComment(
 	return err
 }
 
-// GetPRCommentsContaining returns comments containing the specified substring.
-func (c *Client) GetPRCommentsContaining(
-	ctx context.Context,
-	repo Repo,
-	pullRequests []int,
-	contents string,
-) (map[int]*github.IssueComment, error) {
-	var mu sync.Mutex
-	result := make(map[int]*github.IssueComment)
-
-	eg, ctx := errgroup.WithContext(ctx)
-	eg.SetLimit(ghConcurrency)
-
-	for _, prNumber := range pullRequests {
-		eg.Go(func() error {
-			issues, _, err := c.client.Issues.ListComments(ctx, repo.Owner, repo.Name, prNumber, nil)
-			if err != nil {
-				return err
-			}
-
-			issues = slices.DeleteFunc(issues, func(issue *github.IssueComment) bool {
-				return !strings.Contains(issue.GetBody(), contents)
-			})
-
-			if len(issues) == 0 {
-				return nil
-			}
-
-			mu.Lock()
-			result[prNumber] = issues[len(issues)-1]
-			mu.Unlock()
-
-			return nil
-		})
-	}
-
-	if err := eg.Wait(); err != nil {
-		return nil, err
-	}
-
-	return result, nil
-}
-
 // Repo represents a GitHub repository.
 type Repo struct {
 	Owner string
@@ -276,13 +463,3 @@ func parseHttpsRemote(remote string) (Repo, error) {
 
 	return Repo{Owner: owner, Name: repo}, nil
 }
-
-// GetGHAuthToken returns a GitHub auth token using the gh cli.
-func GetGHAuthToken() (string, error) {
-	out, err := exec.Command("gh", "auth", "token").Output()
-	if err != nil {
-		return "", fmt.Errorf("gh auth token: %w", err)
-	}
-
-	return strings.TrimSpace(string(out)), nil
-}
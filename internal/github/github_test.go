@@ -2,7 +2,9 @@ package github
 
 import (
 	"testing"
+	"time"
 
+	"github.com/google/go-github/v80/github"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -60,3 +62,119 @@ func TestGetRepoFromRemoteInvalid(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePullRequestRef(t *testing.T) {
+	for _, tc := range []struct {
+		Name   string
+		Ref    string
+		Repo   Repo
+		Number int
+	}{
+		{Name: "number", Ref: "123", Number: 123},
+		{Name: "hash", Ref: "#45", Number: 45},
+		{Name: "url", Ref: "https://github.com/cbrewster/jj-github/pull/7", Repo: Repo{Owner: "cbrewster", Name: "jj-github"}, Number: 7},
+		{Name: "url with tab", Ref: "https://github.com/cbrewster/jj-github/pull/7/files", Repo: Repo{Owner: "cbrewster", Name: "jj-github"}, Number: 7},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			repo, number, err := ParsePullRequestRef(tc.Ref)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Repo, repo)
+			assert.Equal(t, tc.Number, number)
+		})
+	}
+
+	for _, ref := range []string{"", "0", "main", "https://gitlab.com/a/b/pull/1", "https://github.com/a/b/issues/1", "https://github.com/a/b/pull/x"} {
+		_, _, err := ParsePullRequestRef(ref)
+		assert.Error(t, err, ref)
+	}
+}
+
+func TestIsFork(t *testing.T) {
+	repo := Repo{Owner: "owner", Name: "repo"}
+
+	pr := &github.PullRequest{Head: &github.PullRequestBranch{Ref: github.Ptr("patch-1")}}
+	assert.False(t, IsFork(pr, repo))
+
+	pr.Head.Repo = &github.Repository{Owner: &github.User{Login: github.Ptr("Owner")}}
+	assert.False(t, IsFork(pr, repo))
+
+	pr.Head.Repo.Owner.Login = github.Ptr("someone")
+	assert.True(t, IsFork(pr, repo))
+}
+
+func TestSelectPullRequest(t *testing.T) {
+	closedAt := func(day int) *github.Timestamp {
+		return &github.Timestamp{Time: time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC)}
+	}
+	pr := func(number int, state string, closed, merged *github.Timestamp) *github.PullRequest {
+		return &github.PullRequest{
+			Number:   github.Ptr(number),
+			State:    github.Ptr(state),
+			Head:     &github.PullRequestBranch{Ref: github.Ptr("push-a")},
+			ClosedAt: closed,
+			MergedAt: merged,
+		}
+	}
+
+	for _, tc := range []struct {
+		Name     string
+		PRs      []*github.PullRequest
+		Expected int // 0 means no PR should be selected
+	}{
+		{
+			Name:     "no pull requests",
+			Expected: 0,
+		},
+		{
+			Name: "open preferred over closed",
+			PRs: []*github.PullRequest{
+				pr(1, "closed", closedAt(2), nil),
+				pr(2, "open", nil, nil),
+			},
+			Expected: 2,
+		},
+		{
+			Name: "most recently closed is reopened",
+			PRs: []*github.PullRequest{
+				pr(1, "closed", closedAt(1), nil),
+				pr(2, "closed", closedAt(3), nil),
+				pr(3, "closed", closedAt(2), nil),
+			},
+			Expected: 2,
+		},
+		{
+			Name: "merged is never reused",
+			PRs: []*github.PullRequest{
+				pr(1, "closed", closedAt(1), closedAt(1)),
+			},
+			Expected: 0,
+		},
+		{
+			Name: "closed preferred over more recently merged",
+			PRs: []*github.PullRequest{
+				pr(1, "closed", closedAt(1), nil),
+				pr(2, "closed", closedAt(2), closedAt(2)),
+			},
+			Expected: 1,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			selected, err := SelectPullRequest("push-a", tc.PRs)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, selected.GetNumber())
+		})
+	}
+}
+
+func TestSelectPullRequestMultipleOpen(t *testing.T) {
+	open := func(number int) *github.PullRequest {
+		return &github.PullRequest{
+			Number: github.Ptr(number),
+			State:  github.Ptr("open"),
+			Head:   &github.PullRequestBranch{Ref: github.Ptr("push-a")},
+		}
+	}
+
+	_, err := SelectPullRequest("push-a", []*github.PullRequest{open(1), open(2)})
+	require.Error(t, err)
+}
@@ -0,0 +1,744 @@
+// Package githubtest provides fakes of the GitHub API for tests.
+package githubtest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// Fake is an in-memory implementation of github.API.
+type Fake struct {
+	mu           sync.Mutex
+	pullRequests []*gogithub.PullRequest
+	comments     map[int][]*gogithub.IssueComment
+	reviews      map[int][]*gogithub.PullRequestReview
+	threads      map[int][]github.ReviewThread
+	checks       map[int][]github.Check
+	mergeable    map[int]string
+	mergeQueues  map[string]bool
+	queue        map[int]*github.MergeQueueEntry
+	nextNumber   int
+	nextComment  int64
+	nextReview   int64
+	failures     map[string][]error
+	calls        []string
+	auth         github.AuthStatus
+	deleted      []string
+}
+
+var _ github.API = (*Fake)(nil)
+
+// NewFake creates an empty fake.
+func NewFake() *Fake {
+	return &Fake{
+		comments:    make(map[int][]*gogithub.IssueComment),
+		reviews:     make(map[int][]*gogithub.PullRequestReview),
+		threads:     make(map[int][]github.ReviewThread),
+		checks:      make(map[int][]github.Check),
+		mergeable:   make(map[int]string),
+		mergeQueues: make(map[string]bool),
+		queue:       make(map[int]*github.MergeQueueEntry),
+		nextNumber:  1,
+		nextComment: 1,
+		nextReview:  1,
+		failures:    make(map[string][]error),
+		auth:        github.AuthStatus{Login: reviewer, Scopes: []string{"repo", "workflow", "read:org"}},
+	}
+}
+
+// SetAuth sets the account and token scopes reported by AuthStatus.
+func (f *Fake) SetAuth(status github.AuthStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.auth = status
+}
+
+// AddPullRequest adds an existing open pull request.
+func (f *Fake) AddPullRequest(opts github.PullRequestOptions, headSHA string) *gogithub.PullRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pr := f.newPullRequest(opts)
+	pr.Head.SHA = gogithub.Ptr(headSHA)
+	return clone(pr)
+}
+
+// AddStack adds an open pull request for each of changes, as submit would
+// open them on top of base, with each change's current commit pushed.
+func (f *Fake) AddStack(base string, changes ...jj.Change) []*gogithub.PullRequest {
+	var prs []*gogithub.PullRequest
+	for _, change := range changes {
+		title, _, _ := strings.Cut(change.Description, "\n")
+		prs = append(prs, f.AddPullRequest(github.PullRequestOptions{
+			Title:  title,
+			Branch: change.GitPushBookmark,
+			Base:   base,
+		}, change.CommitID))
+		base = change.GitPushBookmark
+	}
+	return prs
+}
+
+// ClosePullRequest closes the pull request, optionally marking it merged.
+func (f *Fake) ClosePullRequest(number int, merged bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pr := f.find(number)
+	pr.State = gogithub.Ptr("closed")
+	pr.ClosedAt = &gogithub.Timestamp{}
+	if merged {
+		pr.Merged = gogithub.Ptr(true)
+		pr.MergedAt = &gogithub.Timestamp{}
+	}
+}
+
+// DeletedBranches returns the branches deleted through the API, in order.
+func (f *Fake) DeletedBranches() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.deleted)
+}
+
+// AddReview adds a review by user with the given state
+// (APPROVED, CHANGES_REQUESTED or COMMENTED) to a pull request.
+func (f *Fake) AddReview(number int, user, state string) *gogithub.PullRequestReview {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.addReview(number, user, state, "")
+}
+
+// Reviews returns the reviews on a pull request, oldest first.
+func (f *Fake) Reviews(number int) []*gogithub.PullRequestReview {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []*gogithub.PullRequestReview
+	for _, r := range f.reviews[number] {
+		rr := *r
+		result = append(result, &rr)
+	}
+	return result
+}
+
+// AddReviewThread adds a review thread to a pull request.
+func (f *Fake) AddReviewThread(number int, thread github.ReviewThread) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.threads[number] = append(f.threads[number], thread)
+}
+
+// SetChecks replaces the checks on a pull request's head commit.
+func (f *Fake) SetChecks(number int, runs ...github.Check) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.checks[number] = runs
+}
+
+// SetMergeable sets whether a pull request can be merged
+// (MERGEABLE, CONFLICTING or UNKNOWN). Open PRs are mergeable by default.
+func (f *Fake) SetMergeable(number int, mergeable string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.mergeable[number] = mergeable
+}
+
+// PullRequests returns all pull requests, in creation order.
+func (f *Fake) PullRequests() []*gogithub.PullRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []*gogithub.PullRequest
+	for _, pr := range f.pullRequests {
+		result = append(result, clone(pr))
+	}
+	return result
+}
+
+// Comments returns the comments on a pull request, oldest first.
+func (f *Fake) Comments(number int) []*gogithub.IssueComment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []*gogithub.IssueComment
+	for _, c := range f.comments[number] {
+		cc := *c
+		result = append(result, &cc)
+	}
+	return result
+}
+
+// FailNext makes the next call to method (e.g. "CreatePullRequest") return err.
+// Calling it multiple times queues multiple failures.
+func (f *Fake) FailNext(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures[method] = append(f.failures[method], err)
+}
+
+// Calls returns the names of the methods called so far.
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.calls...)
+}
+
+// LoadStackState implements github.API.
+func (f *Fake) LoadStackState(
+	ctx context.Context,
+	repo github.Repo,
+	branches []string,
+	marker string,
+) (*github.StackState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("LoadStackState"); err != nil {
+		return nil, err
+	}
+
+	prs, err := f.selectPullRequests(branches)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &github.StackState{
+		PullRequests:    prs,
+		Comments:        make(map[int]*gogithub.IssueComment),
+		ReviewDecisions: make(map[int]string),
+		Checks:          make(map[int]*github.Checks),
+		Mergeable:       make(map[int]string),
+	}
+	for _, pr := range prs {
+		if decision := f.reviewDecision(pr.GetNumber()); decision != "" {
+			state.ReviewDecisions[pr.GetNumber()] = decision
+		}
+		state.Checks[pr.GetNumber()] = github.NewChecks(slices.Clone(f.checks[pr.GetNumber()]))
+		state.Mergeable[pr.GetNumber()] = f.mergeableState(pr.GetNumber())
+		for _, c := range f.comments[pr.GetNumber()] {
+			if strings.Contains(c.GetBody(), marker) {
+				cc := *c
+				state.Comments[pr.GetNumber()] = &cc
+			}
+		}
+	}
+
+	return state, nil
+}
+
+// LoadReviews mirrors github.Client.LoadReviews.
+func (f *Fake) LoadReviews(ctx context.Context, repo github.Repo, numbers []int) (map[int]*github.Reviews, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("LoadReviews"); err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]*github.Reviews)
+	for _, number := range numbers {
+		if f.find(number) != nil {
+			result[number] = f.loadReviews(number)
+		}
+	}
+	return result, nil
+}
+
+// LoadChecks implements github.API.
+func (f *Fake) LoadChecks(ctx context.Context, repo github.Repo, numbers []int) (map[int]*github.Checks, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("LoadChecks"); err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]*github.Checks)
+	for _, number := range numbers {
+		if f.find(number) != nil {
+			result[number] = github.NewChecks(slices.Clone(f.checks[number]))
+		}
+	}
+	return result, nil
+}
+
+// MergePullRequest mirrors github.Client.MergePullRequest.
+func (f *Fake) MergePullRequest(ctx context.Context, repo github.Repo, number int, method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("MergePullRequest"); err != nil {
+		return err
+	}
+
+	pr := f.find(number)
+	if pr == nil {
+		return fmt.Errorf("pull request #%d not found", number)
+	}
+	if pr.GetState() != "open" || pr.GetDraft() || f.mergeableState(number) != "MERGEABLE" {
+		return fmt.Errorf("pull request #%d is not mergeable", number)
+	}
+	if f.mergeQueues[pr.GetBase().GetRef()] {
+		return fmt.Errorf("changes must be made through the merge queue")
+	}
+
+	pr.State = gogithub.Ptr("closed")
+	pr.Merged = gogithub.Ptr(true)
+	pr.ClosedAt = &gogithub.Timestamp{}
+	pr.MergedAt = &gogithub.Timestamp{}
+	return nil
+}
+
+// EnableAutoMerge implements github.API.
+func (f *Fake) EnableAutoMerge(ctx context.Context, repo github.Repo, pr *gogithub.PullRequest, method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("EnableAutoMerge"); err != nil {
+		return err
+	}
+	return f.setAutoMerge(pr.GetNodeID(), method)
+}
+
+// DisableAutoMerge implements github.API.
+func (f *Fake) DisableAutoMerge(ctx context.Context, repo github.Repo, pr *gogithub.PullRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("DisableAutoMerge"); err != nil {
+		return err
+	}
+	return f.setAutoMerge(pr.GetNodeID(), "")
+}
+
+// SetMergeQueue requires changes to branch to go through a merge queue.
+func (f *Fake) SetMergeQueue(branch string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.mergeQueues[branch] = true
+}
+
+// SetMergeQueueEntry sets the state of a queued pull request and the checks on
+// the queue's commit. An empty state removes the pull request from the queue,
+// as GitHub does when its checks fail. Use ClosePullRequest to merge it.
+func (f *Fake) SetMergeQueueEntry(number int, state string, checks ...github.Check) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := f.queue[number]
+	if !ok {
+		return
+	}
+	if state == "" {
+		delete(f.queue, number)
+		return
+	}
+	entry.State = state
+	entry.Checks = github.NewChecks(checks)
+}
+
+// HasMergeQueue returns true if SetMergeQueue was called for branch.
+func (f *Fake) HasMergeQueue(ctx context.Context, repo github.Repo, branch string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("HasMergeQueue"); err != nil {
+		return false, err
+	}
+	return f.mergeQueues[branch], nil
+}
+
+// EnqueuePullRequest adds pr to the merge queue of its base branch.
+func (f *Fake) EnqueuePullRequest(ctx context.Context, repo github.Repo, pr *gogithub.PullRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("EnqueuePullRequest"); err != nil {
+		return err
+	}
+	return f.enqueue(pr.GetNodeID())
+}
+
+// LoadMergeQueueEntry returns the state of a pull request in its merge queue.
+func (f *Fake) LoadMergeQueueEntry(ctx context.Context, repo github.Repo, number int) (*github.MergeQueueEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("LoadMergeQueueEntry"); err != nil {
+		return nil, err
+	}
+	return f.mergeQueueEntry(number)
+}
+
+// GetPullRequestsForBranches implements github.API.
+func (f *Fake) GetPullRequestsForBranches(
+	ctx context.Context,
+	repo github.Repo,
+	branches []string,
+) (map[string]*gogithub.PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("GetPullRequestsForBranches"); err != nil {
+		return nil, err
+	}
+
+	return f.selectPullRequests(branches)
+}
+
+// GetPullRequest returns the pull request with the given number.
+func (f *Fake) GetPullRequest(ctx context.Context, repo github.Repo, number int) (*gogithub.PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("GetPullRequest"); err != nil {
+		return nil, err
+	}
+
+	pr := f.find(number)
+	if pr == nil {
+		return nil, fmt.Errorf("pull request #%d not found", number)
+	}
+	return clone(pr), nil
+}
+
+// ListPullRequestsWithBase returns the open pull requests that merge into base.
+func (f *Fake) ListPullRequestsWithBase(ctx context.Context, repo github.Repo, base string) ([]*gogithub.PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("ListPullRequestsWithBase"); err != nil {
+		return nil, err
+	}
+
+	var prs []*gogithub.PullRequest
+	for _, pr := range f.pullRequests {
+		if pr.GetBase().GetRef() == base && pr.GetState() == "open" {
+			prs = append(prs, clone(pr))
+		}
+	}
+	return prs, nil
+}
+
+// ListOpenPullRequests returns every open pull request.
+func (f *Fake) ListOpenPullRequests(ctx context.Context, repo github.Repo) ([]*gogithub.PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("ListOpenPullRequests"); err != nil {
+		return nil, err
+	}
+
+	var prs []*gogithub.PullRequest
+	for _, pr := range f.pullRequests {
+		if pr.GetState() == "open" {
+			prs = append(prs, clone(pr))
+		}
+	}
+	return prs, nil
+}
+
+// CreatePullRequest implements github.API.
+func (f *Fake) CreatePullRequest(
+	ctx context.Context,
+	repo github.Repo,
+	opts github.PullRequestOptions,
+) (*gogithub.PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("CreatePullRequest"); err != nil {
+		return nil, err
+	}
+
+	for _, pr := range f.pullRequests {
+		if pr.GetHead().GetRef() == opts.Branch && pr.GetState() == "open" {
+			return nil, fmt.Errorf("a pull request already exists for %s", opts.Branch)
+		}
+	}
+
+	return clone(f.newPullRequest(opts)), nil
+}
+
+// UpdatePullRequest implements github.API.
+func (f *Fake) UpdatePullRequest(
+	ctx context.Context,
+	repo github.Repo,
+	number int,
+	opts github.PullRequestOptions,
+) (*gogithub.PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("UpdatePullRequest"); err != nil {
+		return nil, err
+	}
+
+	pr := f.find(number)
+	if pr == nil {
+		return nil, fmt.Errorf("pull request #%d not found", number)
+	}
+
+	if opts.Reopen {
+		pr.State = gogithub.Ptr("open")
+		pr.ClosedAt = nil
+	}
+	pr.Title = gogithub.Ptr(opts.Title)
+	pr.Body = gogithub.Ptr(opts.Body)
+	pr.Base.Ref = gogithub.Ptr(opts.Base)
+	pr.Draft = gogithub.Ptr(opts.Draft)
+
+	return clone(pr), nil
+}
+
+// CreatePullRequestComment implements github.API.
+func (f *Fake) CreatePullRequestComment(ctx context.Context, repo github.Repo, prNumber int, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("CreatePullRequestComment"); err != nil {
+		return err
+	}
+
+	f.comments[prNumber] = append(f.comments[prNumber], &gogithub.IssueComment{
+		ID:   gogithub.Ptr(f.nextComment),
+		Body: gogithub.Ptr(body),
+	})
+	f.nextComment++
+	return nil
+}
+
+// UpdatePullRequestComment implements github.API.
+func (f *Fake) UpdatePullRequestComment(ctx context.Context, repo github.Repo, commentID int64, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("UpdatePullRequestComment"); err != nil {
+		return err
+	}
+
+	for _, comments := range f.comments {
+		for _, c := range comments {
+			if c.GetID() == commentID {
+				c.Body = gogithub.Ptr(body)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("comment %d not found", commentID)
+}
+
+// AuthStatus mirrors github.Client.AuthStatus.
+func (f *Fake) AuthStatus(ctx context.Context) (github.AuthStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("AuthStatus"); err != nil {
+		return github.AuthStatus{}, err
+	}
+	return f.auth, nil
+}
+
+// RateLimit implements github.API.
+func (f *Fake) RateLimit() (github.RateLimit, bool) {
+	return github.RateLimit{}, false
+}
+
+// call records a call to method and returns its queued failure, if any.
+func (f *Fake) call(method string) error {
+	f.calls = append(f.calls, method)
+
+	if errs := f.failures[method]; len(errs) > 0 {
+		f.failures[method] = errs[1:]
+		return errs[0]
+	}
+	return nil
+}
+
+func (f *Fake) newPullRequest(opts github.PullRequestOptions) *gogithub.PullRequest {
+	pr := &gogithub.PullRequest{
+		ID:     gogithub.Ptr(int64(f.nextNumber)),
+		NodeID: gogithub.Ptr(fmt.Sprintf("PR_%d", f.nextNumber)),
+		Number: gogithub.Ptr(f.nextNumber),
+		State:  gogithub.Ptr("open"),
+		Title:  gogithub.Ptr(opts.Title),
+		Body:   gogithub.Ptr(opts.Body),
+		Draft:  gogithub.Ptr(opts.Draft),
+		Head:   &gogithub.PullRequestBranch{Ref: gogithub.Ptr(opts.Branch)},
+		Base:   &gogithub.PullRequestBranch{Ref: gogithub.Ptr(opts.Base)},
+	}
+	f.nextNumber++
+	f.pullRequests = append(f.pullRequests, pr)
+	return pr
+}
+
+func (f *Fake) addReview(number int, user, state, body string) *gogithub.PullRequestReview {
+	review := &gogithub.PullRequestReview{
+		ID:    gogithub.Ptr(f.nextReview),
+		User:  &gogithub.User{Login: gogithub.Ptr(user)},
+		State: gogithub.Ptr(state),
+		Body:  gogithub.Ptr(body),
+	}
+	f.nextReview++
+	f.reviews[number] = append(f.reviews[number], review)
+	return review
+}
+
+// reviewDecision summarizes the latest review from each user the way GitHub's
+// reviewDecision does, except that reviews are never required.
+func (f *Fake) reviewDecision(number int) string {
+	latest := make(map[string]string)
+	for _, r := range f.reviews[number] {
+		if r.GetState() == "APPROVED" || r.GetState() == "CHANGES_REQUESTED" {
+			latest[r.GetUser().GetLogin()] = r.GetState()
+		}
+	}
+
+	decision := ""
+	for _, state := range latest {
+		if state == "CHANGES_REQUESTED" {
+			return state
+		}
+		decision = state
+	}
+	return decision
+}
+
+// loadReviews returns the latest review of each user and the unresolved
+// threads on a pull request.
+func (f *Fake) loadReviews(number int) *github.Reviews {
+	result := &github.Reviews{Decision: f.reviewDecision(number)}
+	for _, r := range f.reviews[number] {
+		i := slices.IndexFunc(result.Reviewers, func(s github.ReviewerState) bool {
+			return s.Login == r.GetUser().GetLogin()
+		})
+		if i < 0 {
+			result.Reviewers = append(result.Reviewers, github.ReviewerState{Login: r.GetUser().GetLogin()})
+			i = len(result.Reviewers) - 1
+		}
+		result.Reviewers[i].State = r.GetState()
+	}
+	for _, thread := range f.threads[number] {
+		if !thread.Resolved {
+			result.Threads = append(result.Threads, thread)
+		}
+	}
+	return result
+}
+
+// mergeableState returns whether a pull request can be merged, as set by SetMergeable.
+func (f *Fake) mergeableState(number int) string {
+	if mergeable, ok := f.mergeable[number]; ok {
+		return mergeable
+	}
+	return "MERGEABLE"
+}
+
+// setAutoMerge turns on auto-merge with method for the PR with the given node
+// ID, or turns it off if method is empty. Like GitHub, auto-merge can only be
+// enabled for open PRs that aren't drafts.
+func (f *Fake) setAutoMerge(nodeID, method string) error {
+	i := slices.IndexFunc(f.pullRequests, func(pr *gogithub.PullRequest) bool { return pr.GetNodeID() == nodeID })
+	if i < 0 {
+		return fmt.Errorf("could not resolve to a node with the global id of %q", nodeID)
+	}
+	pr := f.pullRequests[i]
+
+	if method == "" {
+		pr.AutoMerge = nil
+		return nil
+	}
+	if pr.GetState() != "open" || pr.GetDraft() {
+		return fmt.Errorf("pull request #%d can't be auto-merged", pr.GetNumber())
+	}
+	pr.AutoMerge = &gogithub.PullRequestAutoMerge{MergeMethod: gogithub.Ptr(strings.ToLower(method))}
+	return nil
+}
+
+// enqueue adds the PR with the given node ID to the end of its base branch's
+// merge queue.
+func (f *Fake) enqueue(nodeID string) error {
+	i := slices.IndexFunc(f.pullRequests, func(pr *gogithub.PullRequest) bool { return pr.GetNodeID() == nodeID })
+	if i < 0 {
+		return fmt.Errorf("could not resolve to a node with the global id of %q", nodeID)
+	}
+	pr := f.pullRequests[i]
+
+	switch {
+	case !f.mergeQueues[pr.GetBase().GetRef()]:
+		return fmt.Errorf("%s has no merge queue", pr.GetBase().GetRef())
+	case pr.GetState() != "open" || pr.GetDraft():
+		return fmt.Errorf("pull request #%d can't be added to the merge queue", pr.GetNumber())
+	case f.queue[pr.GetNumber()] != nil:
+		return fmt.Errorf("pull request #%d is already in the merge queue", pr.GetNumber())
+	}
+	f.queue[pr.GetNumber()] = &github.MergeQueueEntry{State: "QUEUED", Position: len(f.queue) + 1}
+	return nil
+}
+
+// mergeQueueEntry returns a copy of a PR's merge queue entry. The PR leaves
+// the queue once it is merged.
+func (f *Fake) mergeQueueEntry(number int) (*github.MergeQueueEntry, error) {
+	pr := f.find(number)
+	if pr == nil {
+		return nil, fmt.Errorf("pull request #%d not found", number)
+	}
+	if pr.GetMerged() {
+		delete(f.queue, number)
+		return &github.MergeQueueEntry{Merged: true}, nil
+	}
+
+	entry := &github.MergeQueueEntry{}
+	if queued, ok := f.queue[number]; ok {
+		*entry = *queued
+	}
+	return entry, nil
+}
+
+func (f *Fake) find(number int) *gogithub.PullRequest {
+	for _, pr := range f.pullRequests {
+		if pr.GetNumber() == number {
+			return pr
+		}
+	}
+	return nil
+}
+
+func (f *Fake) selectPullRequests(branches []string) (map[string]*gogithub.PullRequest, error) {
+	result := make(map[string]*gogithub.PullRequest)
+	for _, branch := range branches {
+		pr, err := github.SelectPullRequest(branch, f.pullRequests)
+		if err != nil {
+			return nil, err
+		}
+		if pr != nil {
+			result[branch] = clone(pr)
+		}
+	}
+	return result, nil
+}
+
+func clone(pr *gogithub.PullRequest) *gogithub.PullRequest {
+	c := *pr
+	head := *pr.Head
+	base := *pr.Base
+	c.Head = &head
+	c.Base = &base
+	return &c
+}
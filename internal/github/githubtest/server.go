@@ -0,0 +1,817 @@
+package githubtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// reviewer is the login used for reviews created through the API.
+const reviewer = "octocat"
+
+// commentPageSize matches the page size in GraphQL comment queries.
+var commentPageSize = regexp.MustCompile(`comments\(first: (\d+)`)
+
+// pullRequestPageSize matches the page size in GraphQL pull request queries.
+var pullRequestPageSize = regexp.MustCompile(`pullRequests\(headRefName: \$h\d+, first: (\d+)`)
+
+// Server is a fake GitHub API served over HTTP and backed by a Fake. It
+// implements the REST and GraphQL endpoints used by github.Client for a single
+// repository, so the real client can be exercised end to end.
+//
+// Methods queued with FailNext fail the matching endpoint with a 500.
+type Server struct {
+	*Fake
+
+	// URL is the base URL of the API, for use with github.NewClientWithBaseURL.
+	URL string
+
+	// HeadSHA returns the commit a branch points at. GitHub keeps a PR's head
+	// in sync with its branch, so this is consulted whenever a PR is returned.
+	// If nil, the head SHA passed to AddPullRequest is used.
+	HeadSHA func(branch string) string
+
+	repo github.Repo
+}
+
+// NewServer starts a fake GitHub API for repo. It is closed when the test ends.
+func NewServer(t testing.TB, repo github.Repo) *Server {
+	t.Helper()
+
+	s := &Server{Fake: NewFake(), repo: repo}
+
+	prefix := fmt.Sprintf("/repos/%s/%s", repo.Owner, repo.Name)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+"/pulls", s.listPullRequests)
+	mux.HandleFunc("POST "+prefix+"/pulls", s.createPullRequest)
+	mux.HandleFunc("GET "+prefix+"/pulls/{number}", s.getPullRequest)
+	mux.HandleFunc("PATCH "+prefix+"/pulls/{number}", s.editPullRequest)
+	mux.HandleFunc("PUT "+prefix+"/pulls/{number}/merge", s.mergePullRequest)
+	mux.HandleFunc("GET "+prefix+"/pulls/{number}/reviews", s.listReviews)
+	mux.HandleFunc("POST "+prefix+"/pulls/{number}/reviews", s.createReview)
+	mux.HandleFunc("POST "+prefix+"/issues/{number}/comments", s.createComment)
+	mux.HandleFunc("PATCH "+prefix+"/issues/comments/{id}", s.editComment)
+	mux.HandleFunc("DELETE "+prefix+"/git/refs/heads/{branch...}", s.deleteBranch)
+	mux.HandleFunc("GET /user", s.getUser)
+	mux.HandleFunc("POST /graphql", s.graphQL)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	s.URL = server.URL + "/"
+
+	return s
+}
+
+// Client returns a github.Client that talks to the server.
+func (s *Server) Client(t testing.TB) *github.Client {
+	t.Helper()
+
+	client, err := github.NewClientWithBaseURL("token", s.URL)
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	return client
+}
+
+func (s *Server) listPullRequests(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ok(w, "GetPullRequestsForBranches") {
+		return
+	}
+
+	var branch string
+	if head := r.URL.Query().Get("head"); head != "" {
+		owner, ref, ok := strings.Cut(head, ":")
+		if !ok || !strings.EqualFold(owner, s.repo.Owner) {
+			writeJSON(w, http.StatusOK, []any{})
+			return
+		}
+		branch = ref
+	}
+
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		state = "open"
+	}
+	base := r.URL.Query().Get("base")
+
+	result := []*gogithub.PullRequest{}
+	for _, pr := range slices.Backward(s.pullRequests) {
+		if branch != "" && pr.GetHead().GetRef() != branch {
+			continue
+		}
+		if base != "" && pr.GetBase().GetRef() != base {
+			continue
+		}
+		if state != "all" && pr.GetState() != state {
+			continue
+		}
+		result = append(result, s.render(pr))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) createPullRequest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ok(w, "CreatePullRequest") {
+		return
+	}
+
+	var req gogithub.NewPullRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	_, head, ok := strings.Cut(req.GetHead(), ":")
+	if !ok {
+		head = req.GetHead()
+	}
+	if req.GetTitle() == "" || head == "" || req.GetBase() == "" {
+		writeError(w, http.StatusUnprocessableEntity, "title, head and base are required")
+		return
+	}
+	for _, pr := range s.pullRequests {
+		if pr.GetHead().GetRef() == head && pr.GetState() == "open" {
+			writeError(w, http.StatusUnprocessableEntity, "A pull request already exists for "+s.repo.Owner+":"+head+".")
+			return
+		}
+	}
+
+	pr := s.newPullRequest(github.PullRequestOptions{
+		Title:  req.GetTitle(),
+		Body:   req.GetBody(),
+		Branch: head,
+		Base:   req.GetBase(),
+		Draft:  req.GetDraft(),
+	})
+	writeJSON(w, http.StatusCreated, s.render(pr))
+}
+
+func (s *Server) getPullRequest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, ok := s.pullRequest(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.render(pr))
+}
+
+func (s *Server) editPullRequest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ok(w, "UpdatePullRequest") {
+		return
+	}
+	pr, ok := s.pullRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Title *string `json:"title"`
+		Body  *string `json:"body"`
+		State *string `json:"state"`
+		Base  *string `json:"base"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	state := ""
+	if req.State != nil {
+		state = *req.State
+	}
+
+	switch state {
+	case "":
+	case "open":
+		if pr.GetMerged() {
+			writeError(w, http.StatusUnprocessableEntity, "Cannot reopen a merged pull request.")
+			return
+		}
+		pr.State = gogithub.Ptr("open")
+		pr.ClosedAt = nil
+	case "closed":
+		if pr.GetState() == "open" {
+			pr.Head.SHA = s.render(pr).Head.SHA
+			pr.State = gogithub.Ptr("closed")
+			pr.ClosedAt = &gogithub.Timestamp{Time: time.Now()}
+		}
+	default:
+		writeError(w, http.StatusUnprocessableEntity, "Invalid state "+state)
+		return
+	}
+	if req.Title != nil {
+		pr.Title = req.Title
+	}
+	if req.Body != nil {
+		pr.Body = req.Body
+	}
+	if req.Base != nil {
+		pr.Base.Ref = req.Base
+	}
+
+	writeJSON(w, http.StatusOK, s.render(pr))
+}
+
+func (s *Server) mergePullRequest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ok(w, "MergePullRequest") {
+		return
+	}
+	pr, ok := s.pullRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if pr.GetState() != "open" || pr.GetDraft() || s.mergeableState(pr.GetNumber()) != "MERGEABLE" {
+		writeError(w, http.StatusMethodNotAllowed, "Pull Request is not mergeable")
+		return
+	}
+	if s.mergeQueues[pr.GetBase().GetRef()] {
+		writeError(w, http.StatusMethodNotAllowed, "Changes must be made through the merge queue")
+		return
+	}
+
+	// The head stops following the branch once the PR is closed.
+	pr.Head.SHA = s.render(pr).Head.SHA
+	now := &gogithub.Timestamp{Time: time.Now()}
+	pr.State = gogithub.Ptr("closed")
+	pr.Merged = gogithub.Ptr(true)
+	pr.ClosedAt = now
+	pr.MergedAt = now
+
+	writeJSON(w, http.StatusOK, &gogithub.PullRequestMergeResult{
+		SHA:     pr.Head.SHA,
+		Merged:  gogithub.Ptr(true),
+		Message: gogithub.Ptr("Pull Request successfully merged"),
+	})
+}
+
+func (s *Server) deleteBranch(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ok(w, "DeleteBranch") {
+		return
+	}
+
+	branch := r.PathValue("branch")
+	if slices.Contains(s.deleted, branch) {
+		writeError(w, http.StatusUnprocessableEntity, "Reference does not exist")
+		return
+	}
+	s.deleted = append(s.deleted, branch)
+
+	// Like GitHub, deleting a PR's head branch closes it.
+	for _, pr := range s.pullRequests {
+		if pr.GetHead().GetRef() == branch && pr.GetState() == "open" {
+			pr.Head.SHA = s.render(pr).Head.SHA
+			pr.State = gogithub.Ptr("closed")
+			pr.ClosedAt = &gogithub.Timestamp{Time: time.Now()}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ok(w, "AuthStatus") {
+		return
+	}
+
+	if len(s.auth.Scopes) > 0 {
+		w.Header().Set("X-OAuth-Scopes", strings.Join(s.auth.Scopes, ", "))
+	}
+	writeJSON(w, http.StatusOK, &gogithub.User{Login: gogithub.Ptr(s.auth.Login)})
+}
+
+func (s *Server) listReviews(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, ok := s.pullRequest(w, r)
+	if !ok {
+		return
+	}
+
+	result := []*gogithub.PullRequestReview{}
+	result = append(result, s.reviews[pr.GetNumber()]...)
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) createReview(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ok(w, "CreateReview") {
+		return
+	}
+	pr, ok := s.pullRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req gogithub.PullRequestReviewRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	var state string
+	switch req.GetEvent() {
+	case "APPROVE":
+		state = "APPROVED"
+	case "REQUEST_CHANGES":
+		state = "CHANGES_REQUESTED"
+	case "COMMENT", "":
+		state = "COMMENTED"
+	default:
+		writeError(w, http.StatusUnprocessableEntity, "Invalid event "+req.GetEvent())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.addReview(pr.GetNumber(), reviewer, state, req.GetBody()))
+}
+
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ok(w, "CreatePullRequestComment") {
+		return
+	}
+	pr, ok := s.pullRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req gogithub.IssueComment
+	if !decode(w, r, &req) {
+		return
+	}
+
+	comment := &gogithub.IssueComment{
+		ID:   gogithub.Ptr(s.nextComment),
+		Body: req.Body,
+	}
+	s.nextComment++
+	s.comments[pr.GetNumber()] = append(s.comments[pr.GetNumber()], comment)
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (s *Server) editComment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ok(w, "UpdatePullRequestComment") {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var req gogithub.IssueComment
+	if !decode(w, r, &req) {
+		return
+	}
+
+	for _, comments := range s.comments {
+		for _, c := range comments {
+			if c.GetID() == id {
+				c.Body = req.Body
+				writeJSON(w, http.StatusOK, c)
+				return
+			}
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+// graphQL serves the StackState and StackComments queries made by
+// github.Client.LoadStackState.
+func (s *Server) graphQL(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var req struct {
+		OperationName string         `json:"operationName"`
+		Query         string         `json:"query"`
+		Variables     map[string]any `json:"variables"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	// Mutations address pull requests by node ID rather than by repository.
+	switch req.OperationName {
+	case "EnqueuePullRequest":
+		if err := s.call(req.OperationName); err != nil {
+			writeJSON(w, http.StatusOK, graphQLErrors(err.Error()))
+			return
+		}
+		id, _ := req.Variables["id"].(string)
+		if err := s.enqueue(id); err != nil {
+			writeJSON(w, http.StatusOK, graphQLErrors(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"data": map[string]any{"enqueuePullRequest": map[string]any{"mergeQueueEntry": map[string]any{"id": "MQE_" + id}}},
+		})
+		return
+
+	case "EnableAutoMerge", "DisableAutoMerge":
+		if err := s.call(req.OperationName); err != nil {
+			writeJSON(w, http.StatusOK, graphQLErrors(err.Error()))
+			return
+		}
+		id, _ := req.Variables["id"].(string)
+		method, _ := req.Variables["method"].(string)
+		if err := s.setAutoMerge(id, method); err != nil {
+			writeJSON(w, http.StatusOK, graphQLErrors(err.Error()))
+			return
+		}
+		field := "enablePullRequestAutoMerge"
+		if req.OperationName == "DisableAutoMerge" {
+			field = "disablePullRequestAutoMerge"
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"data": map[string]any{field: map[string]any{"clientMutationId": nil}},
+		})
+		return
+	}
+
+	method := "LoadStackState"
+	switch req.OperationName {
+	case "Reviews":
+		method = "LoadReviews"
+	case "Checks":
+		method = "LoadChecks"
+	case "MergeQueue":
+		method = "HasMergeQueue"
+	case "MergeQueueEntry":
+		method = "LoadMergeQueueEntry"
+	}
+	if err := s.call(method); err != nil {
+		writeJSON(w, http.StatusOK, graphQLErrors(err.Error()))
+		return
+	}
+	if req.Variables["owner"] != s.repo.Owner || req.Variables["name"] != s.repo.Name {
+		writeJSON(w, http.StatusOK, graphQLErrors("Could not resolve to a Repository."))
+		return
+	}
+
+	pageSize := 100
+	if m := commentPageSize.FindStringSubmatch(req.Query); m != nil {
+		pageSize, _ = strconv.Atoi(m[1])
+	}
+
+	repository := make(map[string]any)
+	switch req.OperationName {
+	case "StackState", "StackPullRequests":
+		prPageSize := 100
+		if m := pullRequestPageSize.FindStringSubmatch(req.Query); m != nil {
+			prPageSize, _ = strconv.Atoi(m[1])
+		}
+		for name, value := range req.Variables {
+			if !isAlias(name, "h") {
+				continue
+			}
+			var prs []*gogithub.PullRequest
+			for _, pr := range slices.Backward(s.pullRequests) {
+				if pr.GetHead().GetRef() == value {
+					prs = append(prs, pr)
+				}
+			}
+
+			after, _ := req.Variables["a"+strings.TrimPrefix(name, "h")].(string)
+			start, _ := strconv.Atoi(after)
+			start = min(start, len(prs))
+			end := min(start+prPageSize, len(prs))
+			nodes := []any{}
+			for _, pr := range prs[start:end] {
+				nodes = append(nodes, s.renderGraphQL(pr, pageSize))
+			}
+			repository[name] = map[string]any{
+				"pageInfo": map[string]any{"hasNextPage": end < len(prs), "endCursor": strconv.Itoa(end)},
+				"nodes":    nodes,
+			}
+		}
+
+	case "StackComments":
+		for name, value := range req.Variables {
+			if !isAlias(name, "n") {
+				continue
+			}
+			index := strings.TrimPrefix(name, "n")
+			number, _ := value.(float64)
+			after, _ := req.Variables["a"+index].(string)
+			repository["p"+index] = map[string]any{
+				"comments": s.commentPage(int(number), after, pageSize),
+			}
+		}
+
+	case "Reviews":
+		for name, value := range req.Variables {
+			if !isAlias(name, "n") {
+				continue
+			}
+			number, _ := value.(float64)
+			if s.find(int(number)) == nil {
+				repository["p"+strings.TrimPrefix(name, "n")] = nil
+				continue
+			}
+			repository["p"+strings.TrimPrefix(name, "n")] = renderReviews(s.loadReviews(int(number)))
+		}
+
+	case "Checks":
+		for name, value := range req.Variables {
+			if !isAlias(name, "n") {
+				continue
+			}
+			number, _ := value.(float64)
+			if s.find(int(number)) == nil {
+				repository["p"+strings.TrimPrefix(name, "n")] = nil
+				continue
+			}
+			repository["p"+strings.TrimPrefix(name, "n")] = renderChecks(s.checks[int(number)])
+		}
+
+	case "MergeQueue":
+		branch, _ := req.Variables["branch"].(string)
+		repository["mergeQueue"] = nil
+		if s.mergeQueues[branch] {
+			repository["mergeQueue"] = map[string]any{"id": "MQ_" + branch}
+		}
+
+	case "MergeQueueEntry":
+		number, _ := req.Variables["number"].(float64)
+		entry, err := s.mergeQueueEntry(int(number))
+		if err != nil {
+			repository["pullRequest"] = nil
+			break
+		}
+		var queued any
+		if entry.State != "" {
+			var runs []github.Check
+			if entry.Checks != nil {
+				runs = entry.Checks.Runs
+			}
+			queued = map[string]any{
+				"state":      entry.State,
+				"position":   entry.Position,
+				"headCommit": map[string]any{"statusCheckRollup": renderRollup(runs)},
+			}
+		}
+		repository["pullRequest"] = map[string]any{"merged": entry.Merged, "mergeQueueEntry": queued}
+
+	default:
+		writeJSON(w, http.StatusOK, graphQLErrors("Unknown operation "+req.OperationName))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data": map[string]any{"repository": repository},
+	})
+}
+
+// renderGraphQL returns the PullRequestFields fragment for pr.
+func (s *Server) renderGraphQL(pr *gogithub.PullRequest, pageSize int) map[string]any {
+	pr = s.render(pr)
+
+	state := strings.ToUpper(pr.GetState())
+	if pr.GetMerged() {
+		state = "MERGED"
+	}
+
+	var reviewDecision any
+	if decision := s.reviewDecision(pr.GetNumber()); decision != "" {
+		reviewDecision = decision
+	}
+
+	var autoMerge any
+	if method := github.AutoMergeMethod(pr); method != "" {
+		autoMerge = map[string]any{"mergeMethod": strings.ToUpper(method)}
+	}
+
+	var closedAt, mergedAt any
+	if pr.ClosedAt != nil {
+		closedAt = pr.ClosedAt.Format(time.RFC3339)
+	}
+	if pr.MergedAt != nil {
+		mergedAt = pr.MergedAt.Format(time.RFC3339)
+	}
+
+	return map[string]any{
+		"id":                  pr.GetNodeID(),
+		"databaseId":          pr.GetID(),
+		"number":              pr.GetNumber(),
+		"title":               pr.GetTitle(),
+		"body":                pr.GetBody(),
+		"isDraft":             pr.GetDraft(),
+		"state":               state,
+		"url":                 pr.GetHTMLURL(),
+		"closedAt":            closedAt,
+		"mergedAt":            mergedAt,
+		"headRefName":         pr.GetHead().GetRef(),
+		"headRefOid":          pr.GetHead().GetSHA(),
+		"headRepositoryOwner": map[string]any{"login": s.repo.Owner},
+		"baseRefName":         pr.GetBase().GetRef(),
+		"mergeable":           s.mergeableState(pr.GetNumber()),
+		"reviewDecision":      reviewDecision,
+		"autoMergeRequest":    autoMerge,
+		"commits":             renderChecks(s.checks[pr.GetNumber()])["commits"],
+		"comments":            s.commentPage(pr.GetNumber(), "", pageSize),
+	}
+}
+
+// renderReviews returns the review fields of the Reviews query.
+func renderReviews(reviews *github.Reviews) map[string]any {
+	var decision any
+	if reviews.Decision != "" {
+		decision = reviews.Decision
+	}
+
+	latest := []any{}
+	for _, r := range reviews.Reviewers {
+		latest = append(latest, map[string]any{"author": map[string]any{"login": r.Login}, "state": r.State})
+	}
+
+	threads := []any{}
+	for _, t := range reviews.Threads {
+		var line, originalLine any = t.Line, t.Line
+		if t.Outdated {
+			line = nil
+		}
+		if t.Line == 0 {
+			line, originalLine = nil, nil
+		}
+		threads = append(threads, map[string]any{
+			"isResolved":   t.Resolved,
+			"isOutdated":   t.Outdated,
+			"path":         t.Path,
+			"line":         line,
+			"originalLine": originalLine,
+			"comments": map[string]any{"nodes": []any{map[string]any{
+				"author": map[string]any{"login": t.Author},
+				"body":   t.Body,
+				"url":    t.URL,
+			}}},
+		})
+	}
+
+	return map[string]any{
+		"reviewDecision": decision,
+		"latestReviews":  map[string]any{"pageInfo": map[string]any{"hasNextPage": false}, "nodes": latest},
+		"reviewThreads":  map[string]any{"pageInfo": map[string]any{"hasNextPage": false}, "nodes": threads},
+	}
+}
+
+// renderChecks returns the head commit of the Checks query.
+func renderChecks(runs []github.Check) map[string]any {
+	return map[string]any{"commits": map[string]any{"nodes": []any{
+		map[string]any{"commit": map[string]any{"statusCheckRollup": renderRollup(runs)}},
+	}}}
+}
+
+// renderRollup returns the status check rollup of a commit, reporting every
+// check as a check run.
+func renderRollup(runs []github.Check) any {
+	var rollup any
+	if len(runs) > 0 {
+		contexts := []any{}
+		for _, run := range runs {
+			status, conclusion := "COMPLETED", any(nil)
+			switch run.Status {
+			case github.CheckPending:
+				status = "IN_PROGRESS"
+			case github.CheckSuccess:
+				conclusion = "SUCCESS"
+			case github.CheckFailure:
+				conclusion = "FAILURE"
+			case github.CheckSkipped:
+				conclusion = "SKIPPED"
+			}
+			contexts = append(contexts, map[string]any{
+				"__typename": "CheckRun",
+				"name":       run.Name,
+				"status":     status,
+				"conclusion": conclusion,
+			})
+		}
+		rollup = map[string]any{"contexts": map[string]any{"nodes": contexts}}
+	}
+	return rollup
+}
+
+// commentPage returns a page of comments on a PR. Cursors are offsets.
+func (s *Server) commentPage(number int, after string, pageSize int) map[string]any {
+	comments := s.comments[number]
+
+	start, _ := strconv.Atoi(after)
+	start = min(start, len(comments))
+	end := min(start+pageSize, len(comments))
+
+	nodes := []any{}
+	for _, c := range comments[start:end] {
+		nodes = append(nodes, map[string]any{"databaseId": c.GetID(), "body": c.GetBody()})
+	}
+
+	return map[string]any{
+		"pageInfo": map[string]any{
+			"hasNextPage": end < len(comments),
+			"endCursor":   strconv.Itoa(end),
+		},
+		"nodes": nodes,
+	}
+}
+
+// render returns a copy of pr as the API would return it.
+func (s *Server) render(pr *gogithub.PullRequest) *gogithub.PullRequest {
+	pr = clone(pr)
+	pr.HTMLURL = gogithub.Ptr(fmt.Sprintf("https://github.com/%s/%s/pull/%d", s.repo.Owner, s.repo.Name, pr.GetNumber()))
+	if s.HeadSHA != nil && pr.GetState() == "open" {
+		pr.Head.SHA = gogithub.Ptr(s.HeadSHA(pr.GetHead().GetRef()))
+	}
+	return pr
+}
+
+// pullRequest looks up the PR in the request path, writing a 404 if missing.
+func (s *Server) pullRequest(w http.ResponseWriter, r *http.Request) (*gogithub.PullRequest, bool) {
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err == nil {
+		if pr := s.find(number); pr != nil {
+			return pr, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+	return nil, false
+}
+
+// ok records a call to method and writes a 500 if a failure was queued for it.
+func (s *Server) ok(w http.ResponseWriter, method string) bool {
+	if err := s.call(method); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// isAlias reports whether name is prefix followed by a number, e.g. "h3".
+func isAlias(name, prefix string) bool {
+	rest, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return false
+	}
+	_, err := strconv.Atoi(rest)
+	return err == nil
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return false
+	}
+	return true
+}
+
+func graphQLErrors(message string) map[string]any {
+	return map[string]any{
+		"data":   nil,
+		"errors": []any{map[string]any{"message": message}},
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"message": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,409 @@
+package githubtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+// restClient returns a go-github client for endpoints github.Client doesn't wrap.
+func restClient(t *testing.T, s *Server) *gogithub.Client {
+	t.Helper()
+
+	client := gogithub.NewClient(nil)
+	baseURL, err := url.Parse(s.URL)
+	require.NoError(t, err)
+	client.BaseURL = baseURL
+	return client
+}
+
+func TestServerPullRequests(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	s.HeadSHA = func(branch string) string { return "sha-" + branch }
+	client := s.Client(t)
+
+	pr, err := client.CreatePullRequest(ctx, testRepo, github.PullRequestOptions{
+		Title:  "Add feature",
+		Body:   "Body",
+		Branch: "push-a",
+		Base:   "main",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, pr.GetNumber())
+	assert.Equal(t, "https://github.com/owner/repo/pull/1", pr.GetHTMLURL())
+	assert.Equal(t, "sha-push-a", pr.GetHead().GetSHA())
+
+	_, err = client.CreatePullRequest(ctx, testRepo, github.PullRequestOptions{
+		Title:  "Duplicate",
+		Branch: "push-a",
+		Base:   "main",
+	})
+	assert.True(t, github.IsUnprocessable(err))
+
+	pr, err = client.UpdatePullRequest(ctx, testRepo, 1, github.PullRequestOptions{
+		Title:  "Add feature (v2)",
+		Body:   "Body v2",
+		Branch: "push-a",
+		Base:   "push-b",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Add feature (v2)", pr.GetTitle())
+	assert.Equal(t, "push-b", pr.GetBase().GetRef())
+
+	prs, err := client.GetPullRequestsForBranches(ctx, testRepo, []string{"push-a", "push-missing"})
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	assert.Equal(t, "Body v2", prs["push-a"].GetBody())
+}
+
+func TestServerGetAndListByBase(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	client := s.Client(t)
+
+	s.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	s.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-b", Base: "push-a"}, "sha-b")
+	s.AddPullRequest(github.PullRequestOptions{Title: "C", Branch: "push-c", Base: "push-a"}, "sha-c")
+	s.ClosePullRequest(3, false)
+
+	pr, err := client.GetPullRequest(ctx, testRepo, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "push-a", pr.GetBase().GetRef())
+
+	_, err = client.GetPullRequest(ctx, testRepo, 99)
+	assert.Error(t, err)
+
+	prs, err := client.ListPullRequestsWithBase(ctx, testRepo, "push-a")
+	require.NoError(t, err)
+	require.Len(t, prs, 1, "closed pull requests aren't listed")
+	assert.Equal(t, 2, prs[0].GetNumber())
+
+	prs, err = client.ListOpenPullRequests(ctx, testRepo)
+	require.NoError(t, err)
+	assert.Len(t, prs, 2)
+}
+
+func TestServerReopen(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	client := s.Client(t)
+
+	pr := s.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha")
+	s.ClosePullRequest(pr.GetNumber(), false)
+	merged := s.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-b", Base: "main"}, "sha")
+	s.ClosePullRequest(merged.GetNumber(), true)
+
+	reopened, err := client.UpdatePullRequest(ctx, testRepo, pr.GetNumber(), github.PullRequestOptions{
+		Title:  "A",
+		Branch: "push-a",
+		Base:   "main",
+		Reopen: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "open", reopened.GetState())
+
+	_, err = client.UpdatePullRequest(ctx, testRepo, merged.GetNumber(), github.PullRequestOptions{
+		Title:  "B",
+		Branch: "push-b",
+		Base:   "main",
+		Reopen: true,
+	})
+	assert.True(t, github.IsUnprocessable(err))
+}
+
+func TestServerLoadStackState(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	s.HeadSHA = func(branch string) string { return "sha-" + branch }
+	client := s.Client(t)
+
+	a := s.AddPullRequest(github.PullRequestOptions{Title: "A", Body: "Body A", Branch: "push-a", Base: "main"}, "")
+	b := s.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-b", Base: "push-a", Draft: true}, "")
+	s.SetChecks(a.GetNumber(), github.Check{Name: "build", Status: github.CheckFailure})
+	s.AddReview(a.GetNumber(), "alice", "APPROVED")
+
+	// Enough comments on b to need a second page.
+	for i := range 150 {
+		require.NoError(t, client.CreatePullRequestComment(ctx, testRepo, b.GetNumber(), fmt.Sprintf("comment %d", i)))
+	}
+	require.NoError(t, client.CreatePullRequestComment(ctx, testRepo, b.GetNumber(), "<!-- marker --> stack"))
+	require.NoError(t, client.CreatePullRequestComment(ctx, testRepo, a.GetNumber(), "<!-- marker --> old"))
+	require.NoError(t, client.CreatePullRequestComment(ctx, testRepo, a.GetNumber(), "<!-- marker --> new"))
+
+	state, err := client.LoadStackState(ctx, testRepo, []string{"push-a", "push-b", "push-c"}, "<!-- marker -->")
+	require.NoError(t, err)
+
+	require.Len(t, state.PullRequests, 2)
+	assert.Equal(t, "Body A", state.PullRequests["push-a"].GetBody())
+	assert.Equal(t, "sha-push-a", state.PullRequests["push-a"].GetHead().GetSHA())
+	assert.Equal(t, "push-a", state.PullRequests["push-b"].GetBase().GetRef())
+	assert.True(t, state.PullRequests["push-b"].GetDraft())
+	assert.Equal(t, []string{"build"}, state.Checks[a.GetNumber()].Failed())
+	assert.Equal(t, github.CheckNone, state.Checks[b.GetNumber()].Status)
+
+	assert.Equal(t, "<!-- marker --> new", state.Comments[a.GetNumber()].GetBody())
+	assert.Equal(t, "<!-- marker --> stack", state.Comments[b.GetNumber()].GetBody())
+	assert.Equal(t, map[int]string{a.GetNumber(): "APPROVED"}, state.ReviewDecisions)
+
+	// The open pull request is found behind a page of newer closed ones.
+	c := s.AddPullRequest(github.PullRequestOptions{Title: "C", Branch: "push-c", Base: "main"}, "")
+	for range 25 {
+		pr := s.AddPullRequest(github.PullRequestOptions{Title: "C", Branch: "push-c", Base: "main"}, "")
+		s.ClosePullRequest(pr.GetNumber(), false)
+	}
+	pages, err := client.LoadStackState(ctx, testRepo, []string{"push-c"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, c.GetNumber(), pages.PullRequests["push-c"].GetNumber())
+
+	// Editing the stack comment is visible on the next load.
+	require.NoError(t, client.UpdatePullRequestComment(ctx, testRepo, state.Comments[b.GetNumber()].GetID(), "<!-- marker --> edited"))
+	state, err = client.LoadStackState(ctx, testRepo, []string{"push-b"}, "<!-- marker -->")
+	require.NoError(t, err)
+	assert.Equal(t, "<!-- marker --> edited", state.Comments[b.GetNumber()].GetBody())
+}
+
+func TestServerMergeAndReviews(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	s.HeadSHA = func(branch string) string { return "sha-" + branch }
+	rest := restClient(t, s)
+
+	pr := s.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "")
+
+	review, _, err := rest.PullRequests.CreateReview(ctx, "owner", "repo", pr.GetNumber(), &gogithub.PullRequestReviewRequest{
+		Event: gogithub.Ptr("REQUEST_CHANGES"),
+		Body:  gogithub.Ptr("Needs tests"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "CHANGES_REQUESTED", review.GetState())
+
+	reviews, _, err := rest.PullRequests.ListReviews(ctx, "owner", "repo", pr.GetNumber(), nil)
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+	assert.Equal(t, "Needs tests", reviews[0].GetBody())
+
+	result, _, err := rest.PullRequests.Merge(ctx, "owner", "repo", pr.GetNumber(), "", nil)
+	require.NoError(t, err)
+	assert.True(t, result.GetMerged())
+	assert.Equal(t, "sha-push-a", result.GetSHA())
+
+	merged := s.PullRequests()[0]
+	assert.Equal(t, "closed", merged.GetState())
+	assert.True(t, merged.GetMerged())
+
+	_, _, err = rest.PullRequests.Merge(ctx, "owner", "repo", pr.GetNumber(), "", nil)
+	assert.Error(t, err, "merged PRs can't be merged again")
+}
+
+func TestServerLoadReviews(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	client := s.Client(t)
+
+	a := s.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	b := s.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-b", Base: "push-a"}, "sha-b")
+	s.AddReview(a.GetNumber(), "alice", "CHANGES_REQUESTED")
+	s.AddReview(a.GetNumber(), "bob", "COMMENTED")
+	s.AddReview(a.GetNumber(), "bob", "APPROVED")
+	s.AddReviewThread(a.GetNumber(), github.ReviewThread{Path: "main.go", Line: 12, Author: "alice", Body: "Handle the error"})
+	s.AddReviewThread(a.GetNumber(), github.ReviewThread{Path: "old.go", Line: 3, Outdated: true, Author: "bob", Body: "Typo"})
+	s.AddReviewThread(a.GetNumber(), github.ReviewThread{Path: "done.go", Line: 1, Resolved: true})
+
+	reviews, err := client.LoadReviews(ctx, testRepo, []int{a.GetNumber(), b.GetNumber(), 99})
+	require.NoError(t, err)
+	require.Len(t, reviews, 2)
+
+	assert.Equal(t, &github.Reviews{
+		Decision: "CHANGES_REQUESTED",
+		Reviewers: []github.ReviewerState{
+			{Login: "alice", State: "CHANGES_REQUESTED"},
+			{Login: "bob", State: "APPROVED"},
+		},
+		Threads: []github.ReviewThread{
+			{Path: "main.go", Line: 12, Author: "alice", Body: "Handle the error"},
+			{Path: "old.go", Line: 3, Outdated: true, Author: "bob", Body: "Typo"},
+		},
+	}, reviews[a.GetNumber()])
+	assert.Equal(t, &github.Reviews{}, reviews[b.GetNumber()])
+
+	s.FailNext("LoadReviews", errors.New("boom"))
+	_, err = client.LoadReviews(ctx, testRepo, []int{a.GetNumber()})
+	assert.ErrorContains(t, err, "boom")
+}
+
+func TestServerLoadChecks(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	client := s.Client(t)
+
+	a := s.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	b := s.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-b", Base: "push-a"}, "sha-b")
+	s.SetChecks(a.GetNumber(),
+		github.Check{Name: "build", Status: github.CheckSuccess},
+		github.Check{Name: "test", Status: github.CheckFailure},
+		github.Check{Name: "deploy", Status: github.CheckSkipped},
+	)
+
+	checks, err := client.LoadChecks(ctx, testRepo, []int{a.GetNumber(), b.GetNumber(), 99})
+	require.NoError(t, err)
+	require.Len(t, checks, 2)
+	assert.Equal(t, github.CheckFailure, checks[a.GetNumber()].Status)
+	assert.Equal(t, []string{"test"}, checks[a.GetNumber()].Failed())
+	assert.Equal(t, github.CheckNone, checks[b.GetNumber()].Status)
+
+	s.SetChecks(b.GetNumber(), github.Check{Name: "build", Status: github.CheckPending})
+	checks, err = client.LoadChecks(ctx, testRepo, []int{b.GetNumber()})
+	require.NoError(t, err)
+	assert.Equal(t, github.CheckPending, checks[b.GetNumber()].Status)
+}
+
+func TestServerMergeable(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	client := s.Client(t)
+
+	a := s.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	b := s.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-b", Base: "main"}, "sha-b")
+	s.SetMergeable(b.GetNumber(), "CONFLICTING")
+
+	state, err := client.LoadStackState(ctx, testRepo, []string{"push-a", "push-b"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{a.GetNumber(): "MERGEABLE", b.GetNumber(): "CONFLICTING"}, state.Mergeable)
+	assert.Empty(t, state.Comments, "comments aren't loaded without a marker")
+
+	require.NoError(t, client.MergePullRequest(ctx, testRepo, a.GetNumber(), "squash"))
+	assert.True(t, s.PullRequests()[0].GetMerged())
+
+	assert.Error(t, client.MergePullRequest(ctx, testRepo, b.GetNumber(), "squash"))
+	assert.False(t, s.PullRequests()[1].GetMerged())
+}
+
+func TestServerAutoMerge(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	client := s.Client(t)
+
+	s.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	s.AddPullRequest(github.PullRequestOptions{Title: "WIP: B", Branch: "push-b", Base: "push-a", Draft: true}, "sha-b")
+
+	state, err := client.LoadStackState(ctx, testRepo, []string{"push-a", "push-b"}, "marker")
+	require.NoError(t, err)
+	a, b := state.PullRequests["push-a"], state.PullRequests["push-b"]
+	assert.Equal(t, "PR_1", a.GetNodeID())
+	assert.Empty(t, github.AutoMergeMethod(a))
+
+	require.NoError(t, client.EnableAutoMerge(ctx, testRepo, a, "squash"))
+	assert.ErrorContains(t, client.EnableAutoMerge(ctx, testRepo, b, "squash"), "can't be auto-merged")
+
+	state, err = client.LoadStackState(ctx, testRepo, []string{"push-a"}, "marker")
+	require.NoError(t, err)
+	assert.Equal(t, "squash", github.AutoMergeMethod(state.PullRequests["push-a"]))
+
+	require.NoError(t, client.DisableAutoMerge(ctx, testRepo, a))
+	assert.Empty(t, github.AutoMergeMethod(s.PullRequests()[0]))
+}
+
+func TestServerMergeQueue(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	client := s.Client(t)
+
+	s.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	s.SetMergeQueue("main")
+
+	queued, err := client.HasMergeQueue(ctx, testRepo, "main")
+	require.NoError(t, err)
+	assert.True(t, queued)
+	queued, err = client.HasMergeQueue(ctx, testRepo, "release")
+	require.NoError(t, err)
+	assert.False(t, queued)
+
+	assert.ErrorContains(t, client.MergePullRequest(ctx, testRepo, 1, "squash"), "Changes must be made through the merge queue")
+
+	a := s.PullRequests()[0]
+	require.NoError(t, client.EnqueuePullRequest(ctx, testRepo, a))
+	entry, err := client.LoadMergeQueueEntry(ctx, testRepo, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &github.MergeQueueEntry{State: "QUEUED", Position: 1}, entry)
+
+	s.SetMergeQueueEntry(1, "AWAITING_CHECKS",
+		github.Check{Name: "build", Status: github.CheckSuccess},
+		github.Check{Name: "test", Status: github.CheckFailure})
+	entry, err = client.LoadMergeQueueEntry(ctx, testRepo, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "AWAITING_CHECKS", entry.State)
+	require.NotNil(t, entry.Checks)
+	assert.Equal(t, []string{"test"}, entry.Checks.Failed())
+
+	s.SetMergeQueueEntry(1, "")
+	entry, err = client.LoadMergeQueueEntry(ctx, testRepo, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &github.MergeQueueEntry{}, entry, "the PR left the queue without being merged")
+
+	require.NoError(t, client.EnqueuePullRequest(ctx, testRepo, a))
+	s.ClosePullRequest(1, true)
+	entry, err = client.LoadMergeQueueEntry(ctx, testRepo, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &github.MergeQueueEntry{Merged: true}, entry)
+}
+
+func TestServerFailNext(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	client := s.Client(t)
+
+	s.FailNext("LoadStackState", errors.New("something went wrong"))
+	_, err := client.LoadStackState(ctx, testRepo, []string{"push-a"}, "marker")
+	assert.ErrorContains(t, err, "something went wrong")
+
+	s.FailNext("CreatePullRequest", errors.New("server error"))
+	_, err = client.CreatePullRequest(ctx, testRepo, github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"})
+	assert.ErrorContains(t, err, "server error")
+	assert.Empty(t, s.PullRequests())
+
+	_, err = client.LoadStackState(ctx, github.Repo{Owner: "other", Name: "repo"}, []string{"push-a"}, "marker")
+	assert.ErrorContains(t, err, "Could not resolve to a Repository.")
+}
+
+func TestServerAuthStatus(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	client := s.Client(t)
+
+	status, err := client.AuthStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, github.AuthStatus{Login: "octocat", Scopes: []string{"repo", "workflow", "read:org"}}, status)
+
+	s.SetAuth(github.AuthStatus{Login: "someone"})
+	status, err = client.AuthStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, github.AuthStatus{Login: "someone"}, status)
+}
+
+func TestServerCloseAndDeleteBranch(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(t, testRepo)
+	client := s.Client(t)
+
+	a := s.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	s.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "user/push-b", Base: "push-a"}, "sha-b")
+
+	require.NoError(t, client.ClosePullRequest(ctx, testRepo, a.GetNumber()))
+	require.NoError(t, client.DeleteBranch(ctx, testRepo, "user/push-b"))
+	assert.Error(t, client.DeleteBranch(ctx, testRepo, "user/push-b"), "already deleted")
+
+	prs := s.PullRequests()
+	assert.Equal(t, "closed", prs[0].GetState())
+	assert.Equal(t, "closed", prs[1].GetState(), "deleting the head branch closes the PR")
+	assert.Equal(t, []string{"user/push-b"}, s.DeletedBranches())
+}
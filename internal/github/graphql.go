@@ -0,0 +1,61 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// graphQLError is a single error returned in a GraphQL response.
+type graphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// graphQL executes a GraphQL query against the GitHub API and decodes the
+// "data" field of the response into out.
+func (c *Client) graphQL(
+	ctx context.Context,
+	operation string,
+	query string,
+	variables map[string]any,
+	out any,
+) error {
+	if strings.HasPrefix(strings.TrimSpace(query), "query") {
+		ctx = withIdempotent(ctx)
+	}
+
+	req, err := c.client.NewRequest(http.MethodPost, "graphql", map[string]any{
+		"operationName": operation,
+		"query":         query,
+		"variables":     variables,
+	})
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []graphQLError  `json:"errors"`
+	}
+	if _, err := c.client.Do(ctx, req, &resp); err != nil {
+		return fmt.Errorf("graphql %s: %w", operation, err)
+	}
+
+	if len(resp.Errors) > 0 {
+		var msgs []string
+		for _, e := range resp.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return fmt.Errorf("graphql %s: %s", operation, strings.Join(msgs, "; "))
+	}
+
+	if len(resp.Data) == 0 || string(resp.Data) == "null" {
+		return fmt.Errorf("graphql %s: %w", operation, errors.New("empty response"))
+	}
+
+	return json.Unmarshal(resp.Data, out)
+}
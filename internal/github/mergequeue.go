@@ -0,0 +1,121 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v80/github"
+)
+
+// MergeQueueEntry is the state of a pull request added to a merge queue.
+type MergeQueueEntry struct {
+	// State is QUEUED, AWAITING_CHECKS, MERGEABLE, UNMERGEABLE or LOCKED, or
+	// empty once the pull request has left the queue.
+	State string
+	// Position is the pull request's place in the queue, starting at 1.
+	Position int
+	// Merged is true once the queue has merged the pull request.
+	Merged bool
+	// Checks are the checks on the commit the queue is testing the pull
+	// request with. Nil until the queue has created it.
+	Checks *Checks
+}
+
+// HasMergeQueue returns true if changes to branch must go through a merge queue.
+func (c *Client) HasMergeQueue(ctx context.Context, repo Repo, branch string) (bool, error) {
+	const query = `query MergeQueue($owner: String!, $name: String!, $branch: String!) {
+  repository(owner: $owner, name: $name) {
+    mergeQueue(branch: $branch) { id }
+  }
+}`
+	var data struct {
+		Repository struct {
+			MergeQueue *struct {
+				ID string `json:"id"`
+			} `json:"mergeQueue"`
+		} `json:"repository"`
+	}
+	if err := c.graphQL(ctx, "MergeQueue", query, map[string]any{
+		"owner":  repo.Owner,
+		"name":   repo.Name,
+		"branch": branch,
+	}, &data); err != nil {
+		return false, err
+	}
+	return data.Repository.MergeQueue != nil, nil
+}
+
+// EnqueuePullRequest adds pr to the merge queue of its base branch.
+func (c *Client) EnqueuePullRequest(ctx context.Context, repo Repo, pr *github.PullRequest) error {
+	const mutation = `mutation EnqueuePullRequest($id: ID!) {
+  enqueuePullRequest(input: {pullRequestId: $id}) { mergeQueueEntry { id } }
+}`
+	var data struct{}
+	return c.graphQL(ctx, "EnqueuePullRequest", mutation, map[string]any{"id": pr.GetNodeID()}, &data)
+}
+
+// LoadMergeQueueEntry loads the state of a pull request in its merge queue.
+// Only the first 100 checks of the queue's commit are considered.
+func (c *Client) LoadMergeQueueEntry(ctx context.Context, repo Repo, number int) (*MergeQueueEntry, error) {
+	query := fmt.Sprintf(`query MergeQueueEntry($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      merged
+      mergeQueueEntry {
+        state
+        position
+        headCommit { statusCheckRollup { contexts(first: %d) { nodes {
+          __typename
+          ... on CheckRun { name status conclusion }
+          ... on StatusContext { context state }
+        } } } }
+      }
+    }
+  }
+}`, checkContextPageSize)
+
+	var data struct {
+		Repository struct {
+			PullRequest *struct {
+				Merged          bool `json:"merged"`
+				MergeQueueEntry *struct {
+					State      string `json:"state"`
+					Position   int    `json:"position"`
+					HeadCommit *struct {
+						StatusCheckRollup *struct {
+							Contexts struct {
+								Nodes []gqlCheckContext `json:"nodes"`
+							} `json:"contexts"`
+						} `json:"statusCheckRollup"`
+					} `json:"headCommit"`
+				} `json:"mergeQueueEntry"`
+			} `json:"pullRequest"`
+		} `json:"repository"`
+	}
+	if err := c.graphQL(ctx, "MergeQueueEntry", query, map[string]any{
+		"owner":  repo.Owner,
+		"name":   repo.Name,
+		"number": number,
+	}, &data); err != nil {
+		return nil, err
+	}
+
+	pr := data.Repository.PullRequest
+	if pr == nil {
+		return nil, fmt.Errorf("pull request #%d not found", number)
+	}
+
+	entry := &MergeQueueEntry{Merged: pr.Merged}
+	if queued := pr.MergeQueueEntry; queued != nil {
+		entry.State = queued.State
+		entry.Position = queued.Position
+		if queued.HeadCommit != nil && queued.HeadCommit.StatusCheckRollup != nil {
+			var runs []Check
+			for _, check := range queued.HeadCommit.StatusCheckRollup.Contexts.Nodes {
+				runs = append(runs, check.toCheck())
+			}
+			entry.Checks = NewChecks(runs)
+		}
+	}
+	return entry, nil
+}
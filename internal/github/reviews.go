@@ -0,0 +1,272 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// reviewPageSize is the number of reviews and review threads fetched per PR
+// per page.
+const reviewPageSize = 100
+
+const (
+	latestReviewsFields = `pageInfo { hasNextPage endCursor } nodes { author { login } state }`
+	reviewThreadsFields = `pageInfo { hasNextPage endCursor } nodes { isResolved isOutdated path line originalLine comments(first: 1) { nodes { author { login } body url } } }`
+)
+
+// Reviews is the review state of a pull request.
+type Reviews struct {
+	// Decision is the PR's review decision
+	// (APPROVED, CHANGES_REQUESTED or REVIEW_REQUIRED). Empty if no review is required.
+	Decision string
+	// Reviewers is the latest review state of each reviewer, in the order GitHub returns them.
+	Reviewers []ReviewerState
+	// Threads are the unresolved review threads.
+	Threads []ReviewThread
+}
+
+// ReviewerState is the state of a reviewer's latest review
+// (APPROVED, CHANGES_REQUESTED, COMMENTED or DISMISSED).
+type ReviewerState struct {
+	Login string
+	State string
+}
+
+// ReviewThread is a thread of review comments on a line of a file.
+type ReviewThread struct {
+	Path string
+	// Line is the line in the PR's current diff, or the line the thread was
+	// started on if it is outdated. Zero for comments on the whole file.
+	Line     int
+	Outdated bool
+	Resolved bool
+	// Author, Body and URL are from the thread's first comment.
+	Author string
+	Body   string
+	URL    string
+}
+
+type gqlLatestReviews struct {
+	PageInfo gqlPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		Author *struct {
+			Login string `json:"login"`
+		} `json:"author"`
+		State string `json:"state"`
+	} `json:"nodes"`
+}
+
+type gqlReviewThreads struct {
+	PageInfo gqlPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		IsResolved   bool   `json:"isResolved"`
+		IsOutdated   bool   `json:"isOutdated"`
+		Path         string `json:"path"`
+		Line         *int   `json:"line"`
+		OriginalLine *int   `json:"originalLine"`
+		Comments     struct {
+			Nodes []struct {
+				Author *struct {
+					Login string `json:"login"`
+				} `json:"author"`
+				Body string `json:"body"`
+				URL  string `json:"url"`
+			} `json:"nodes"`
+		} `json:"comments"`
+	} `json:"nodes"`
+}
+
+type gqlReviews struct {
+	ReviewDecision string           `json:"reviewDecision"`
+	LatestReviews  gqlLatestReviews `json:"latestReviews"`
+	ReviewThreads  gqlReviewThreads `json:"reviewThreads"`
+}
+
+// reviewCursors are where the next pages of a PR's reviews and review threads
+// start. Empty if there are no more.
+type reviewCursors struct {
+	reviews string
+	threads string
+}
+
+// next returns the cursors for the pages after r, and false if r was the last page.
+func (r gqlReviews) next() (reviewCursors, bool) {
+	var cursors reviewCursors
+	if r.LatestReviews.PageInfo.HasNextPage {
+		cursors.reviews = r.LatestReviews.PageInfo.EndCursor
+	}
+	if r.ReviewThreads.PageInfo.HasNextPage {
+		cursors.threads = r.ReviewThreads.PageInfo.EndCursor
+	}
+	return cursors, cursors.reviews != "" || cursors.threads != ""
+}
+
+// toReviews converts the GraphQL representation, keeping only unresolved threads.
+func (r gqlReviews) toReviews() *Reviews {
+	result := &Reviews{Decision: r.ReviewDecision}
+	for _, review := range r.LatestReviews.Nodes {
+		state := ReviewerState{State: review.State}
+		if review.Author != nil {
+			state.Login = review.Author.Login
+		}
+		result.Reviewers = append(result.Reviewers, state)
+	}
+
+	for _, thread := range r.ReviewThreads.Nodes {
+		if thread.IsResolved {
+			continue
+		}
+		t := ReviewThread{Path: thread.Path, Outdated: thread.IsOutdated}
+		switch {
+		case thread.Line != nil:
+			t.Line = *thread.Line
+		case thread.OriginalLine != nil:
+			t.Line = *thread.OriginalLine
+		}
+		if len(thread.Comments.Nodes) > 0 {
+			comment := thread.Comments.Nodes[0]
+			t.Body = comment.Body
+			t.URL = comment.URL
+			if comment.Author != nil {
+				t.Author = comment.Author.Login
+			}
+		}
+		result.Threads = append(result.Threads, t)
+	}
+	return result
+}
+
+// LoadReviews loads the review state of the given pull requests, keyed by PR
+// number. PRs are looked up in batches using a single GraphQL query per batch,
+// followed by a query per page for PRs with more reviews or threads.
+func (c *Client) LoadReviews(ctx context.Context, repo Repo, numbers []int) (map[int]*Reviews, error) {
+	loaded := make(map[int]*gqlReviews, len(numbers))
+	more := make(map[int]reviewCursors)
+
+	for batch := range slices.Chunk(numbers, stackBatchSize) {
+		var query strings.Builder
+		query.WriteString("query Reviews($owner: String!, $name: String!")
+		variables := map[string]any{
+			"owner": repo.Owner,
+			"name":  repo.Name,
+		}
+		for i, number := range batch {
+			fmt.Fprintf(&query, ", $n%d: Int!", i)
+			variables[fmt.Sprintf("n%d", i)] = number
+		}
+		query.WriteString(") {\n  repository(owner: $owner, name: $name) {\n")
+		for i := range batch {
+			fmt.Fprintf(&query,
+				"    p%d: pullRequest(number: $n%d) {\n"+
+					"      reviewDecision\n"+
+					"      latestReviews(first: %d) { %s }\n"+
+					"      reviewThreads(first: %d) { %s }\n"+
+					"    }\n",
+				i, i, reviewPageSize, latestReviewsFields, reviewPageSize, reviewThreadsFields)
+		}
+		query.WriteString("  }\n}\n")
+
+		var data struct {
+			Repository map[string]*gqlReviews `json:"repository"`
+		}
+		if err := c.graphQL(ctx, "Reviews", query.String(), variables, &data); err != nil {
+			return nil, err
+		}
+
+		for i, number := range batch {
+			reviews := data.Repository[fmt.Sprintf("p%d", i)]
+			if reviews == nil {
+				continue
+			}
+			loaded[number] = reviews
+			if cursors, ok := reviews.next(); ok {
+				more[number] = cursors
+			}
+		}
+	}
+
+	if err := c.loadRemainingReviews(ctx, repo, loaded, more); err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]*Reviews, len(loaded))
+	for number, reviews := range loaded {
+		result[number] = reviews.toReviews()
+	}
+	return result, nil
+}
+
+// loadRemainingReviews pages through the reviews and review threads of PRs
+// with more than one page of either, batching all PRs into a single query per
+// page. Pages are appended to loaded.
+func (c *Client) loadRemainingReviews(
+	ctx context.Context,
+	repo Repo,
+	loaded map[int]*gqlReviews,
+	cursors map[int]reviewCursors,
+) error {
+	for len(cursors) > 0 {
+		var query strings.Builder
+		query.WriteString("query ReviewPages($owner: String!, $name: String!")
+		variables := map[string]any{
+			"owner": repo.Owner,
+			"name":  repo.Name,
+		}
+
+		numbers := make([]int, 0, len(cursors))
+		for number := range cursors {
+			numbers = append(numbers, number)
+		}
+
+		for i, number := range numbers {
+			fmt.Fprintf(&query, ", $n%d: Int!", i)
+			variables[fmt.Sprintf("n%d", i)] = number
+			if after := cursors[number].reviews; after != "" {
+				fmt.Fprintf(&query, ", $r%d: String", i)
+				variables[fmt.Sprintf("r%d", i)] = after
+			}
+			if after := cursors[number].threads; after != "" {
+				fmt.Fprintf(&query, ", $t%d: String", i)
+				variables[fmt.Sprintf("t%d", i)] = after
+			}
+		}
+		query.WriteString(") {\n  repository(owner: $owner, name: $name) {\n")
+		for i, number := range numbers {
+			fmt.Fprintf(&query, "    p%d: pullRequest(number: $n%d) {\n", i, i)
+			if cursors[number].reviews != "" {
+				fmt.Fprintf(&query, "      latestReviews(first: %d, after: $r%d) { %s }\n", reviewPageSize, i, latestReviewsFields)
+			}
+			if cursors[number].threads != "" {
+				fmt.Fprintf(&query, "      reviewThreads(first: %d, after: $t%d) { %s }\n", reviewPageSize, i, reviewThreadsFields)
+			}
+			query.WriteString("    }\n")
+		}
+		query.WriteString("  }\n}\n")
+
+		var data struct {
+			Repository map[string]*gqlReviews `json:"repository"`
+		}
+		if err := c.graphQL(ctx, "ReviewPages", query.String(), variables, &data); err != nil {
+			return err
+		}
+
+		next := make(map[int]reviewCursors)
+		for i, number := range numbers {
+			page := data.Repository[fmt.Sprintf("p%d", i)]
+			if page == nil {
+				continue
+			}
+			reviews := loaded[number]
+			reviews.LatestReviews.Nodes = append(reviews.LatestReviews.Nodes, page.LatestReviews.Nodes...)
+			reviews.ReviewThreads.Nodes = append(reviews.ReviewThreads.Nodes, page.ReviewThreads.Nodes...)
+			if cursors, ok := page.next(); ok {
+				next[number] = cursors
+			}
+		}
+		cursors = next
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadReviewsPages(t *testing.T) {
+	var operations []string
+
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graphQLRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		operations = append(operations, req.OperationName)
+
+		switch req.OperationName {
+		case "Reviews":
+			fmt.Fprint(w, `{"data": {
+				"repository": {
+					"p0": {
+						"reviewDecision": "CHANGES_REQUESTED",
+						"latestReviews": {"pageInfo": {"hasNextPage": false}, "nodes": [{"author": {"login": "alice"}, "state": "APPROVED"}]},
+						"reviewThreads": {
+							"pageInfo": {"hasNextPage": true, "endCursor": "threads-1"},
+							"nodes": [{"isResolved": true, "path": "a.go", "line": 1, "comments": {"nodes": []}}]
+						}
+					},
+					"p1": null
+				}
+			}}`)
+		case "ReviewPages":
+			assert.EqualValues(t, 1, req.Variables["n0"])
+			assert.Equal(t, "threads-1", req.Variables["t0"])
+			assert.NotContains(t, req.Variables, "r0", "only connections with more pages are fetched")
+			assert.NotContains(t, req.Query, "latestReviews")
+			fmt.Fprint(w, `{"data": {
+				"repository": {
+					"p0": {"reviewThreads": {
+						"pageInfo": {"hasNextPage": false},
+						"nodes": [{"isResolved": false, "path": "b.go", "line": 7, "comments": {"nodes": [{"author": {"login": "bob"}, "body": "Typo"}]}}]
+					}}
+				}
+			}}`)
+		default:
+			t.Fatalf("unexpected operation %q", req.OperationName)
+		}
+	}))
+
+	reviews, err := client.LoadReviews(context.Background(), Repo{Owner: "owner", Name: "repo"}, []int{1, 99})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Reviews", "ReviewPages"}, operations)
+	require.Contains(t, reviews, 1)
+	assert.NotContains(t, reviews, 99)
+	assert.Equal(t, []ReviewerState{{Login: "alice", State: "APPROVED"}}, reviews[1].Reviewers)
+	assert.Equal(t, []ReviewThread{{Path: "b.go", Line: 7, Author: "bob", Body: "Typo"}}, reviews[1].Threads)
+}
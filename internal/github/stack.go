@@ -0,0 +1,355 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v80/github"
+)
+
+const (
+	// stackBatchSize is the number of branches looked up per GraphQL query.
+	stackBatchSize = 25
+	// pullRequestPageSize is the number of pull requests fetched per branch per page.
+	pullRequestPageSize = 20
+	// commentPageSize is the number of comments fetched per PR per page.
+	commentPageSize = 100
+)
+
+// StackState is the GitHub state of a set of branches, loaded in bulk.
+type StackState struct {
+	// PullRequests maps head branch name to the pull request chosen by SelectPullRequest.
+	PullRequests map[string]*github.PullRequest
+	// Comments maps PR number to the most recent comment containing the marker.
+	Comments map[int]*github.IssueComment
+	// ReviewDecisions maps PR number to its review decision
+	// (APPROVED, CHANGES_REQUESTED or REVIEW_REQUIRED). Empty if no review is required.
+	ReviewDecisions map[int]string
+	// Checks maps PR number to the checks on its head commit.
+	Checks map[int]*Checks
+	// Mergeable maps PR number to whether it can be merged into its base
+	// branch (MERGEABLE, CONFLICTING or UNKNOWN while GitHub computes it).
+	Mergeable map[int]string
+}
+
+const pullRequestFields = `
+fragment PullRequestFields on PullRequest {
+  id
+  databaseId
+  number
+  title
+  body
+  isDraft
+  state
+  url
+  closedAt
+  mergedAt
+  headRefName
+  headRefOid
+  headRepositoryOwner { login }
+  baseRefName
+  mergeable
+  reviewDecision
+  autoMergeRequest { mergeMethod }
+  ` + headCommitChecksFields + `
+  comments(first: %d) @include(if: $comments) {
+    pageInfo { hasNextPage endCursor }
+    nodes { databaseId body }
+  }
+}
+`
+
+type gqlPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type gqlComments struct {
+	PageInfo gqlPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		DatabaseID int64  `json:"databaseId"`
+		Body       string `json:"body"`
+	} `json:"nodes"`
+}
+
+type gqlPullRequest struct {
+	ID                  string     `json:"id"`
+	DatabaseID          int64      `json:"databaseId"`
+	Number              int        `json:"number"`
+	Title               string     `json:"title"`
+	Body                string     `json:"body"`
+	IsDraft             bool       `json:"isDraft"`
+	State               string     `json:"state"`
+	URL                 string     `json:"url"`
+	ClosedAt            *time.Time `json:"closedAt"`
+	MergedAt            *time.Time `json:"mergedAt"`
+	HeadRefName         string     `json:"headRefName"`
+	HeadRefOid          string     `json:"headRefOid"`
+	HeadRepositoryOwner *struct {
+		Login string `json:"login"`
+	} `json:"headRepositoryOwner"`
+	BaseRefName      string `json:"baseRefName"`
+	Mergeable        string `json:"mergeable"`
+	ReviewDecision   string `json:"reviewDecision"`
+	AutoMergeRequest *struct {
+		MergeMethod string `json:"mergeMethod"`
+	} `json:"autoMergeRequest"`
+	Commits  gqlHeadCommit `json:"commits"`
+	Comments gqlComments   `json:"comments"`
+}
+
+// toPullRequest converts the GraphQL representation into the REST type used
+// throughout jj-github.
+func (pr gqlPullRequest) toPullRequest() *github.PullRequest {
+	result := &github.PullRequest{
+		ID:      github.Ptr(pr.DatabaseID),
+		NodeID:  github.Ptr(pr.ID),
+		Number:  github.Ptr(pr.Number),
+		Title:   github.Ptr(pr.Title),
+		Body:    github.Ptr(pr.Body),
+		Draft:   github.Ptr(pr.IsDraft),
+		State:   github.Ptr(strings.ToLower(pr.State)),
+		HTMLURL: github.Ptr(pr.URL),
+		Merged:  github.Ptr(pr.MergedAt != nil),
+		Head: &github.PullRequestBranch{
+			Ref: github.Ptr(pr.HeadRefName),
+			SHA: github.Ptr(pr.HeadRefOid),
+			// An owner-less repository if it was deleted, e.g. a deleted fork.
+			Repo: &github.Repository{},
+		},
+		Base: &github.PullRequestBranch{
+			Ref: github.Ptr(pr.BaseRefName),
+		},
+	}
+	if pr.ClosedAt != nil {
+		result.ClosedAt = &github.Timestamp{Time: *pr.ClosedAt}
+	}
+	if pr.MergedAt != nil {
+		result.MergedAt = &github.Timestamp{Time: *pr.MergedAt}
+	}
+	if pr.HeadRepositoryOwner != nil {
+		result.Head.Repo.Owner = &github.User{Login: github.Ptr(pr.HeadRepositoryOwner.Login)}
+	}
+	if pr.AutoMergeRequest != nil {
+		result.AutoMerge = &github.PullRequestAutoMerge{
+			MergeMethod: github.Ptr(strings.ToLower(pr.AutoMergeRequest.MergeMethod)),
+		}
+	}
+	return result
+}
+
+// LoadStackState loads the pull request to manage for each of the given
+// branches, as chosen by SelectPullRequest, along with the checks on their
+// head commits, their review decisions, whether they can be merged, and the
+// most recent comment containing marker. Branches are looked up in batches
+// using a single GraphQL query per batch, and pull requests and comments
+// beyond the first page are fetched with follow-up queries. Comments aren't
+// loaded if marker is empty.
+func (c *Client) LoadStackState(
+	ctx context.Context,
+	repo Repo,
+	branches []string,
+	marker string,
+) (*StackState, error) {
+	state := &StackState{
+		PullRequests:    make(map[string]*github.PullRequest),
+		Comments:        make(map[int]*github.IssueComment),
+		ReviewDecisions: make(map[int]string),
+		Checks:          make(map[int]*Checks),
+		Mergeable:       make(map[int]string),
+	}
+	comments := marker != ""
+
+	nodes, err := c.loadPullRequests(ctx, repo, branches, comments)
+	if err != nil {
+		return nil, err
+	}
+
+	// PRs whose comments didn't fit on the first page, by PR number.
+	moreComments := make(map[int]string)
+
+	for _, branch := range branches {
+		byNumber := make(map[int]gqlPullRequest)
+		var prs []*github.PullRequest
+		for _, pr := range nodes[branch] {
+			// headRefName matches branches from forks too.
+			converted := pr.toPullRequest()
+			if IsFork(converted, repo) {
+				continue
+			}
+			byNumber[pr.Number] = pr
+			prs = append(prs, converted)
+		}
+
+		selected, err := SelectPullRequest(branch, prs)
+		if err != nil {
+			return nil, err
+		}
+		if selected == nil {
+			continue
+		}
+
+		pr := byNumber[selected.GetNumber()]
+		state.PullRequests[branch] = selected
+		if pr.ReviewDecision != "" {
+			state.ReviewDecisions[pr.Number] = pr.ReviewDecision
+		}
+		state.Checks[pr.Number] = pr.Commits.checks()
+		state.Mergeable[pr.Number] = pr.Mergeable
+		if !comments {
+			continue
+		}
+		state.addComments(pr.Number, pr.Comments, marker)
+		if pr.Comments.PageInfo.HasNextPage {
+			moreComments[pr.Number] = pr.Comments.PageInfo.EndCursor
+		}
+	}
+
+	if err := c.loadRemainingComments(ctx, repo, state, moreComments, marker); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// loadPullRequests loads every pull request whose head is one of branches,
+// keyed by branch. Branches are looked up in batches using a single GraphQL
+// query per batch, and branches with more than one page of pull requests are
+// paged through with follow-up queries.
+func (c *Client) loadPullRequests(
+	ctx context.Context,
+	repo Repo,
+	branches []string,
+	comments bool,
+) (map[string][]gqlPullRequest, error) {
+	result := make(map[string][]gqlPullRequest, len(branches))
+	cursors := make(map[string]string)
+
+	operation := "StackState"
+	for pending := branches; len(pending) > 0; operation = "StackPullRequests" {
+		var next []string
+		for batch := range slices.Chunk(pending, stackBatchSize) {
+			var query strings.Builder
+			fmt.Fprintf(&query, "query %s($owner: String!, $name: String!, $comments: Boolean!", operation)
+			variables := map[string]any{
+				"owner":    repo.Owner,
+				"name":     repo.Name,
+				"comments": comments,
+			}
+			for i, branch := range batch {
+				fmt.Fprintf(&query, ", $h%d: String!, $a%d: String", i, i)
+				variables[fmt.Sprintf("h%d", i)] = branch
+				var after any
+				if cursor, ok := cursors[branch]; ok {
+					after = cursor
+				}
+				variables[fmt.Sprintf("a%d", i)] = after
+			}
+			query.WriteString(") {\n  repository(owner: $owner, name: $name) {\n")
+			for i := range batch {
+				fmt.Fprintf(&query,
+					"    h%d: pullRequests(headRefName: $h%d, first: %d, after: $a%d, orderBy: {field: CREATED_AT, direction: DESC}) { pageInfo { hasNextPage endCursor } nodes { ...PullRequestFields } }\n",
+					i, i, pullRequestPageSize, i)
+			}
+			query.WriteString("  }\n}\n")
+			fmt.Fprintf(&query, pullRequestFields, checkContextPageSize, commentPageSize)
+
+			var data struct {
+				Repository map[string]struct {
+					PageInfo gqlPageInfo      `json:"pageInfo"`
+					Nodes    []gqlPullRequest `json:"nodes"`
+				} `json:"repository"`
+			}
+			if err := c.graphQL(ctx, operation, query.String(), variables, &data); err != nil {
+				return nil, err
+			}
+
+			for i, branch := range batch {
+				connection := data.Repository[fmt.Sprintf("h%d", i)]
+				result[branch] = append(result[branch], connection.Nodes...)
+				if connection.PageInfo.HasNextPage {
+					cursors[branch] = connection.PageInfo.EndCursor
+					next = append(next, branch)
+				}
+			}
+		}
+		pending = next
+	}
+
+	return result, nil
+}
+
+// loadRemainingComments pages through comments for PRs with more than one
+// page of comments, batching all PRs into a single query per page.
+func (c *Client) loadRemainingComments(
+	ctx context.Context,
+	repo Repo,
+	state *StackState,
+	cursors map[int]string,
+	marker string,
+) error {
+	for len(cursors) > 0 {
+		var query strings.Builder
+		query.WriteString("query StackComments($owner: String!, $name: String!")
+		variables := map[string]any{
+			"owner": repo.Owner,
+			"name":  repo.Name,
+		}
+
+		numbers := make([]int, 0, len(cursors))
+		for number := range cursors {
+			numbers = append(numbers, number)
+		}
+
+		for i, number := range numbers {
+			fmt.Fprintf(&query, ", $n%d: Int!, $a%d: String", i, i)
+			variables[fmt.Sprintf("n%d", i)] = number
+			variables[fmt.Sprintf("a%d", i)] = cursors[number]
+		}
+		query.WriteString(") {\n  repository(owner: $owner, name: $name) {\n")
+		for i := range numbers {
+			fmt.Fprintf(&query,
+				"    p%d: pullRequest(number: $n%d) { comments(first: %d, after: $a%d) { pageInfo { hasNextPage endCursor } nodes { databaseId body } } }\n",
+				i, i, commentPageSize, i)
+		}
+		query.WriteString("  }\n}\n")
+
+		var data struct {
+			Repository map[string]struct {
+				Comments gqlComments `json:"comments"`
+			} `json:"repository"`
+		}
+		if err := c.graphQL(ctx, "StackComments", query.String(), variables, &data); err != nil {
+			return err
+		}
+
+		next := make(map[int]string)
+		for i, number := range numbers {
+			comments := data.Repository[fmt.Sprintf("p%d", i)].Comments
+			state.addComments(number, comments, marker)
+			if comments.PageInfo.HasNextPage {
+				next[number] = comments.PageInfo.EndCursor
+			}
+		}
+		cursors = next
+	}
+
+	return nil
+}
+
+// addComments records the latest comment containing marker. Pages are
+// processed in order, so later matches replace earlier ones.
+func (s *StackState) addComments(prNumber int, comments gqlComments, marker string) {
+	for _, comment := range comments.Nodes {
+		if !strings.Contains(comment.Body, marker) {
+			continue
+		}
+		s.Comments[prNumber] = &github.IssueComment{
+			ID:   github.Ptr(comment.DatabaseID),
+			Body: github.Ptr(comment.Body),
+		}
+	}
+}
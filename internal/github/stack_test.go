@@ -0,0 +1,192 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a client that sends all requests to handler.
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := newClient("token", http.DefaultTransport)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	client.client.BaseURL = baseURL
+
+	return client
+}
+
+type graphQLRequest struct {
+	OperationName string         `json:"operationName"`
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func TestLoadStackState(t *testing.T) {
+	var operations []string
+
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/graphql", r.URL.Path)
+
+		var req graphQLRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		operations = append(operations, req.OperationName)
+
+		switch req.OperationName {
+		case "StackState":
+			assert.Equal(t, "push-a", req.Variables["h0"])
+			assert.Equal(t, "push-b", req.Variables["h1"])
+			fmt.Fprint(w, `{"data": {
+				"repository": {
+					"h0": {"nodes": [
+						{
+							"number": 1, "title": "A", "state": "OPEN",
+							"headRefName": "push-a", "headRefOid": "aaa", "baseRefName": "main",
+							"headRepositoryOwner": {"login": "fork-owner"},
+							"comments": {"pageInfo": {"hasNextPage": false}, "nodes": []}
+						},
+						{
+							"number": 2, "title": "A", "state": "OPEN", "isDraft": true,
+							"headRefName": "push-a", "headRefOid": "aaa", "baseRefName": "main",
+							"headRepositoryOwner": {"login": "owner"},
+							"reviewDecision": "APPROVED",
+							"commits": {"nodes": [{"commit": {"statusCheckRollup": {"contexts": {"nodes": [
+								{"__typename": "CheckRun", "name": "build", "status": "COMPLETED", "conclusion": "FAILURE"},
+								{"__typename": "StatusContext", "context": "ci/lint", "state": "SUCCESS"}
+							]}}}}]},
+							"comments": {
+								"pageInfo": {"hasNextPage": true, "endCursor": "cursor-1"},
+								"nodes": [{"databaseId": 10, "body": "<!-- marker --> old"}]
+							}
+						}
+					]},
+					"h1": {"nodes": []}
+				}
+			}}`)
+		case "StackComments":
+			assert.EqualValues(t, 2, req.Variables["n0"])
+			assert.Equal(t, "cursor-1", req.Variables["a0"])
+			fmt.Fprint(w, `{"data": {
+				"repository": {
+					"p0": {"comments": {
+						"pageInfo": {"hasNextPage": false},
+						"nodes": [
+							{"databaseId": 11, "body": "<!-- marker --> new"},
+							{"databaseId": 12, "body": "unrelated"}
+						]
+					}}
+				}
+			}}`)
+		default:
+			t.Fatalf("unexpected operation %q", req.OperationName)
+		}
+	}))
+
+	state, err := client.LoadStackState(
+		context.Background(),
+		Repo{Owner: "owner", Name: "repo"},
+		[]string{"push-a", "push-b"},
+		"<!-- marker -->",
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"StackState", "StackComments"}, operations)
+
+	require.Contains(t, state.PullRequests, "push-a")
+	assert.NotContains(t, state.PullRequests, "push-b")
+	pr := state.PullRequests["push-a"]
+	assert.Equal(t, 2, pr.GetNumber())
+	assert.Equal(t, "open", pr.GetState())
+	assert.True(t, pr.GetDraft())
+	assert.Equal(t, "aaa", pr.GetHead().GetSHA())
+	assert.Equal(t, "main", pr.GetBase().GetRef())
+
+	assert.Equal(t, "APPROVED", state.ReviewDecisions[2])
+	require.Contains(t, state.Checks, 2)
+	assert.Equal(t, CheckFailure, state.Checks[2].Status)
+	assert.Equal(t, []string{"build"}, state.Checks[2].Failed())
+	require.Contains(t, state.Comments, 2)
+	assert.EqualValues(t, 11, state.Comments[2].GetID())
+}
+
+func TestLoadStackStatePages(t *testing.T) {
+	var operations []string
+
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graphQLRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		operations = append(operations, req.OperationName)
+
+		switch req.OperationName {
+		case "StackState":
+			assert.Nil(t, req.Variables["a0"])
+			fmt.Fprint(w, `{"data": {
+				"repository": {
+					"h0": {
+						"pageInfo": {"hasNextPage": true, "endCursor": "cursor-1"},
+						"nodes": [{
+							"number": 3, "state": "MERGED", "mergedAt": "2025-01-02T00:00:00Z",
+							"headRefName": "push-a", "headRepositoryOwner": {"login": "owner"}
+						}]
+					},
+					"h1": {"pageInfo": {"hasNextPage": false}, "nodes": []}
+				}
+			}}`)
+		case "StackPullRequests":
+			assert.Equal(t, "push-a", req.Variables["h0"])
+			assert.Equal(t, "cursor-1", req.Variables["a0"])
+			assert.NotContains(t, req.Variables, "h1", "only branches with more pull requests are queried again")
+			fmt.Fprint(w, `{"data": {
+				"repository": {
+					"h0": {
+						"pageInfo": {"hasNextPage": false},
+						"nodes": [{
+							"number": 1, "state": "OPEN",
+							"headRefName": "push-a", "headRepositoryOwner": {"login": "owner"}
+						}]
+					}
+				}
+			}}`)
+		default:
+			t.Fatalf("unexpected operation %q", req.OperationName)
+		}
+	}))
+
+	state, err := client.LoadStackState(
+		context.Background(),
+		Repo{Owner: "owner", Name: "repo"},
+		[]string{"push-a", "push-b"},
+		"",
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"StackState", "StackPullRequests"}, operations)
+	require.Contains(t, state.PullRequests, "push-a")
+	assert.Equal(t, 1, state.PullRequests["push-a"].GetNumber(), "the open pull request on the second page is used")
+}
+
+func TestLoadStackStateErrors(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": null, "errors": [{"type": "NOT_FOUND", "message": "Could not resolve to a Repository"}]}`)
+	}))
+
+	_, err := client.LoadStackState(
+		context.Background(),
+		Repo{Owner: "owner", Name: "repo"},
+		[]string{"push-a"},
+		"<!-- marker -->",
+	)
+	assert.ErrorContains(t, err, "Could not resolve to a Repository")
+}
@@ -0,0 +1,266 @@
+package github
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRetries is the number of times a request is retried before giving up.
+	maxRetries = 5
+	// baseRetryDelay is the delay before the first retry, doubled on each attempt.
+	baseRetryDelay = 500 * time.Millisecond
+	// maxRetryDelay caps the exponential backoff between retries.
+	maxRetryDelay = 30 * time.Second
+	// maxRateLimitWait is the longest we'll wait for a rate limit to reset.
+	// Anything longer is returned to the caller as an error.
+	maxRateLimitWait = 2 * time.Minute
+)
+
+// RateLimit describes the remaining API budget.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	ResetAt   time.Time
+}
+
+type idempotentKey struct{}
+
+// withIdempotent marks requests made with ctx as safe to retry after a server
+// error, even if the HTTP method is not idempotent (e.g. GraphQL queries).
+func withIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// retryTransport retries requests that fail due to rate limits or transient
+// server errors, and records the rate limit budget reported by GitHub.
+type retryTransport struct {
+	base http.RoundTripper
+
+	maxRetries       int
+	baseDelay        time.Duration
+	maxDelay         time.Duration
+	maxRateLimitWait time.Duration
+	now              func() time.Time
+	sleep            func(ctx context.Context, d time.Duration) error
+
+	mu         sync.Mutex
+	rateLimits map[string]RateLimit // Keyed by X-RateLimit-Resource
+}
+
+func newRetryTransport(base http.RoundTripper) *retryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &retryTransport{
+		base:             base,
+		maxRetries:       maxRetries,
+		baseDelay:        baseRetryDelay,
+		maxDelay:         maxRetryDelay,
+		maxRateLimitWait: maxRateLimitWait,
+		now:              time.Now,
+		sleep:            sleepContext,
+		rateLimits:       make(map[string]RateLimit),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(ctx)
+			if req.Body != nil && req.Body != http.NoBody {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		t.recordRateLimit(resp)
+
+		if attempt >= t.maxRetries {
+			return resp, nil
+		}
+
+		delay, ok := t.retryDelay(req, resp, attempt)
+		if !ok {
+			return resp, nil
+		}
+
+		// Drain the body so the connection can be reused.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if err := t.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay returns how long to wait before retrying, or false if the
+// response should be returned to the caller as-is.
+func (t *retryTransport) retryDelay(req *http.Request, resp *http.Response, attempt int) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		// Rate limited requests are rejected before being processed, so
+		// they are always safe to retry.
+		if delay, ok := t.retryAfter(resp); ok {
+			return delay, delay <= t.maxRateLimitWait
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			delay, ok := t.untilReset(resp)
+			return delay, ok && delay <= t.maxRateLimitWait
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || isSecondaryRateLimit(resp) {
+			return t.backoff(attempt), true
+		}
+		return 0, false
+
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		// The request may have been processed before the error, so only
+		// retry if doing it twice is harmless.
+		if !isIdempotent(req) {
+			return 0, false
+		}
+		if delay, ok := t.retryAfter(resp); ok {
+			return delay, delay <= t.maxRateLimitWait
+		}
+		return t.backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// backoff returns the exponential backoff for the given attempt with jitter.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	delay := t.baseDelay << attempt
+	if delay <= 0 || delay > t.maxDelay {
+		delay = t.maxDelay
+	}
+	// Up to 25% jitter so concurrent requests don't retry in lockstep.
+	return delay - time.Duration(rand.Int64N(int64(delay)/4+1))
+}
+
+// retryAfter parses the Retry-After header, in seconds or as an HTTP date.
+func (t *retryTransport) retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(t.now()), 0), true
+	}
+	return 0, false
+}
+
+// untilReset returns the time until the rate limit resets, from X-RateLimit-Reset.
+func (t *retryTransport) untilReset(resp *http.Response) (time.Duration, bool) {
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	// Add a second of slack since the reset time has second granularity.
+	return max(time.Unix(reset, 0).Sub(t.now()), 0) + time.Second, true
+}
+
+// recordRateLimit stores the rate limit headers of resp.
+func (t *retryTransport) recordRateLimit(resp *http.Response) {
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	rateLimit := RateLimit{Limit: limit, Remaining: remaining}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		rateLimit.ResetAt = time.Unix(reset, 0)
+	}
+
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+
+	t.mu.Lock()
+	t.rateLimits[resource] = rateLimit
+	t.mu.Unlock()
+}
+
+// lowestRateLimit returns the rate limit with the smallest remaining fraction.
+func (t *retryTransport) lowestRateLimit() (RateLimit, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var lowest RateLimit
+	found := false
+	for _, rl := range t.rateLimits {
+		if rl.Limit == 0 {
+			continue
+		}
+		if !found || rl.Remaining*lowest.Limit < lowest.Remaining*rl.Limit {
+			lowest = rl
+			found = true
+		}
+	}
+	return lowest, found
+}
+
+// isSecondaryRateLimit returns true if resp is a secondary rate limit or
+// abuse detection response. GitHub only signals these in the message body,
+// so the body is read and replaced.
+func isSecondaryRateLimit(resp *http.Response) bool {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(strings.NewReader(string(body)))
+	if err != nil {
+		return false
+	}
+
+	msg := strings.ToLower(string(body))
+	return strings.Contains(msg, "secondary rate limit") ||
+		strings.Contains(msg, "abuse detection")
+}
+
+// isIdempotent returns true if req can safely be sent more than once.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	idempotent, _ := req.Context().Value(idempotentKey{}).(bool)
+	return idempotent
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
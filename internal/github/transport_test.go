@@ -0,0 +1,248 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyServer fails the first failures requests with the given response, then succeeds.
+type flakyServer struct {
+	mu       sync.Mutex
+	requests int
+	failures int
+	fail     func(w http.ResponseWriter)
+	succeed  func(w http.ResponseWriter)
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	n := s.requests
+	s.mu.Unlock()
+
+	if n <= s.failures {
+		s.fail(w)
+		return
+	}
+	s.succeed(w)
+}
+
+// newRetryTestClient returns a client pointed at handler whose retries don't
+// actually sleep. Requested delays are recorded instead.
+func newRetryTestClient(t *testing.T, handler http.Handler) (*Client, *[]time.Duration) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := newClient("token", http.DefaultTransport)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	client.client.BaseURL = baseURL
+
+	var sleeps []time.Duration
+	client.transport.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	client.transport.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+
+	return client, &sleeps
+}
+
+func writePullRequests(w http.ResponseWriter) {
+	w.Header().Set("X-RateLimit-Limit", "5000")
+	w.Header().Set("X-RateLimit-Remaining", "4999")
+	w.Header().Set("X-RateLimit-Reset", "1700000600")
+	fmt.Fprint(w, `[]`)
+}
+
+func TestRetryTransientServerErrors(t *testing.T) {
+	server := &flakyServer{
+		failures: 2,
+		fail:     func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+		succeed:  writePullRequests,
+	}
+	client, sleeps := newRetryTestClient(t, server)
+
+	_, err := client.GetPullRequestsForBranches(context.Background(), Repo{Owner: "o", Name: "r"}, []string{"push-a"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, server.requests)
+	require.Len(t, *sleeps, 2)
+	// Exponential backoff with up to 25% jitter.
+	assert.InDelta(t, baseRetryDelay, (*sleeps)[0], float64(baseRetryDelay)/4)
+	assert.InDelta(t, 2*baseRetryDelay, (*sleeps)[1], float64(baseRetryDelay)/2)
+}
+
+func TestRetryGivesUpAfterMaxRetries(t *testing.T) {
+	server := &flakyServer{
+		failures: maxRetries + 10,
+		fail:     func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+		succeed:  writePullRequests,
+	}
+	client, sleeps := newRetryTestClient(t, server)
+
+	_, err := client.GetPullRequestsForBranches(context.Background(), Repo{Owner: "o", Name: "r"}, []string{"push-a"})
+	require.Error(t, err)
+
+	assert.Equal(t, maxRetries+1, server.requests)
+	assert.Len(t, *sleeps, maxRetries)
+}
+
+func TestNoRetryForNonIdempotentServerErrors(t *testing.T) {
+	server := &flakyServer{
+		failures: 1,
+		fail:     func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+		succeed:  func(w http.ResponseWriter) { fmt.Fprint(w, `{"number": 1}`) },
+	}
+	client, sleeps := newRetryTestClient(t, server)
+
+	// Creating a PR may have succeeded before the 502, so it must not be retried.
+	_, err := client.CreatePullRequest(context.Background(), Repo{Owner: "o", Name: "r"}, PullRequestOptions{
+		Title:  "title",
+		Branch: "push-a",
+		Base:   "main",
+	})
+	require.Error(t, err)
+
+	assert.Equal(t, 1, server.requests)
+	assert.Empty(t, *sleeps)
+}
+
+func TestRetrySecondaryRateLimit(t *testing.T) {
+	for _, tc := range []struct {
+		Name          string
+		Fail          func(w http.ResponseWriter)
+		ExpectedSleep time.Duration
+	}{
+		{
+			Name: "retry-after",
+			Fail: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "7")
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"message": "You have exceeded a secondary rate limit."}`)
+			},
+			ExpectedSleep: 7 * time.Second,
+		},
+		{
+			Name: "primary-rate-limit-reset",
+			Fail: func(w http.ResponseWriter) {
+				w.Header().Set("X-RateLimit-Limit", "5000")
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.Itoa(1_700_000_030))
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"message": "API rate limit exceeded"}`)
+			},
+			ExpectedSleep: 31 * time.Second,
+		},
+		{
+			Name: "abuse-detection",
+			Fail: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"message": "You have triggered an abuse detection mechanism."}`)
+			},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := &flakyServer{
+				failures: 1,
+				fail:     tc.Fail,
+				succeed:  func(w http.ResponseWriter) { fmt.Fprint(w, `{"number": 1}`) },
+			}
+			client, sleeps := newRetryTestClient(t, server)
+
+			// Rate limited requests were never processed, so even creates are retried.
+			pr, err := client.CreatePullRequest(context.Background(), Repo{Owner: "o", Name: "r"}, PullRequestOptions{
+				Title:  "title",
+				Branch: "push-a",
+				Base:   "main",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 1, pr.GetNumber())
+
+			assert.Equal(t, 2, server.requests)
+			require.Len(t, *sleeps, 1)
+			if tc.ExpectedSleep != 0 {
+				assert.Equal(t, tc.ExpectedSleep, (*sleeps)[0])
+			}
+		})
+	}
+}
+
+func TestNoRetryWhenRateLimitResetIsTooFar(t *testing.T) {
+	server := &flakyServer{
+		failures: 1,
+		fail: func(w http.ResponseWriter) {
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(1_700_003_600))
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "API rate limit exceeded"}`)
+		},
+		succeed: writePullRequests,
+	}
+	client, sleeps := newRetryTestClient(t, server)
+
+	_, err := client.GetPullRequestsForBranches(context.Background(), Repo{Owner: "o", Name: "r"}, []string{"push-a"})
+	require.Error(t, err)
+
+	assert.Equal(t, 1, server.requests)
+	assert.Empty(t, *sleeps)
+
+	rateLimit, ok := client.RateLimit()
+	require.True(t, ok)
+	assert.Equal(t, 0, rateLimit.Remaining)
+	assert.Equal(t, 5000, rateLimit.Limit)
+}
+
+func TestNoRetryForPermissionErrors(t *testing.T) {
+	server := &flakyServer{
+		failures: 1,
+		fail: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "Resource not accessible by integration"}`)
+		},
+		succeed: writePullRequests,
+	}
+	client, sleeps := newRetryTestClient(t, server)
+
+	_, err := client.GetPullRequestsForBranches(context.Background(), Repo{Owner: "o", Name: "r"}, []string{"push-a"})
+	assert.ErrorContains(t, err, "Resource not accessible by integration")
+	assert.Equal(t, 1, server.requests)
+	assert.Empty(t, *sleeps)
+}
+
+func TestRetryGraphQLQueries(t *testing.T) {
+	server := &flakyServer{
+		failures: 1,
+		fail:     func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+		succeed: func(w http.ResponseWriter) {
+			w.Header().Set("X-RateLimit-Resource", "graphql")
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "120")
+			fmt.Fprint(w, `{"data": {"rateLimit": {"limit": 5000, "remaining": 120}, "repository": {"h0": {"nodes": []}}}}`)
+		},
+	}
+	client, sleeps := newRetryTestClient(t, server)
+
+	_, err := client.LoadStackState(context.Background(), Repo{Owner: "o", Name: "r"}, []string{"push-a"}, "marker")
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, server.requests)
+	assert.Len(t, *sleeps, 1)
+
+	rateLimit, ok := client.RateLimit()
+	require.True(t, ok)
+	assert.Equal(t, 120, rateLimit.Remaining)
+}
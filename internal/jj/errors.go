@@ -0,0 +1,157 @@
+package jj
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorKind classifies why a jj command failed.
+type ErrorKind int
+
+const (
+	ErrUnknown         ErrorKind = iota
+	ErrAuth                      // The Git remote rejected our credentials
+	ErrNonFastForward            // The remote branch moved since we last fetched
+	ErrImmutable                 // The command would rewrite an immutable revision
+	ErrUnknownRevset             // The revset doesn't parse or names a missing revision
+	ErrMissingBookmark           // A bookmark named in the command doesn't exist
+)
+
+// errorPatterns maps stderr substrings to the kind of failure they indicate.
+// Patterns are matched case-insensitively, in order, so more specific
+// patterns come first.
+var errorPatterns = []struct {
+	kind     ErrorKind
+	patterns []string
+}{
+	{ErrAuth, []string{
+		"authentication failed",
+		"authentication required",
+		// Only SSH and GitHub's permission errors, not local file ones.
+		"permission denied (publickey",
+		"remote: permission to",
+		"could not read username",
+		"invalid username or password",
+		"the requested url returned error: 403",
+	}},
+	{ErrNonFastForward, []string{
+		"non-fast-forward",
+		"unexpectedly moved on the remote",
+		"stale info",
+		"[rejected]",
+	}},
+	{ErrImmutable, []string{
+		"is immutable",
+		"immutable commit",
+	}},
+	{ErrMissingBookmark, []string{
+		"no such bookmark",
+		"no matching bookmarks",
+	}},
+	{ErrUnknownRevset, []string{
+		"failed to parse revset",
+		"doesn't exist",
+		"syntax error",
+	}},
+}
+
+// CommandError is returned when a jj command exits unsuccessfully.
+type CommandError struct {
+	Args     []string // Arguments jj was run with
+	ExitCode int      // Exit status, or -1 if jj could not be run
+	Stderr   string
+	Kind     ErrorKind
+	err      error
+}
+
+// newCommandError wraps the failure of `jj args...`, classifying it by stderr.
+func newCommandError(args []string, stderr []byte, err error) *CommandError {
+	exitCode := -1
+	var exit interface{ ExitCode() int }
+	if errors.As(err, &exit) {
+		exitCode = exit.ExitCode()
+	}
+
+	e := &CommandError{
+		Args:     args,
+		ExitCode: exitCode,
+		Stderr:   strings.TrimSpace(string(stderr)),
+		err:      err,
+	}
+
+	lower := strings.ToLower(e.Stderr)
+	for _, p := range errorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(lower, pattern) {
+				e.Kind = p.kind
+				return e
+			}
+		}
+	}
+
+	return e
+}
+
+// Error returns the subcommand and jj's error message. Flags are left out
+// since they include the long log template.
+func (e *CommandError) Error() string {
+	command := "jj"
+	for _, arg := range e.Args {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		command += " " + arg
+	}
+
+	if msg := e.message(); msg != "" {
+		return fmt.Sprintf("%s: %s", command, msg)
+	}
+	return fmt.Sprintf("%s: %v", command, e.err)
+}
+
+// Unwrap returns the underlying process error.
+func (e *CommandError) Unwrap() error {
+	return e.err
+}
+
+// message returns the lines of stderr that describe the error, without
+// jj's "Error: " prefix and trailing "Hint: " lines.
+func (e *CommandError) message() string {
+	var lines []string
+	for line := range strings.Lines(e.Stderr) {
+		line = strings.TrimRight(line, "\n")
+		if strings.HasPrefix(line, "Hint: ") {
+			break
+		}
+		lines = append(lines, strings.TrimPrefix(line, "Error: "))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// Hint returns a suggestion for fixing the failure, or "" if there is none.
+func (e *CommandError) Hint() string {
+	switch e.Kind {
+	case ErrAuth:
+		return "Check your Git credentials for the remote, e.g. with `gh auth status` or `ssh -T git@github.com`."
+	case ErrNonFastForward:
+		return "The remote branch changed since the last fetch. Run `jj git fetch`, check the stack, then try again."
+	case ErrImmutable:
+		return "The revision is immutable, likely because it is already in trunk. Run `jj github sync` to rebase the stack."
+	case ErrUnknownRevset:
+		return "Check the revset with `jj log -r <revset>`."
+	case ErrMissingBookmark:
+		return "Run `jj bookmark list --all` to see which bookmarks exist."
+	}
+	return ""
+}
+
+// Hint returns the remediation hint for the first CommandError in err's
+// tree, or "" if there is none.
+func Hint(err error) string {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Hint()
+	}
+	return ""
+}
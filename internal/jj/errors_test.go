@@ -0,0 +1,113 @@
+package jj
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e exitError) ExitCode() int { return int(e) }
+
+func TestCommandError(t *testing.T) {
+	for _, tc := range []struct {
+		Name            string
+		Args            []string
+		Stderr          string
+		ExpectedKind    ErrorKind
+		ExpectedMessage string
+	}{
+		{
+			Name:            "auth",
+			Args:            []string{"git", "push", "-c", "change_id(abc)"},
+			Stderr:          "Error: failed to authenticate SSH session: Permission denied (publickey)",
+			ExpectedKind:    ErrAuth,
+			ExpectedMessage: "jj git push: failed to authenticate SSH session: Permission denied (publickey)",
+		},
+		{
+			Name:            "https auth",
+			Args:            []string{"git", "push", "-c", "change_id(abc)"},
+			Stderr:          "Error: remote: Permission to owner/repo.git denied to someone.",
+			ExpectedKind:    ErrAuth,
+			ExpectedMessage: "jj git push: remote: Permission to owner/repo.git denied to someone.",
+		},
+		{
+			Name: "file permission",
+			Args: []string{"git", "push", "-c", "change_id(abc)"},
+			Stderr: "Error: Failed to snapshot the working copy\n" +
+				"Caused by: Failed to open file src/main.go: Permission denied (os error 13)",
+			ExpectedKind: ErrUnknown,
+			ExpectedMessage: "jj git push: Failed to snapshot the working copy\n" +
+				"Caused by: Failed to open file src/main.go: Permission denied (os error 13)",
+		},
+		{
+			Name: "non-fast-forward",
+			Args: []string{"git", "push", "-c", "change_id(abc)"},
+			Stderr: "Error: Failed to push some bookmarks\n" +
+				"Hint: The following references unexpectedly moved on the remote:\n" +
+				"  refs/heads/push-abc (reason: stale info)\n",
+			ExpectedKind:    ErrNonFastForward,
+			ExpectedMessage: "jj git push: Failed to push some bookmarks",
+		},
+		{
+			Name:            "immutable",
+			Args:            []string{"rebase", "-s", "abc", "-d", "trunk()"},
+			Stderr:          "Error: Commit 1234abcd is immutable\nHint: Could not modify commit: abc 1234abcd main | Initial commit",
+			ExpectedKind:    ErrImmutable,
+			ExpectedMessage: "jj rebase: Commit 1234abcd is immutable",
+		},
+		{
+			Name:            "unknown revset",
+			Args:            []string{"log", "--no-graph", "-T", "template", "-r", "nope"},
+			Stderr:          "Error: Revision `nope` doesn't exist",
+			ExpectedKind:    ErrUnknownRevset,
+			ExpectedMessage: "jj log: Revision `nope` doesn't exist",
+		},
+		{
+			Name:            "missing bookmark",
+			Args:            []string{"git", "fetch", "--branch", "push-abc"},
+			Stderr:          "Warning: No matching bookmarks for names: push-abc",
+			ExpectedKind:    ErrMissingBookmark,
+			ExpectedMessage: "jj git fetch: Warning: No matching bookmarks for names: push-abc",
+		},
+		{
+			Name:            "unknown",
+			Args:            []string{"git", "fetch"},
+			Stderr:          "Error: something unexpected happened",
+			ExpectedKind:    ErrUnknown,
+			ExpectedMessage: "jj git fetch: something unexpected happened",
+		},
+		{
+			Name:            "no stderr",
+			Args:            []string{"root"},
+			ExpectedKind:    ErrUnknown,
+			ExpectedMessage: "jj root: exit status 1",
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := newCommandError(tc.Args, []byte(tc.Stderr), exitError(1))
+			assert.Equal(t, tc.ExpectedKind, err.Kind)
+			assert.Equal(t, tc.ExpectedMessage, err.Error())
+			assert.Equal(t, 1, err.ExitCode)
+
+			if tc.ExpectedKind == ErrUnknown {
+				assert.Empty(t, err.Hint())
+			} else {
+				assert.NotEmpty(t, err.Hint())
+			}
+		})
+	}
+}
+
+func TestHint(t *testing.T) {
+	cmdErr := newCommandError([]string{"git", "push"}, []byte("Error: Permission denied (publickey)"), errors.New("exec: not started"))
+	assert.Equal(t, -1, cmdErr.ExitCode)
+
+	wrapped := errors.Join(errors.New("other"), fmt.Errorf("push: %w", cmdErr))
+	assert.Equal(t, cmdErr.Hint(), Hint(wrapped))
+	assert.Empty(t, Hint(errors.New("other")))
+}
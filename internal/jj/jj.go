@@ -5,35 +5,92 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os/exec"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 const (
-	logTemplate = `"{\"id\": \"" ++ change_id ++ "\", \"short_id\": \"" ++ change_id.shortest() ++ "\", \"commit_id\": \"" ++ commit_id ++ "\", \"immutable\": " ++ immutable ++ ", \"description\": " ++ json(description) ++ ", \"bookmarks\": " ++ json(bookmarks) ++ ", \"git_push_bookmark\": \"" ++ %s ++ "\", \"parents\": " ++ json(parents) ++ "}"`
+	logTemplate = `"{\"id\": \"" ++ change_id ++ "\", \"short_id\": \"" ++ change_id.shortest() ++ "\", \"commit_id\": \"" ++ commit_id ++ "\", \"immutable\": " ++ immutable ++ ", \"conflict\": " ++ conflict ++ ", \"empty\": " ++ empty ++ ", \"description\": " ++ json(description) ++ ", \"bookmarks\": " ++ json(bookmarks) ++ ", \"git_push_bookmark\": \"" ++ %s ++ "\", \"parents\": " ++ json(parents) ++ "}"`
 )
 
+// Repo is a Jujutsu repository. It is implemented by *Client and can be
+// replaced in tests by a Client backed by a fake Runner.
+type Repo interface {
+	GetChanges(revsets ...string) ([]Change, error)
+	GetTemplate(name string) (string, error)
+	GetRemote(name string) (string, error)
+	GetRepoPath() (string, error)
+	GitPush(changeID string) error
+	PushBranch(branch, changeID string) error
+	HasRemoteBookmark(branch string) (bool, error)
+	GitFetch() error
+	GitFetchBranches(branches []string) error
+	GetStackUpstreams() (map[string][]string, error)
+	GetStackRootsToRebase(onto string, upstreams map[string][]string) ([]Bookmark, error)
+	GetStackRoots(revset string) ([]Change, error)
+	RebaseStacks(roots []string, destination string) (map[string]RebaseResult, error)
+	GetTrunkName() (string, error)
+	ConflictedFiles(revision string) ([]string, error)
+	Edit(revision string) error
+}
+
+// Client runs jj commands against a repository.
+type Client struct {
+	runner Runner
+}
+
+var _ Repo = (*Client)(nil)
+
+// NewClient creates a client that runs jj commands with runner.
+func NewClient(runner Runner) *Client {
+	return &Client{runner: runner}
+}
+
+// run runs jj with args, returning a *CommandError if it fails.
+func (c *Client) run(args ...string) (stdout []byte, stderr []byte, err error) {
+	stdout, stderr, err = c.runner.Run(args...)
+	if err != nil {
+		return stdout, stderr, newCommandError(args, stderr, err)
+	}
+	return stdout, stderr, nil
+}
+
 // Change represents a Jujutsu revision with its metadata.
 type Change struct {
-	ID              string `json:"id"`
-	ShortID         string `json:"short_id"`
-	CommitID        string `json:"commit_id"`
-	Immutable       bool   `json:"immutable"`
-	GitPushBookmark string `json:"git_push_bookmark"`
-	Description     string `json:"description"`
-	Bookmarks       []struct {
-		Name string `json:"name"`
-	} `json:"bookmarks"`
-	Parents []struct {
-		ChangeID string `json:"change_id"`
-		CommitID string `json:"commit_id"`
-	} `json:"parents"`
+	ID              string        `json:"id"`
+	ShortID         string        `json:"short_id"`
+	CommitID        string        `json:"commit_id"`
+	Immutable       bool          `json:"immutable"`
+	Conflict        bool          `json:"conflict"`
+	Empty           bool          `json:"empty"`
+	GitPushBookmark string        `json:"git_push_bookmark"`
+	Description     string        `json:"description"`
+	Bookmarks       []BookmarkRef `json:"bookmarks"`
+	Parents         []Parent      `json:"parents"`
+}
+
+// BookmarkRef is a bookmark pointing at a change.
+type BookmarkRef struct {
+	Name string `json:"name"`
+}
+
+// Parent identifies a parent of a change.
+type Parent struct {
+	ChangeID string `json:"change_id"`
+	CommitID string `json:"commit_id"`
 }
 
 // GetChanges returns changes matching the given revsets in topological order.
-func GetChanges(revsets ...string) ([]Change, error) {
-	gitPushBookmark, err := GetTemplate("git_push_bookmark")
-	if err != nil {
+func (c *Client) GetChanges(revsets ...string) ([]Change, error) {
+	gitPushBookmark, err := c.GetTemplate("git_push_bookmark")
+	if errors.Is(err, ErrNotSet) {
+		// Not every jj reports its built-in default through `config get`.
+		gitPushBookmark = DefaultGitPushBookmark
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -48,12 +105,8 @@ func GetChanges(revsets ...string) ([]Change, error) {
 		args = append(args, "-r", revset)
 	}
 
-	out, err := exec.Command("jj", args...).Output()
+	out, _, err := c.run(args...)
 	if err != nil {
-		var ee *exec.ExitError
-		if errors.As(err, &ee) {
-			print(string(ee.Stderr))
-		}
 		return nil, err
 	}
 
@@ -71,21 +124,42 @@ func GetChanges(revsets ...string) ([]Change, error) {
 	return changes, nil
 }
 
+// ErrNotSet is returned by GetTemplate when the template isn't configured.
+var ErrNotSet = errors.New("not set")
+
 // GetTemplate returns a Jujutsu template value from the user's config.
-func GetTemplate(name string) (string, error) {
-	output, err := exec.Command("jj", "config", "get", "templates."+name).Output()
+// Returns an error wrapping ErrNotSet if it isn't set.
+func (c *Client) GetTemplate(name string) (string, error) {
+	output, stderr, err := c.run("config", "get", "templates."+name)
 	if err != nil {
+		if strings.Contains(string(stderr), "not found") {
+			return "", fmt.Errorf("get template %q: %w", name, ErrNotSet)
+		}
 		return "", fmt.Errorf("get template %q: %w", name, err)
 	}
 
 	return strings.TrimSpace(string(output)), nil
 }
 
+// GetConfig returns a value from the user's or repository's jj config, e.g.
+// "jj-github.sync-revset". Returns "" if it isn't set.
+func (c *Client) GetConfig(name string) (string, error) {
+	output, stderr, err := c.run("config", "get", name)
+	if err != nil {
+		if strings.Contains(string(stderr), "not found") {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
 // GetRemote returns the URL for the named Git remote.
-func GetRemote(name string) (string, error) {
-	output, err := exec.Command("jj", "git", "remote", "list").Output()
+func (c *Client) GetRemote(name string) (string, error) {
+	output, _, err := c.run("git", "remote", "list")
 	if err != nil {
-		return "", fmt.Errorf("jj git remote list: %w", err)
+		return "", err
 	}
 
 	for line := range strings.Lines(string(output)) {
@@ -107,19 +181,94 @@ func GetRemote(name string) (string, error) {
 	return "", fmt.Errorf("remote named %q not found", name)
 }
 
+// GetRepoPath returns the path to the repository's `.jj/repo` directory.
+// In secondary workspaces `.jj/repo` is a file containing the path to the
+// main workspace's repo directory, which is resolved here.
+func (c *Client) GetRepoPath() (string, error) {
+	output, _, err := c.run("root")
+	if err != nil {
+		return "", err
+	}
+
+	jjDir := filepath.Join(strings.TrimSpace(string(output)), ".jj")
+	repoPath := filepath.Join(jjDir, "repo")
+
+	info, err := os.Stat(repoPath)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return repoPath, nil
+	}
+
+	target, err := os.ReadFile(repoPath)
+	if err != nil {
+		return "", err
+	}
+	resolved := strings.TrimSpace(string(target))
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(jjDir, resolved)
+	}
+	return resolved, nil
+}
+
 // GitPush pushes the specified change to its Git branch.
-func GitPush(changeID string) error {
-	return exec.Command("jj", "git", "push", "-c", fmt.Sprintf("change_id(%s)", changeID)).Run()
+func (c *Client) GitPush(changeID string) error {
+	_, _, err := c.run("git", "push", "-c", fmt.Sprintf("change_id(%s)", changeID))
+	return err
+}
+
+// PushBranch moves the bookmark branch to changeID and pushes it. Unlike
+// GitPush, the branch can have any name, e.g. one adopted from a pull request
+// opened outside jj-github.
+func (c *Client) PushBranch(branch, changeID string) error {
+	if _, _, err := c.run("bookmark", "set", branch, "-r", fmt.Sprintf("change_id(%s)", changeID), "--allow-backwards"); err != nil {
+		return err
+	}
+	_, _, err := c.run("git", "push", "-b", branch)
+	return err
+}
+
+// HasRemoteBookmark returns true if branch exists on origin, as of the last
+// fetch or push.
+func (c *Client) HasRemoteBookmark(branch string) (bool, error) {
+	revset := fmt.Sprintf(`remote_bookmarks(exact:%s, remote=exact:"origin")`, strconv.Quote(branch))
+	out, _, err := c.run("log", "--no-graph", "-r", revset, "-T", `commit_id ++ "\n"`)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) != "", nil
+}
+
+// CurrentOperation returns the ID of the repository's latest operation.
+func (c *Client) CurrentOperation() (string, error) {
+	out, _, err := c.run("operation", "log", "--no-graph", "--limit", "1", "-T", "id")
+	if err != nil {
+		return "", err
+	}
+
+	id := strings.TrimSpace(string(out))
+	if id == "" {
+		return "", fmt.Errorf("jj operation log returned no operation")
+	}
+	return id, nil
+}
+
+// RestoreOperation restores the repository to the state at operation id.
+func (c *Client) RestoreOperation(id string) error {
+	_, _, err := c.run("operation", "restore", id)
+	return err
 }
 
 // GitFetch fetches from the Git remote to get the latest state.
-func GitFetch() error {
-	return exec.Command("jj", "git", "fetch").Run()
+func (c *Client) GitFetch() error {
+	_, _, err := c.run("git", "fetch")
+	return err
 }
 
 // GitFetchBranches fetches only the specified branches from the Git remote.
 // This is useful when you want to avoid fetching trunk or other branches.
-func GitFetchBranches(branches []string) error {
+func (c *Client) GitFetchBranches(branches []string) error {
 	if len(branches) == 0 {
 		return nil
 	}
@@ -129,7 +278,31 @@ func GitFetchBranches(branches []string) error {
 		args = append(args, "--branch", branch)
 	}
 
-	return exec.Command("jj", args...).Run()
+	_, _, err := c.run(args...)
+	return err
+}
+
+// TrackBookmarks starts tracking the remote bookmarks for branches on origin,
+// creating local bookmarks for them. Bookmarks that are already tracked are
+// left as they are.
+func (c *Client) TrackBookmarks(branches []string) error {
+	if len(branches) == 0 {
+		return nil
+	}
+
+	args := []string{"bookmark", "track"}
+	for _, branch := range branches {
+		args = append(args, branch+"@origin")
+	}
+
+	_, _, err := c.run(args...)
+	return err
+}
+
+// New creates an empty revision on top of revision and makes it the working copy.
+func (c *Client) New(revision string) error {
+	_, _, err := c.run("new", revision)
+	return err
 }
 
 // Bookmark represents a jj bookmark with its associated revision.
@@ -139,38 +312,85 @@ type Bookmark struct {
 	ShortID     string `json:"short_id"`
 	CommitID    string `json:"commit_id"`
 	Description string `json:"description"`
+
+	// Destination is the revset the stack is rebased onto, and
+	// DestinationName how it is shown, e.g. "release-2.3".
+	Destination     string `json:"destination"`
+	DestinationName string `json:"destination_name"`
 }
 
-// GetStackRootsToRebase returns the root revisions of stacks that need rebasing onto trunk.
+// GetStackRootsToRebase returns the root revisions of stacks that need rebasing.
 // A "root" is a mutable revision whose parent is immutable (i.e., on or derived from trunk).
 // This handles the case where a PR was squash-merged into trunk - the original commits
 // are no longer direct children of trunk but still need to be rebased (and will become
 // empty, which --skip-emptied will handle).
 //
-// Only returns roots that are NOT already parented on the current trunk commit.
-func GetStackRootsToRebase() ([]Bookmark, error) {
+// Each stack is rebased onto onto if it is given. Otherwise, stacks based on
+// trunk are rebased onto trunk, and other stacks onto the updated position of
+// the remote bookmark they were based on, e.g. a release branch. upstreams is
+// the result of GetStackUpstreams from before the fetch. See
+// stackDestinations.
+//
+// Only returns roots that are NOT already parented on their destination.
+func (c *Client) GetStackRootsToRebase(onto string, upstreams map[string][]string) ([]Bookmark, error) {
 	// Get the current trunk commit ID to check if roots are already parented on it
-	trunkChanges, err := GetChanges("trunk()")
+	trunkChanges, err := c.GetChanges("trunk()")
 	if err != nil {
 		return nil, fmt.Errorf("get trunk: %w", err)
 	}
 	if len(trunkChanges) == 0 {
 		return nil, fmt.Errorf("trunk not found")
 	}
-	trunkCommitID := trunkChanges[0].CommitID
+	trunkName := "trunk()"
+	if len(trunkChanges[0].Bookmarks) > 0 {
+		trunkName = trunkChanges[0].Bookmarks[0].Name
+	}
 
 	// Find "roots" - mutable revisions whose parent is immutable.
 	// The revset "roots(mutable())" gives us all mutable revisions that have no mutable ancestors.
 	// These are the starting points of all working stacks.
-	changes, err := GetChanges("roots(mutable())")
+	changes, err := c.GetChanges("roots(mutable())")
 	if err != nil {
 		return nil, fmt.Errorf("get stack roots: %w", err)
 	}
 
+	var destinations map[string]destination
+	if onto == "" {
+		destinations, err = c.stackDestinations(changes, trunkName, upstreams)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Destination revsets resolved to commit IDs
+	targets := map[string]string{"trunk()": trunkChanges[0].CommitID}
+
 	var bookmarks []Bookmark
 	for _, change := range changes {
-		// Skip if already parented on current trunk (no rebase needed)
-		if len(change.Parents) > 0 && change.Parents[0].CommitID == trunkCommitID {
+		if len(change.Parents) == 0 {
+			continue
+		}
+
+		dest := destination{revset: onto, name: onto}
+		if onto == "" {
+			dest = destinations[change.Parents[0].CommitID]
+		}
+
+		target, ok := targets[dest.revset]
+		if !ok {
+			resolved, err := c.GetChanges(dest.revset)
+			if err != nil {
+				return nil, fmt.Errorf("resolve destination %q: %w", dest.revset, err)
+			}
+			if len(resolved) != 1 {
+				return nil, fmt.Errorf("destination %q resolves to %d revisions, expected 1", dest.revset, len(resolved))
+			}
+			target = resolved[0].CommitID
+			targets[dest.revset] = target
+		}
+
+		// Skip if already parented on the destination (no rebase needed)
+		if change.Parents[0].CommitID == target {
 			continue
 		}
 
@@ -185,58 +405,291 @@ func GetStackRootsToRebase() ([]Bookmark, error) {
 		}
 
 		bookmarks = append(bookmarks, Bookmark{
-			Name:        bookmarkName,
-			ChangeID:    change.ID,
-			ShortID:     change.ShortID,
-			CommitID:    change.CommitID,
-			Description: change.Description,
+			Name:            bookmarkName,
+			ChangeID:        change.ID,
+			ShortID:         change.ShortID,
+			CommitID:        change.CommitID,
+			Description:     change.Description,
+			Destination:     dest.revset,
+			DestinationName: dest.name,
 		})
 	}
 
 	return bookmarks, nil
 }
 
+// destination is where a stack is rebased onto.
+type destination struct {
+	revset string
+	name   string // Shown to the user, e.g. "main" or "release-2.3"
+}
+
+// GetStackUpstreams returns the names of the bookmarks on origin pointing at
+// the parent of each stack root, keyed by the parent's commit ID. Called
+// before fetching, these are the branches the stacks were based on.
+func (c *Client) GetStackUpstreams() (map[string][]string, error) {
+	out, _, err := c.run("log", "--no-graph", "-r", "parents(roots(mutable()))",
+		"-T", `commit_id ++ remote_bookmarks.map(|b| if(b.remote() == "origin", " " ++ b.name())).join("") ++ "\n"`)
+	if err != nil {
+		return nil, fmt.Errorf("find stack upstreams: %w", err)
+	}
+
+	upstreams := make(map[string][]string)
+	for line := range strings.Lines(string(out)) {
+		fields := strings.Fields(line)
+		if len(fields) > 1 {
+			upstreams[fields[0]] = fields[1:]
+		}
+	}
+	return upstreams, nil
+}
+
+// stackDestinations works out where each of the stack roots should be
+// rebased onto, keyed by the commit ID of the root's parent. Stacks whose
+// parent is in trunk go onto trunk. Other stacks go onto the branch in
+// upstreams that pointed at their parent, which may have moved since. Without
+// one, they go onto the closest remote bookmark descending from their parent.
+// Stacks whose branch can't be found go onto trunk.
+func (c *Client) stackDestinations(
+	roots []Change,
+	trunkName string,
+	upstreams map[string][]string,
+) (map[string]destination, error) {
+	trunk := destination{revset: "trunk()", name: trunkName}
+	destinations := make(map[string]destination)
+
+	var parents, rootIDs []string
+	for _, root := range roots {
+		if len(root.Parents) == 0 {
+			continue
+		}
+		rootIDs = append(rootIDs, root.ID)
+		parent := root.Parents[0].CommitID
+		if _, ok := destinations[parent]; !ok {
+			destinations[parent] = trunk
+			parents = append(parents, parent)
+		}
+	}
+	if len(parents) == 0 {
+		return destinations, nil
+	}
+
+	inTrunk, err := c.GetChanges(fmt.Sprintf("(%s) & ::trunk()", strings.Join(parents, " | ")))
+	if err != nil {
+		return nil, fmt.Errorf("find stacks based on trunk: %w", err)
+	}
+	onTrunk := make(map[string]bool, len(inTrunk))
+	for _, change := range inTrunk {
+		onTrunk[change.CommitID] = true
+	}
+
+	for _, parent := range parents {
+		if onTrunk[parent] {
+			continue
+		}
+
+		// Descendants are listed closest first. The stacks themselves are
+		// excluded, since their own branches have been pushed too.
+		upstream, err := c.originBookmarks(fmt.Sprintf(`%s:: & remote_bookmarks(remote=exact:"origin") ~ (%s)::`,
+			parent, strings.Join(rootIDs, " | ")))
+		if err != nil {
+			return nil, fmt.Errorf("find upstream of %s: %w", parent, err)
+		}
+		if len(upstream) == 0 {
+			continue
+		}
+
+		name := upstream[0]
+		if recorded := upstreams[parent]; len(recorded) > 0 {
+			// A teammate's branch based on the same commit may be closer
+			// than the one the stack was based on.
+			i := slices.IndexFunc(upstream, func(b string) bool {
+				return slices.Contains(recorded, b)
+			})
+			if i < 0 {
+				continue
+			}
+			name = upstream[i]
+		}
+		destinations[parent] = destination{revset: strconv.Quote(name) + "@origin", name: name}
+	}
+
+	return destinations, nil
+}
+
+// originBookmarks returns the names of the bookmarks on origin pointing at
+// revisions in revset, closest to the root first.
+func (c *Client) originBookmarks(revset string) ([]string, error) {
+	out, _, err := c.run("log", "--no-graph", "--reversed", "-r", revset,
+		"-T", `remote_bookmarks.map(|b| if(b.remote() == "origin", b.name() ++ "\n")).join("")`)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// GetStackRoots returns the roots of the stacks containing any revision in
+// revset, i.e. the mutable revisions with an immutable parent that are
+// ancestors of revset.
+func (c *Client) GetStackRoots(revset string) ([]Change, error) {
+	changes, err := c.GetChanges(fmt.Sprintf("roots(mutable() & ::(%s))", revset))
+	if err != nil {
+		return nil, fmt.Errorf("get stack roots for %q: %w", revset, err)
+	}
+	return changes, nil
+}
+
+// conflictDescription matches the description `jj resolve --list` prints
+// after each path, e.g. "2-sided conflict including 1 deletion".
+var conflictDescription = regexp.MustCompile(`\s+\d+-sided conflict.*$`)
+
+// ConflictedFiles returns the paths of the conflicted files in revision,
+// relative to the current directory. Returns nil if it has no conflicts.
+func (c *Client) ConflictedFiles(revision string) ([]string, error) {
+	out, stderr, err := c.run("resolve", "--list", "-r", revision)
+	if err != nil {
+		if strings.Contains(string(stderr), "No conflicts found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list conflicts in %s: %w", revision, err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if path := strings.TrimSpace(conflictDescription.ReplaceAllString(line, "")); path != "" {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// Edit makes revision the working-copy revision.
+func (c *Client) Edit(revision string) error {
+	_, _, err := c.run("edit", revision)
+	return err
+}
+
 // RebaseResult contains the result of a rebase operation.
 type RebaseResult struct {
-	HasConflict  bool
-	SkippedEmpty bool
-}
-
-// Rebase rebases a source revision and its descendants onto a destination.
-// Uses `jj rebase -s <source> -d <destination> --skip-emptied` to rebase the entire subtree.
-// The --skip-emptied flag automatically abandons commits that become empty after rebasing,
-// which handles the case where a PR was squash-merged into trunk.
-// Returns whether the rebase resulted in conflicts or skipped empty commits.
-// jj treats conflicts as first-class, so we continue even if there's a conflict.
-func Rebase(source, destination string) (RebaseResult, error) {
-	cmd := exec.Command("jj", "rebase", "-s", source, "-d", destination, "--skip-emptied")
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
-
-	// Check for conflicts and skipped commits in output
-	hasConflict := strings.Contains(outputStr, "conflict")
-	skippedEmpty := strings.Contains(outputStr, "Skipped rebase of") || strings.Contains(outputStr, "became empty")
-
-	if err != nil {
-		var ee *exec.ExitError
-		if errors.As(err, &ee) {
-			// jj rebase can exit with error but still complete with conflicts
-			// We consider it a success if there's conflict markers in output
-			if hasConflict || skippedEmpty {
-				return RebaseResult{HasConflict: hasConflict, SkippedEmpty: skippedEmpty}, nil
+	// Conflicted lists the rebased revisions the rebase left with conflicts,
+	// bottom first.
+	Conflicted []Change
+	// AlreadyConflicted lists the rebased revisions that had conflicts before
+	// the rebase and still have them, bottom first.
+	AlreadyConflicted []Change
+	// Abandoned lists the revisions that became empty and were abandoned, bottom first.
+	Abandoned []Change
+	// Rebased is the number of revisions that still exist after the rebase.
+	Rebased int
+}
+
+// RebaseStacks rebases the stacks rooted at roots, given as change IDs, onto
+// destination in a single operation. Uses
+// `jj rebase -s <root> -s <root>... -d <destination> --skip-emptied` to rebase
+// each root's entire subtree. The --skip-emptied flag automatically abandons
+// commits that become empty after rebasing, which handles the case where a PR
+// was squash-merged into trunk.
+//
+// jj treats conflicts as first-class, so a rebase that leaves conflicts
+// succeeds. Which revisions were abandoned or conflicted, and whether they were
+// conflicted before, is found by listing
+// the subtrees before rebasing and looking their change IDs up again
+// afterwards. Results are keyed by root; a revision descending from several
+// roots is attributed to the first one.
+func (c *Client) RebaseStacks(roots []string, destination string) (map[string]RebaseResult, error) {
+	results := make(map[string]RebaseResult, len(roots))
+	if len(roots) == 0 {
+		return results, nil
+	}
+
+	subtrees := make([]string, len(roots))
+	args := []string{"rebase"}
+	for i, root := range roots {
+		subtrees[i] = root + "::"
+		args = append(args, "-s", root)
+	}
+	args = append(args, "-d", destination, "--skip-emptied")
+
+	// Changes are listed parents first, so each is attributed to the root
+	// of its first parent that has been seen.
+	before, err := c.GetChanges(strings.Join(subtrees, " | "))
+	if err != nil {
+		return nil, err
+	}
+	rootOf := make(map[string]string, len(before))
+	for _, root := range roots {
+		rootOf[root] = root
+	}
+	for _, change := range before {
+		if _, ok := rootOf[change.ID]; ok {
+			continue
+		}
+		for _, parent := range change.Parents {
+			if root, ok := rootOf[parent.ChangeID]; ok {
+				rootOf[change.ID] = root
+				break
 			}
-			return RebaseResult{}, fmt.Errorf("rebase: %s", outputStr)
 		}
-		return RebaseResult{}, err
 	}
 
-	return RebaseResult{HasConflict: hasConflict, SkippedEmpty: skippedEmpty}, nil
+	if _, _, err := c.run(args...); err != nil {
+		return nil, err
+	}
+
+	for _, root := range roots {
+		results[root] = RebaseResult{}
+	}
+	if len(before) == 0 {
+		return results, nil
+	}
+
+	// present() keeps abandoned change IDs from failing the whole revset.
+	revsets := make([]string, len(before))
+	for i, change := range before {
+		revsets[i] = fmt.Sprintf("present(%s)", change.ID)
+	}
+	after, err := c.GetChanges(strings.Join(revsets, " | "))
+	if err != nil {
+		return nil, err
+	}
+
+	conflictedBefore := make(map[string]bool)
+	for _, change := range before {
+		if change.Conflict {
+			conflictedBefore[change.ID] = true
+		}
+	}
+
+	exists := make(map[string]bool, len(after))
+	for _, change := range after {
+		exists[change.ID] = true
+		root := rootOf[change.ID]
+		result := results[root]
+		result.Rebased++
+		switch {
+		case change.Conflict && conflictedBefore[change.ID]:
+			result.AlreadyConflicted = append(result.AlreadyConflicted, change)
+		case change.Conflict:
+			result.Conflicted = append(result.Conflicted, change)
+		}
+		results[root] = result
+	}
+	for _, change := range before {
+		if !exists[change.ID] {
+			root := rootOf[change.ID]
+			result := results[root]
+			result.Abandoned = append(result.Abandoned, change)
+			results[root] = result
+		}
+	}
+
+	return results, nil
 }
 
 // GetTrunkName returns the name of the trunk bookmark (e.g., "main" or "master").
-func GetTrunkName() (string, error) {
+func (c *Client) GetTrunkName() (string, error) {
 	// Get the trunk revision and its bookmarks
-	changes, err := GetChanges("trunk()")
+	changes, err := c.GetChanges("trunk()")
 	if err != nil {
 		return "", err
 	}
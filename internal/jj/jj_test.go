@@ -0,0 +1,204 @@
+package jj_test
+
+import (
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetConfig(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("config", "get", "jj-github.sync-revset").Return("mine()\n")
+
+	value, err := repo.GetConfig("jj-github.sync-revset")
+	require.NoError(t, err)
+	assert.Equal(t, "mine()", value)
+
+	runner.On("config", "get", "jj-github.sync-revset").Fail("Config error: Value not found for jj-github.sync-revset")
+	value, err = repo.GetConfig("jj-github.sync-revset")
+	require.NoError(t, err)
+	assert.Empty(t, value)
+
+	runner.On("config", "get", "jj-github.sync-revset").Fail("Error: There is no jj repo in \".\"")
+	_, err = repo.GetConfig("jj-github.sync-revset")
+	assert.Error(t, err)
+}
+
+func TestGetStackRoots(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("log", "-r", "roots(mutable() & ::(mine() | @))").ReturnChanges(jjtest.Change("aaaaaaaa", "A", "trunk"))
+
+	roots, err := repo.GetStackRoots("mine() | @")
+	require.NoError(t, err)
+	require.Len(t, roots, 1)
+	assert.Equal(t, "aaaaaaaa", roots[0].ID)
+}
+
+func TestRebaseStacks(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+
+	a := jjtest.Change("aaaaaaaa", "A", "trunk")
+	a2 := jjtest.Change("aaaaaaa2", "A2", "aaaaaaaa")
+	b := jjtest.Change("bbbbbbbb", "B", "trunk")
+	b2 := jjtest.Change("bbbbbbb2", "B2", "bbbbbbbb")
+	b2.Conflict = true
+	// A merge of both stacks is attributed to the stack of its first parent.
+	merge := jjtest.Change("mmmmmmmm", "Merge", "bbbbbbbb")
+	merge.Parents = append(merge.Parents, jj.Parent{ChangeID: "aaaaaaa2"})
+
+	conflictedA2 := a2
+	conflictedA2.Conflict = true
+	runner.On("log", "-r", "aaaaaaaa:: | bbbbbbbb::").ReturnChanges(a, b, a2, b2, merge)
+	runner.On("log", "-r", "present(aaaaaaaa) | present(bbbbbbbb) | present(aaaaaaa2) | present(bbbbbbb2) | present(mmmmmmmm)").
+		ReturnChanges(conflictedA2, b2, merge)
+	runner.On("rebase")
+
+	results, err := repo.RebaseStacks([]string{"aaaaaaaa", "bbbbbbbb"}, "trunk()")
+	require.NoError(t, err)
+	assert.Equal(t, 1, runner.Called("rebase", "-s", "aaaaaaaa", "-s", "bbbbbbbb", "-d", "trunk()", "--skip-emptied"))
+
+	assert.Equal(t, 1, results["aaaaaaaa"].Rebased)
+	assert.Equal(t, []string{"aaaaaaa2"}, ids(results["aaaaaaaa"].Conflicted))
+	assert.Empty(t, results["aaaaaaaa"].AlreadyConflicted)
+	assert.Equal(t, []string{"aaaaaaaa"}, ids(results["aaaaaaaa"].Abandoned))
+
+	// B2 was conflicted before the rebase, so the rebase didn't cause it.
+	assert.Equal(t, 2, results["bbbbbbbb"].Rebased)
+	assert.Empty(t, results["bbbbbbbb"].Conflicted)
+	assert.Equal(t, []string{"bbbbbbb2"}, ids(results["bbbbbbbb"].AlreadyConflicted))
+	assert.Equal(t, []string{"bbbbbbbb"}, ids(results["bbbbbbbb"].Abandoned))
+}
+
+func ids(changes []jj.Change) []string {
+	var result []string
+	for _, change := range changes {
+		result = append(result, change.ID)
+	}
+	return result
+}
+
+func TestRebaseStacksFails(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("log", "-r", "aaaaaaaa::").ReturnChanges(jjtest.Change("aaaaaaaa", "A", "trunk"))
+	runner.On("rebase").Fail("Error: Commit 1234abcd is immutable")
+
+	_, err := repo.RebaseStacks([]string{"aaaaaaaa"}, "trunk()")
+	assert.ErrorContains(t, err, "is immutable")
+}
+
+func TestGetStackRootsToRebase(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+
+	onTrunk := jjtest.Change("aaaaaaaa", "A", "oldtrunk")
+	onRelease := jjtest.Change("bbbbbbbb", "B", "oldrelease")
+	upToDate := jjtest.Change("cccccccc", "C", "newtrunk")
+	orphaned := jjtest.Change("dddddddd", "D", "gone")
+	onStaging := jjtest.Change("eeeeeeee", "E", "oldstaging")
+	release := jjtest.Change("newrelease", "", "")
+	staging := jjtest.Change("newstaging", "", "")
+
+	runner.On("log", "-r", "trunk()").ReturnChanges(jjtest.Trunk("newtrunk", "main"))
+	runner.On("log", "-r", "roots(mutable())").ReturnChanges(onTrunk, onRelease, upToDate, orphaned, onStaging)
+	runner.On("log", "-r", "(commit-oldtrunk | commit-oldrelease | commit-newtrunk | commit-gone | commit-oldstaging) & ::trunk()").
+		ReturnChanges(jjtest.Change("oldtrunk", "", ""), jjtest.Change("newtrunk", "", ""))
+	stacks := "(aaaaaaaa | bbbbbbbb | cccccccc | dddddddd | eeeeeeee)::"
+	runner.On("log", "-r", `commit-oldrelease:: & remote_bookmarks(remote=exact:"origin") ~ `+stacks).Return("release-2.3\nrelease-2.3-hotfix\n")
+	runner.On("log", "-r", `commit-gone:: & remote_bookmarks(remote=exact:"origin") ~ `+stacks)
+	// A teammate's branch based on the old staging commit is closer than
+	// staging itself.
+	runner.On("log", "-r", `commit-oldstaging:: & remote_bookmarks(remote=exact:"origin") ~ `+stacks).Return("push-xyz\nstaging\n")
+	runner.On("log", "-r", `"release-2.3"@origin`).ReturnChanges(release)
+	runner.On("log", "-r", `"staging"@origin`).ReturnChanges(staging)
+
+	roots, err := repo.GetStackRootsToRebase("", map[string][]string{"commit-oldstaging": {"staging"}})
+	require.NoError(t, err)
+	require.Len(t, roots, 4, "stacks already on their destination are skipped")
+
+	assert.Equal(t, "aaaaaaaa", roots[0].ChangeID)
+	assert.Equal(t, "trunk()", roots[0].Destination)
+	assert.Equal(t, "main", roots[0].DestinationName)
+
+	assert.Equal(t, "bbbbbbbb", roots[1].ChangeID)
+	assert.Equal(t, `"release-2.3"@origin`, roots[1].Destination, "without a recorded branch, the closest one is used")
+	assert.Equal(t, "release-2.3", roots[1].DestinationName)
+
+	assert.Equal(t, "dddddddd", roots[2].ChangeID)
+	assert.Equal(t, "trunk()", roots[2].Destination, "stacks whose branch is gone go onto trunk")
+
+	assert.Equal(t, "eeeeeeee", roots[3].ChangeID)
+	assert.Equal(t, `"staging"@origin`, roots[3].Destination, "the branch the stack was based on is used")
+	assert.Equal(t, "staging", roots[3].DestinationName)
+}
+
+func TestGetStackUpstreams(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("log", "-r", "parents(roots(mutable()))").Return("commit-oldtrunk main\ncommit-oldstaging staging push-xyz\ncommit-local\n")
+
+	upstreams, err := repo.GetStackUpstreams()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"commit-oldtrunk":   {"main"},
+		"commit-oldstaging": {"staging", "push-xyz"},
+	}, upstreams)
+}
+
+func TestGetStackRootsToRebaseOnto(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+
+	runner.On("log", "-r", "trunk()").ReturnChanges(jjtest.Trunk("newtrunk", "main"))
+	runner.On("log", "-r", "roots(mutable())").ReturnChanges(
+		jjtest.Change("aaaaaaaa", "A", "oldtrunk"),
+		jjtest.Change("bbbbbbbb", "B", "newrelease"),
+	)
+	runner.On("log", "-r", "release-2.3@origin").ReturnChanges(jjtest.Change("newrelease", "", ""))
+
+	roots, err := repo.GetStackRootsToRebase("release-2.3@origin", nil)
+	require.NoError(t, err)
+	require.Len(t, roots, 1)
+	assert.Equal(t, "aaaaaaaa", roots[0].ChangeID)
+	assert.Equal(t, "release-2.3@origin", roots[0].Destination)
+
+	runner.On("log", "-r", "heads(mutable())").ReturnChanges(
+		jjtest.Change("aaaaaaaa", "A", "oldtrunk"),
+		jjtest.Change("bbbbbbbb", "B", "newrelease"),
+	)
+	_, err = repo.GetStackRootsToRebase("heads(mutable())", nil)
+	assert.ErrorContains(t, err, "resolves to 2 revisions")
+}
+
+func TestConflictedFiles(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("resolve", "--list", "-r", "bbbbbbbb").Return(
+		"src/main.go    2-sided conflict\n" +
+			"docs/a very long file name.md 3-sided conflict including 1 deletion\n")
+
+	files, err := repo.ConflictedFiles("bbbbbbbb")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"src/main.go", "docs/a very long file name.md"}, files)
+
+	runner.On("resolve", "--list", "-r", "bbbbbbbb").Fail("Error: No conflicts found at this revision")
+	files, err = repo.ConflictedFiles("bbbbbbbb")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	runner.On("resolve", "--list", "-r", "bbbbbbbb").Fail("Error: Revision `bbbbbbbb` doesn't exist")
+	_, err = repo.ConflictedFiles("bbbbbbbb")
+	assert.ErrorContains(t, err, "doesn't exist")
+}
+
+func TestHasRemoteBookmark(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("log", "-r", `remote_bookmarks(exact:"push-a", remote=exact:"origin")`).Return("0123abcd\n")
+	runner.On("log", "-r", `remote_bookmarks(exact:"push-b", remote=exact:"origin")`).Return("")
+
+	exists, err := repo.HasRemoteBookmark("push-a")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = repo.HasRemoteBookmark("push-b")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,200 @@
+// Package jjtest provides a scriptable fake jj for tests.
+package jjtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cbrewster/jj-github/internal/jj"
+)
+
+// PushBookmarkTemplate is the git_push_bookmark template the fake reports by default.
+const PushBookmarkTemplate = `"push-" ++ change_id.short()`
+
+// Runner is a fake jj.Runner that replies to invocations with scripted responses.
+// Responses are matched against the arguments of each invocation, with the most
+// recently added matching response winning, so tests can change the repository
+// state part way through by adding new responses.
+type Runner struct {
+	mu        sync.Mutex
+	responses []*Response
+	calls     [][]string
+}
+
+// Response is a scripted reply to a jj invocation.
+type Response struct {
+	args   []string
+	stdout string
+	stderr string
+	err    error
+}
+
+// NewRunner creates a fake with responses for the config jj-github reads on
+// every run already scripted.
+func NewRunner() *Runner {
+	r := &Runner{}
+	r.On("config", "get", "templates.git_push_bookmark").Return(PushBookmarkTemplate)
+	return r
+}
+
+// NewClient returns a jj client backed by a new fake runner.
+func NewClient() (*jj.Client, *Runner) {
+	r := NewRunner()
+	return jj.NewClient(r), r
+}
+
+// On adds a response for invocations containing args, in order but not
+// necessarily adjacent. For example, On("log", "-r", "trunk()") matches
+// `jj log --no-graph -T ... -r trunk()`. The response succeeds with no output
+// unless configured otherwise.
+func (r *Runner) On(args ...string) *Response {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	resp := &Response{args: args}
+	r.responses = append(r.responses, resp)
+	return resp
+}
+
+// OnStack scripts a repository where changes are stacked on trunk and
+// include @, as loaded for the whole stack around @.
+func (r *Runner) OnStack(trunk jj.Change, changes ...jj.Change) {
+	r.On("log").ReturnChanges(append([]jj.Change{trunk}, changes...)...)
+	r.On("log", "-r", "trunk()").ReturnChanges(trunk)
+	r.On("log", "-r", "(::(@) | (@)::) & mutable() & ~empty()").ReturnChanges(changes...)
+}
+
+// Return sets the stdout of the response.
+func (resp *Response) Return(stdout string) *Response {
+	resp.stdout = stdout
+	return resp
+}
+
+// ReturnChanges sets the stdout of the response to the `jj log` output for changes.
+func (resp *Response) ReturnChanges(changes ...jj.Change) *Response {
+	return resp.Return(LogOutput(changes...))
+}
+
+// Fail makes the response exit with an error and the given stderr.
+func (resp *Response) Fail(stderr string) *Response {
+	resp.stderr = stderr
+	resp.err = exitError{code: 1}
+	return resp
+}
+
+// exitError mimics the *exec.ExitError of a jj process that failed.
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (e exitError) ExitCode() int {
+	return e.code
+}
+
+// Stderr sets the stderr of the response without failing.
+func (resp *Response) Stderr(stderr string) *Response {
+	resp.stderr = stderr
+	return resp
+}
+
+// Run implements jj.Runner.
+func (r *Runner) Run(args ...string) ([]byte, []byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, args)
+
+	for _, resp := range slices.Backward(r.responses) {
+		if matches(args, resp.args) {
+			return []byte(resp.stdout), []byte(resp.stderr), resp.err
+		}
+	}
+
+	msg := fmt.Sprintf("jjtest: unexpected invocation: jj %s", strings.Join(args, " "))
+	return nil, []byte(msg), errors.New(msg)
+}
+
+// Calls returns the arguments of every invocation so far.
+func (r *Runner) Calls() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.calls)
+}
+
+// Called returns the number of invocations containing args, matched like On.
+func (r *Runner) Called(args ...string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for _, call := range r.calls {
+		if matches(call, args) {
+			count++
+		}
+	}
+	return count
+}
+
+// matches returns true if want appears in args in order.
+func matches(args, want []string) bool {
+	i := 0
+	for _, arg := range args {
+		if i < len(want) && arg == want[i] {
+			i++
+		}
+	}
+	return i == len(want)
+}
+
+// LogOutput renders changes the way `jj log` does with jj-github's template.
+func LogOutput(changes ...jj.Change) string {
+	var sb strings.Builder
+	for _, change := range changes {
+		if change.Bookmarks == nil {
+			change.Bookmarks = []jj.BookmarkRef{}
+		}
+		if change.Parents == nil {
+			change.Parents = []jj.Parent{}
+		}
+		data, err := json.Marshal(change)
+		if err != nil {
+			panic(err)
+		}
+		sb.Write(data)
+	}
+	return sb.String()
+}
+
+// Change builds a change with the given ID, description and parent change ID.
+// The short ID is the first 3 characters of the ID, the commit ID is derived
+// from the change ID, and the push bookmark is "push-<id>".
+func Change(id, description, parent string) jj.Change {
+	change := jj.Change{
+		ID:              id,
+		ShortID:         id[:min(3, len(id))],
+		CommitID:        "commit-" + id,
+		GitPushBookmark: "push-" + id,
+		Description:     description,
+	}
+	if parent != "" {
+		change.Parents = []jj.Parent{{ChangeID: parent, CommitID: "commit-" + parent}}
+	}
+	return change
+}
+
+// Trunk builds an immutable trunk change with the given bookmark.
+func Trunk(id, bookmark string) jj.Change {
+	change := Change(id, "", "")
+	change.Immutable = true
+	change.Bookmarks = []jj.BookmarkRef{{Name: bookmark}}
+	return change
+}
@@ -0,0 +1,30 @@
+package jj
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+// Runner executes jj commands. It allows jj to be replaced with a fake in tests.
+type Runner interface {
+	// Run runs jj with the given arguments and returns its stdout and stderr.
+	// A non-zero exit status is returned as an error.
+	Run(args ...string) (stdout []byte, stderr []byte, err error)
+}
+
+// ExecRunner runs the jj binary found on PATH.
+type ExecRunner struct {
+	// Dir is the working directory jj is run in. Defaults to the current directory.
+	Dir string
+}
+
+// Run implements Runner.
+func (r ExecRunner) Run(args ...string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("jj", args...)
+	cmd.Dir = r.Dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
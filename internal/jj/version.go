@@ -0,0 +1,158 @@
+package jj
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// MinVersion is the oldest supported jj. It is the first release with the
+	// json() template function used by the log template.
+	MinVersion = Version{Major: 0, Minor: 26}
+	// MaxVersion is the first jj release that is not known to work. Newer
+	// versions are allowed as long as the capability checks pass.
+	MaxVersion = Version{Major: 1}
+)
+
+// DefaultGitPushBookmark is jj's default templates.git_push_bookmark, used
+// when the config value is missing.
+const DefaultGitPushBookmark = `"push-" ++ change_id.short()`
+
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)`)
+
+// Version is a jj release version.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion parses the output of `jj --version`, e.g. "jj 0.33.0-a1b2c3".
+func ParseVersion(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("unrecognized jj version %q", strings.TrimSpace(s))
+	}
+
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+	return Version{Major: major, Minor: minor, Patch: patch}, nil
+}
+
+// Less returns true if v is an older release than other.
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Capabilities are the jj features jj-github depends on, detected by probing
+// the installed jj rather than trusting its version number.
+type Capabilities struct {
+	JSONTemplate    bool // The json() template function, required for the log template
+	SkipEmptied     bool // `jj rebase --skip-emptied`, required by sync
+	GitPushBookmark bool // templates.git_push_bookmark is configured; DefaultGitPushBookmark is used otherwise. Not checked by CheckVersion
+}
+
+// Compatibility is the result of checking the installed jj.
+type Compatibility struct {
+	Version      Version
+	Capabilities Capabilities
+}
+
+// Err returns an error if jj-github can't work with this jj.
+func (c Compatibility) Err() error {
+	var errs []error
+	if c.Version.Less(MinVersion) {
+		errs = append(errs, fmt.Errorf("jj %s is older than the oldest supported version %s; please upgrade jj", c.Version, MinVersion))
+	}
+	if !c.Capabilities.JSONTemplate {
+		errs = append(errs, errors.New("jj does not support the json() template function"))
+	}
+	if !c.Capabilities.SkipEmptied {
+		errs = append(errs, errors.New("jj rebase does not support --skip-emptied"))
+	}
+	return errors.Join(errs...)
+}
+
+// Warning returns a message if this jj is newer than the supported range but
+// otherwise looks usable, or "" if there is nothing to warn about.
+func (c Compatibility) Warning() string {
+	if c.Version.Less(MaxVersion) {
+		return ""
+	}
+	return fmt.Sprintf("jj %s is newer than the supported range (%s up to %s); if something breaks, please report it", c.Version, MinVersion, MaxVersion)
+}
+
+// Version returns the version of the installed jj.
+func (c *Client) Version() (Version, error) {
+	out, _, err := c.run("--version")
+	if err != nil {
+		return Version{}, err
+	}
+	return ParseVersion(string(out))
+}
+
+// DetectCapabilities probes which features the installed jj supports.
+func (c *Client) DetectCapabilities() Capabilities {
+	var caps Capabilities
+
+	if _, _, err := c.run("log", "--no-graph", "-r", "root()", "-T", "json(change_id)"); err == nil {
+		caps.JSONTemplate = true
+	}
+
+	if help, _, err := c.run("rebase", "--help"); err == nil {
+		caps.SkipEmptied = strings.Contains(string(help), "--skip-emptied")
+	}
+
+	if _, err := c.GetTemplate("git_push_bookmark"); err == nil {
+		caps.GitPushBookmark = true
+	}
+
+	return caps
+}
+
+// CheckVersion is the cheaper check run before every command. Releases in the
+// supported range have every required feature, so capabilities are only
+// probed when the version is outside it. Use Compatibility.Err to decide
+// whether to continue.
+func (c *Client) CheckVersion() (Compatibility, error) {
+	version, err := c.Version()
+	if err != nil {
+		return Compatibility{}, err
+	}
+
+	if version.Less(MinVersion) || !version.Less(MaxVersion) {
+		return Compatibility{Version: version, Capabilities: c.DetectCapabilities()}, nil
+	}
+	return Compatibility{
+		Version:      version,
+		Capabilities: Capabilities{JSONTemplate: true, SkipEmptied: true},
+	}, nil
+}
+
+// CheckCompatibility detects the installed jj's version and capabilities.
+// Use Compatibility.Err to decide whether to continue.
+func (c *Client) CheckCompatibility() (Compatibility, error) {
+	version, err := c.Version()
+	if err != nil {
+		return Compatibility{}, err
+	}
+
+	return Compatibility{
+		Version:      version,
+		Capabilities: c.DetectCapabilities(),
+	}, nil
+}
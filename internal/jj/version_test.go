@@ -0,0 +1,140 @@
+package jj_test
+
+import (
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Output   string
+		Expected jj.Version
+	}{
+		{Name: "release", Output: "jj 0.33.0\n", Expected: jj.Version{Minor: 33}},
+		{Name: "dev build", Output: "jj 0.34.1-a1b2c3d4e5f6\n", Expected: jj.Version{Minor: 34, Patch: 1}},
+		{Name: "major", Output: "jj 1.2.3", Expected: jj.Version{Major: 1, Minor: 2, Patch: 3}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			v, err := jj.ParseVersion(tc.Output)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, v)
+		})
+	}
+
+	_, err := jj.ParseVersion("jj unknown")
+	assert.ErrorContains(t, err, "unrecognized jj version")
+}
+
+func TestCompatibility(t *testing.T) {
+	allCaps := jj.Capabilities{JSONTemplate: true, SkipEmptied: true, GitPushBookmark: true}
+
+	for _, tc := range []struct {
+		Name            string
+		Compat          jj.Compatibility
+		ExpectedErr     string
+		ExpectedWarning bool
+	}{
+		{
+			Name:   "supported",
+			Compat: jj.Compatibility{Version: jj.Version{Minor: 33}, Capabilities: allCaps},
+		},
+		{
+			Name:   "missing push bookmark template",
+			Compat: jj.Compatibility{Version: jj.Version{Minor: 33}, Capabilities: jj.Capabilities{JSONTemplate: true, SkipEmptied: true}},
+		},
+		{
+			Name:        "too old",
+			Compat:      jj.Compatibility{Version: jj.Version{Minor: 20}, Capabilities: allCaps},
+			ExpectedErr: "older than the oldest supported version 0.26.0",
+		},
+		{
+			Name:        "no skip-emptied",
+			Compat:      jj.Compatibility{Version: jj.Version{Minor: 33}, Capabilities: jj.Capabilities{JSONTemplate: true}},
+			ExpectedErr: "--skip-emptied",
+		},
+		{
+			Name:            "newer than supported",
+			Compat:          jj.Compatibility{Version: jj.Version{Major: 1, Minor: 1}, Capabilities: allCaps},
+			ExpectedWarning: true,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			if tc.ExpectedErr != "" {
+				assert.ErrorContains(t, tc.Compat.Err(), tc.ExpectedErr)
+			} else {
+				assert.NoError(t, tc.Compat.Err())
+			}
+			assert.Equal(t, tc.ExpectedWarning, tc.Compat.Warning() != "")
+		})
+	}
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("--version").Return("jj 0.33.0\n")
+	runner.On("log", "-T", "json(change_id)").Return(`"zzzzzzzz"`)
+	runner.On("rebase", "--help").Return("      --skip-emptied\n          If true, when rebasing would produce an empty commit, ...")
+
+	compat, err := repo.CheckCompatibility()
+	require.NoError(t, err)
+	assert.Equal(t, jj.Version{Minor: 33}, compat.Version)
+	assert.Equal(t, jj.Capabilities{JSONTemplate: true, SkipEmptied: true, GitPushBookmark: true}, compat.Capabilities)
+	assert.NoError(t, compat.Err())
+
+	runner.On("config", "get", "templates.git_push_bookmark").Fail("Config error: Value not found for templates.git_push_bookmark")
+	runner.On("rebase", "--help").Return("Usage: jj rebase [OPTIONS]")
+
+	compat, err = repo.CheckCompatibility()
+	require.NoError(t, err)
+	assert.False(t, compat.Capabilities.GitPushBookmark)
+	assert.False(t, compat.Capabilities.SkipEmptied)
+	assert.Error(t, compat.Err())
+}
+
+func TestCheckVersion(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("--version").Return("jj 0.33.0\n")
+
+	compat, err := repo.CheckVersion()
+	require.NoError(t, err)
+	assert.NoError(t, compat.Err())
+	assert.Equal(t, [][]string{{"--version"}}, runner.Calls(), "supported releases aren't probed")
+
+	// Releases outside the supported range are probed.
+	runner.On("--version").Return("jj 1.2.0\n")
+	runner.On("log", "-T", "json(change_id)").Return(`"zzzzzzzz"`)
+	runner.On("rebase", "--help").Return("Usage: jj rebase [OPTIONS]")
+
+	compat, err = repo.CheckVersion()
+	require.NoError(t, err)
+	assert.True(t, compat.Capabilities.JSONTemplate)
+	assert.False(t, compat.Capabilities.SkipEmptied)
+	assert.Error(t, compat.Err())
+}
+
+func TestGetChangesDefaultPushBookmark(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("config", "get", "templates.git_push_bookmark").Fail("Config error: Value not found for templates.git_push_bookmark")
+	runner.On("log", "-r", "@").ReturnChanges(jjtest.Change("aaaaaaaa", "A", "trunk"))
+
+	changes, err := repo.GetChanges("@")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+
+	calls := runner.Calls()
+	assert.Contains(t, calls[len(calls)-1][4], jj.DefaultGitPushBookmark)
+}
+
+func TestGetChangesConfigError(t *testing.T) {
+	repo, runner := jjtest.NewClient()
+	runner.On("config", "get", "templates.git_push_bookmark").Fail("Config error: Failed to parse file\nCaused by: TOML parse error")
+
+	_, err := repo.GetChanges("@")
+	assert.ErrorContains(t, err, "TOML parse error")
+	assert.Zero(t, runner.Called("log"), "doesn't fall back to the default template")
+}
@@ -0,0 +1,26 @@
+package journal
+
+import "fmt"
+
+// branchesFileName is the name of the adopted branch mapping within the state directory.
+const branchesFileName = "branches.json"
+
+// Branches maps change IDs to the branches of pull requests adopted with
+// `jj github adopt`. Submit pushes an adopted change to its branch instead of
+// the change's push bookmark, so the existing pull request is reused.
+type Branches map[string]string
+
+// LoadBranches reads the branch mapping stored in dir. Returns an empty
+// mapping if there is none.
+func LoadBranches(dir string) (Branches, error) {
+	branches := make(Branches)
+	if _, err := readJSON(dir, branchesFileName, &branches); err != nil {
+		return nil, fmt.Errorf("read adopted branches: %w", err)
+	}
+	return branches, nil
+}
+
+// Save atomically writes the branch mapping to dir.
+func (b Branches) Save(dir string) error {
+	return writeJSON(dir, branchesFileName, b)
+}
@@ -0,0 +1,23 @@
+package journal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchesRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	branches, err := LoadBranches(dir)
+	require.NoError(t, err)
+	assert.Empty(t, branches)
+
+	branches["aaaaaaaa"] = "fix-login"
+	require.NoError(t, branches.Save(dir))
+
+	loaded, err := LoadBranches(dir)
+	require.NoError(t, err)
+	assert.Equal(t, Branches{"aaaaaaaa": "fix-login"}, loaded)
+}
@@ -0,0 +1,137 @@
+// Package journal persists the progress of a submit run so that it can be
+// resumed after a partial failure.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileName is the name of the journal file within the state directory.
+const fileName = "submit-journal.json"
+
+// Journal records which steps of a submit run have completed.
+type Journal struct {
+	Revset    string            `json:"revset"`
+	StartedAt time.Time         `json:"started_at"`
+	Revisions map[string]*Entry `json:"revisions"` // Keyed by change ID
+
+	dir string
+}
+
+// Entry records the progress of a single revision.
+type Entry struct {
+	CommitID string `json:"commit_id"` // Commit that was pushed
+	Branch   string `json:"branch"`
+	Pushed   bool   `json:"pushed"`
+	PRNumber int    `json:"pr_number,omitempty"`
+	Synced   bool   `json:"synced"` // PR was created or updated
+}
+
+// StateDir returns the directory jj-github keeps its state in for the jj
+// repository at repoPath (the path to `.jj/repo`).
+func StateDir(repoPath string) string {
+	return filepath.Join(repoPath, "jj-github")
+}
+
+// New creates an empty journal for a run over revset, stored in dir.
+func New(dir, revset string) *Journal {
+	return &Journal{
+		Revset:    revset,
+		StartedAt: time.Now(),
+		Revisions: make(map[string]*Entry),
+		dir:       dir,
+	}
+}
+
+// Load reads the journal stored in dir. Returns nil if there is none.
+func Load(dir string) (*Journal, error) {
+	var j Journal
+	ok, err := readJSON(dir, fileName, &j)
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	if j.Revisions == nil {
+		j.Revisions = make(map[string]*Entry)
+	}
+	j.dir = dir
+
+	return &j, nil
+}
+
+// Entry returns the entry for changeID, creating it if needed.
+func (j *Journal) Entry(changeID string) *Entry {
+	entry, ok := j.Revisions[changeID]
+	if !ok {
+		entry = &Entry{}
+		j.Revisions[changeID] = entry
+	}
+	return entry
+}
+
+// Save atomically writes the journal to its directory.
+func (j *Journal) Save() error {
+	return writeJSON(j.dir, fileName, j)
+}
+
+// Remove deletes the journal once the run has completed.
+func (j *Journal) Remove() error {
+	return removeFile(j.dir, fileName)
+}
+
+// readJSON reads the state file name in dir into v. Returns false if it
+// doesn't exist.
+func readJSON(dir, name string, v any) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// writeJSON atomically writes v as the state file name in dir.
+func writeJSON(dir, name string, v any) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, name+".*")
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// removeFile deletes the state file name in dir, if it exists.
+func removeFile(dir, name string) error {
+	err := os.Remove(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
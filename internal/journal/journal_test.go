@@ -0,0 +1,45 @@
+package journal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	loaded, err := Load(dir)
+	require.NoError(t, err)
+	assert.Nil(t, loaded, "no journal before the first save")
+
+	j := New(dir, "@-::@")
+	entry := j.Entry("abc")
+	entry.CommitID = "123"
+	entry.Branch = "push-abc"
+	entry.Pushed = true
+	require.NoError(t, j.Save())
+
+	loaded, err = Load(dir)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+	assert.Equal(t, "@-::@", loaded.Revset)
+	assert.Equal(t, &Entry{CommitID: "123", Branch: "push-abc", Pushed: true}, loaded.Revisions["abc"])
+
+	loaded.Entry("abc").Synced = true
+	loaded.Entry("abc").PRNumber = 7
+	require.NoError(t, loaded.Save())
+
+	loaded, err = Load(dir)
+	require.NoError(t, err)
+	assert.True(t, loaded.Revisions["abc"].Synced)
+
+	require.NoError(t, loaded.Remove())
+	loaded, err = Load(dir)
+	require.NoError(t, err)
+	assert.Nil(t, loaded)
+
+	// Removing twice is fine.
+	require.NoError(t, j.Remove())
+}
@@ -0,0 +1,90 @@
+package journal
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// snapshotFileName is the name of the undo snapshot within the state directory.
+const snapshotFileName = "undo-snapshot.json"
+
+// Snapshot records the jj operation a sync or submit run started from, so
+// that `jj github undo` can restore it, along with the changes the run made
+// on GitHub, which restoring the operation doesn't revert.
+type Snapshot struct {
+	Command     string    `json:"command"`      // "sync" or "submit"
+	OperationID string    `json:"operation_id"` // jj operation before the run
+	StartedAt   time.Time `json:"started_at"`
+
+	CreatedPRs      []int    `json:"created_prs,omitempty"`
+	CreatedBranches []string `json:"created_branches,omitempty"` // Branches that didn't exist before the run
+	UpdatedBranches []string `json:"updated_branches,omitempty"` // Existing branches that were force-pushed
+
+	// Restored is set once undo has restored the operation but the changes
+	// on GitHub are still to be reverted.
+	Restored bool `json:"restored,omitempty"`
+
+	dir string
+}
+
+// NewSnapshot creates a snapshot of a run of command starting at operationID,
+// stored in dir.
+func NewSnapshot(dir, command, operationID string) *Snapshot {
+	return &Snapshot{
+		Command:     command,
+		OperationID: operationID,
+		StartedAt:   time.Now(),
+		dir:         dir,
+	}
+}
+
+// LoadSnapshot reads the snapshot stored in dir. Returns nil if there is none.
+func LoadSnapshot(dir string) (*Snapshot, error) {
+	var s Snapshot
+	ok, err := readJSON(dir, snapshotFileName, &s)
+	if err != nil {
+		return nil, fmt.Errorf("read undo snapshot: %w", err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	s.dir = dir
+
+	return &s, nil
+}
+
+// Pushed records that branch was pushed. created is true if the branch didn't
+// exist on the remote before.
+func (s *Snapshot) Pushed(branch string, created bool) {
+	if slices.Contains(s.CreatedBranches, branch) || slices.Contains(s.UpdatedBranches, branch) {
+		return
+	}
+	if created {
+		s.CreatedBranches = append(s.CreatedBranches, branch)
+	} else {
+		s.UpdatedBranches = append(s.UpdatedBranches, branch)
+	}
+}
+
+// CreatedPR records that pull request number was opened.
+func (s *Snapshot) CreatedPR(number int) {
+	if !slices.Contains(s.CreatedPRs, number) {
+		s.CreatedPRs = append(s.CreatedPRs, number)
+	}
+}
+
+// HasRemoteEffects returns true if the run changed anything on GitHub.
+func (s *Snapshot) HasRemoteEffects() bool {
+	return len(s.CreatedPRs) > 0 || len(s.CreatedBranches) > 0 || len(s.UpdatedBranches) > 0
+}
+
+// Save atomically writes the snapshot to its directory.
+func (s *Snapshot) Save() error {
+	return writeJSON(s.dir, snapshotFileName, s)
+}
+
+// Remove deletes the snapshot once it has been undone.
+func (s *Snapshot) Remove() error {
+	return removeFile(s.dir, snapshotFileName)
+}
@@ -0,0 +1,47 @@
+package journal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	loaded, err := LoadSnapshot(dir)
+	require.NoError(t, err)
+	assert.Nil(t, loaded, "no snapshot before the first save")
+
+	s := NewSnapshot(dir, "submit", "op123")
+	assert.False(t, s.HasRemoteEffects())
+	s.Pushed("push-a", true)
+	s.Pushed("push-b", false)
+	s.Pushed("push-a", true)
+	s.CreatedPR(1)
+	s.CreatedPR(1)
+	require.NoError(t, s.Save())
+
+	// The journal and snapshot are kept side by side.
+	require.NoError(t, New(dir, "@").Save())
+
+	loaded, err = LoadSnapshot(dir)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+	assert.Equal(t, "submit", loaded.Command)
+	assert.Equal(t, "op123", loaded.OperationID)
+	assert.Equal(t, []string{"push-a"}, loaded.CreatedBranches)
+	assert.Equal(t, []string{"push-b"}, loaded.UpdatedBranches)
+	assert.Equal(t, []int{1}, loaded.CreatedPRs)
+	assert.True(t, loaded.HasRemoteEffects())
+
+	require.NoError(t, loaded.Remove())
+	loaded, err = LoadSnapshot(dir)
+	require.NoError(t, err)
+	assert.Nil(t, loaded)
+
+	j, err := Load(dir)
+	require.NoError(t, err)
+	assert.NotNil(t, j)
+}
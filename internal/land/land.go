@@ -0,0 +1,167 @@
+// Package land merges the pull request at the bottom of a stack, through the
+// merge queue if its base branch has one.
+package land
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cbrewster/jj-github/internal/browse"
+	"github.com/cbrewster/jj-github/internal/github"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// pollInterval is how often a queued pull request is checked by default
+const pollInterval = 10 * time.Second
+
+// GitHub is the GitHub functionality used to land pull requests.
+type GitHub interface {
+	MergePullRequest(ctx context.Context, repo github.Repo, number int, method string) error
+	HasMergeQueue(ctx context.Context, repo github.Repo, branch string) (bool, error)
+	EnqueuePullRequest(ctx context.Context, repo github.Repo, pr *gogithub.PullRequest) error
+	LoadMergeQueueEntry(ctx context.Context, repo github.Repo, number int) (*github.MergeQueueEntry, error)
+}
+
+// Options configures how a pull request is landed.
+type Options struct {
+	// Method is the merge method, one of github.MergeMethods. Merge queues
+	// use the method configured for the queue instead.
+	Method string
+	// PollInterval is how often a queued pull request is checked. Defaults
+	// to pollInterval.
+	PollInterval time.Duration
+	// Progress is called with the pull request's queue entry each time it
+	// is checked.
+	Progress func(entry *github.MergeQueueEntry)
+}
+
+// Bottom returns the first target in the stack, i.e. the one merging into a
+// branch that isn't part of the stack. Targets are ordered parents first, as
+// returned by browse.Resolve.
+func Bottom(targets []browse.Target) (browse.Target, error) {
+	for _, target := range targets {
+		if !inStack(targets, target.Base) {
+			return target, nil
+		}
+	}
+	return browse.Target{}, fmt.Errorf("no revision to land")
+}
+
+// Check returns why target can't be landed, or nil if it can.
+func Check(targets []browse.Target, target browse.Target) error {
+	pr := target.PR
+	switch {
+	case pr == nil:
+		return fmt.Errorf("%s has no pull request, submit it first", target.Change.ShortID)
+	case pr.GetState() != "open":
+		return fmt.Errorf("pull request #%d is %s", pr.GetNumber(), pr.GetState())
+	case pr.GetDraft():
+		return fmt.Errorf("pull request #%d is a draft", pr.GetNumber())
+	case inStack(targets, pr.GetBase().GetRef()):
+		return fmt.Errorf("pull request #%d merges into %s, land the pull requests below it first",
+			pr.GetNumber(), pr.GetBase().GetRef())
+	case pr.GetHead().GetSHA() != target.Change.CommitID:
+		return fmt.Errorf("%s has changes that aren't in pull request #%d, submit it first",
+			target.Change.ShortID, pr.GetNumber())
+	}
+	return nil
+}
+
+// Land merges target's pull request. If its base branch has a merge queue,
+// the pull request is added to the queue instead, and Land waits until the
+// queue merges it or removes it, e.g. because a check failed.
+func Land(ctx context.Context, gh GitHub, repo github.Repo, target browse.Target, opts Options) error {
+	number := target.PR.GetNumber()
+
+	queued, err := gh.HasMergeQueue(ctx, repo, target.PR.GetBase().GetRef())
+	if err != nil {
+		return fmt.Errorf("check for a merge queue: %w", err)
+	}
+	if !queued {
+		if err := gh.MergePullRequest(ctx, repo, number, opts.Method); err != nil {
+			return fmt.Errorf("merge pull request #%d: %w", number, err)
+		}
+		return nil
+	}
+
+	if err := gh.EnqueuePullRequest(ctx, repo, target.PR); err != nil {
+		return fmt.Errorf("add pull request #%d to the merge queue: %w", number, err)
+	}
+	return waitForQueue(ctx, gh, repo, number, opts)
+}
+
+// waitForQueue polls a queued pull request until it leaves the queue.
+func waitForQueue(ctx context.Context, gh GitHub, repo github.Repo, number int, opts Options) error {
+	interval := opts.PollInterval
+	if interval == 0 {
+		interval = pollInterval
+	}
+
+	// The queue's checks are gone once it removes the pull request, so
+	// remember the last ones seen to report why.
+	var checks *github.Checks
+	for {
+		entry, err := gh.LoadMergeQueueEntry(ctx, repo, number)
+		if err != nil {
+			return fmt.Errorf("load merge queue entry of pull request #%d: %w", number, err)
+		}
+		if entry.Checks != nil {
+			checks = entry.Checks
+		}
+		if opts.Progress != nil {
+			opts.Progress(entry)
+		}
+
+		switch {
+		case entry.Merged:
+			return nil
+		case entry.State == "" || entry.State == "UNMERGEABLE":
+			return queueFailed(number, checks)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting, pull request #%d is still in the merge queue: %w", number, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// queueFailed returns the error for a pull request the queue didn't merge,
+// naming the failed checks if there are any.
+func queueFailed(number int, checks *github.Checks) error {
+	if checks != nil {
+		if failed := checks.Failed(); len(failed) > 0 {
+			return fmt.Errorf("pull request #%d was removed from the merge queue: %s failed", number, strings.Join(failed, ", "))
+		}
+	}
+	return fmt.Errorf("pull request #%d was removed from the merge queue", number)
+}
+
+// Describe summarizes a queue entry for progress output.
+func Describe(entry *github.MergeQueueEntry) string {
+	switch entry.State {
+	case "":
+		if entry.Merged {
+			return "merged"
+		}
+		return "removed from the merge queue"
+	case "QUEUED":
+		return fmt.Sprintf("position %d in the merge queue", entry.Position)
+	case "AWAITING_CHECKS":
+		return fmt.Sprintf("position %d in the merge queue, waiting for checks", entry.Position)
+	case "MERGEABLE", "LOCKED":
+		return "merging"
+	case "UNMERGEABLE":
+		return "can't be merged"
+	}
+	return strings.ToLower(entry.State)
+}
+
+// inStack returns true if branch is the branch of one of targets.
+func inStack(targets []browse.Target, branch string) bool {
+	return slices.ContainsFunc(targets, func(t browse.Target) bool { return t.Branch == branch })
+}
@@ -0,0 +1,179 @@
+package land
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cbrewster/jj-github/internal/browse"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+// testTargets returns trunk <- a <- b with a PR for each.
+func testTargets(gh *githubtest.Fake) []browse.Target {
+	a := gh.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-aaaaaaaa", Base: "main"}, "commit-aaaaaaaa")
+	b := gh.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-bbbbbbbb", Base: "push-aaaaaaaa"}, "commit-bbbbbbbb")
+	return []browse.Target{
+		{Change: jjtest.Change("aaaaaaaa", "A", "trunk"), Branch: "push-aaaaaaaa", Base: "main", PR: a},
+		{Change: jjtest.Change("bbbbbbbb", "B", "aaaaaaaa"), Branch: "push-bbbbbbbb", Base: "push-aaaaaaaa", PR: b},
+	}
+}
+
+func TestBottomAndLand(t *testing.T) {
+	gh := githubtest.NewFake()
+	targets := testTargets(gh)
+
+	bottom, err := Bottom(targets)
+	require.NoError(t, err)
+	assert.Equal(t, "aaaaaaaa", bottom.Change.ID)
+	require.NoError(t, Check(targets, bottom))
+
+	require.NoError(t, Land(context.Background(), gh, testRepo, bottom, Options{Method: "squash"}))
+	assert.True(t, gh.PullRequests()[0].GetMerged())
+
+	_, err = Bottom(nil)
+	assert.Error(t, err)
+}
+
+func TestLandMergeQueue(t *testing.T) {
+	for _, tc := range []struct {
+		Name string
+		// Advance moves the queue along each time it is polled.
+		Advance  func(gh *githubtest.Fake, polls int)
+		Progress []string
+		Err      string
+	}{
+		{
+			Name: "merged",
+			Advance: func(gh *githubtest.Fake, polls int) {
+				switch polls {
+				case 1:
+					gh.SetMergeQueueEntry(1, "AWAITING_CHECKS", github.Check{Name: "ci", Status: github.CheckPending})
+				case 2:
+					gh.ClosePullRequest(1, true)
+				}
+			},
+			Progress: []string{
+				"position 1 in the merge queue",
+				"position 1 in the merge queue, waiting for checks",
+				"merged",
+			},
+		},
+		{
+			Name: "check failed",
+			Advance: func(gh *githubtest.Fake, polls int) {
+				switch polls {
+				case 1:
+					gh.SetMergeQueueEntry(1, "AWAITING_CHECKS",
+						github.Check{Name: "lint", Status: github.CheckSuccess},
+						github.Check{Name: "test", Status: github.CheckFailure})
+				case 2:
+					gh.SetMergeQueueEntry(1, "")
+				}
+			},
+			Progress: []string{
+				"position 1 in the merge queue",
+				"position 1 in the merge queue, waiting for checks",
+				"removed from the merge queue",
+			},
+			Err: "pull request #1 was removed from the merge queue: test failed",
+		},
+		{
+			Name: "removed",
+			Advance: func(gh *githubtest.Fake, polls int) {
+				gh.SetMergeQueueEntry(1, "")
+			},
+			Progress: []string{
+				"position 1 in the merge queue",
+				"removed from the merge queue",
+			},
+			Err: "pull request #1 was removed from the merge queue",
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			gh := githubtest.NewFake()
+			gh.SetMergeQueue("main")
+			targets := testTargets(gh)
+
+			var progress []string
+			err := Land(context.Background(), gh, testRepo, targets[0], Options{
+				Method:       "squash",
+				PollInterval: time.Millisecond,
+				Progress: func(entry *github.MergeQueueEntry) {
+					progress = append(progress, Describe(entry))
+					tc.Advance(gh, len(progress))
+				},
+			})
+			if tc.Err != "" {
+				assert.EqualError(t, err, tc.Err)
+				assert.False(t, gh.PullRequests()[0].GetMerged())
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.Progress, progress)
+		})
+	}
+}
+
+func TestLandMergeQueueStopped(t *testing.T) {
+	gh := githubtest.NewFake()
+	gh.SetMergeQueue("main")
+	targets := testTargets(gh)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := Land(ctx, gh, testRepo, targets[0], Options{
+		PollInterval: time.Hour,
+		Progress:     func(*github.MergeQueueEntry) { cancel() },
+	})
+	assert.EqualError(t, err, "stopped waiting, pull request #1 is still in the merge queue: context canceled")
+}
+
+func TestCheck(t *testing.T) {
+	for _, tc := range []struct {
+		Name   string
+		Modify func(targets []browse.Target) browse.Target
+		Err    string
+	}{
+		{
+			Name:   "above the bottom",
+			Modify: func(targets []browse.Target) browse.Target { return targets[1] },
+			Err:    "pull request #2 merges into push-aaaaaaaa, land the pull requests below it first",
+		},
+		{
+			Name: "no pull request",
+			Modify: func(targets []browse.Target) browse.Target {
+				targets[0].PR = nil
+				return targets[0]
+			},
+			Err: "aaa has no pull request, submit it first",
+		},
+		{
+			Name: "draft",
+			Modify: func(targets []browse.Target) browse.Target {
+				targets[0].PR.Draft = gogithub.Ptr(true)
+				return targets[0]
+			},
+			Err: "pull request #1 is a draft",
+		},
+		{
+			Name: "not pushed",
+			Modify: func(targets []browse.Target) browse.Target {
+				targets[0].Change.CommitID = "amended"
+				return targets[0]
+			},
+			Err: "aaa has changes that aren't in pull request #1, submit it first",
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			targets := testTargets(githubtest.NewFake())
+			assert.EqualError(t, Check(targets, tc.Modify(targets)), tc.Err)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Package reviews summarizes the review state of the pull requests in a
+// stack, mapping unresolved review threads back to the revisions they were
+// left on.
+package reviews
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/browse"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/tui/components"
+)
+
+// maxBodyWidth is the number of characters of a thread's first comment shown.
+const maxBodyWidth = 60
+
+// GitHub is the GitHub functionality used to load reviews.
+type GitHub interface {
+	browse.GitHub
+	LoadReviews(ctx context.Context, repo github.Repo, numbers []int) (map[int]*github.Reviews, error)
+}
+
+// Revision is a revision with the review state of its pull request.
+type Revision struct {
+	browse.Target
+	Reviews *github.Reviews // Nil if the revision has no pull request
+}
+
+// Load loads the review state of the targets' pull requests.
+func Load(ctx context.Context, gh GitHub, ghRepo github.Repo, targets []browse.Target) ([]Revision, error) {
+	var numbers []int
+	for _, target := range targets {
+		if target.PR != nil {
+			numbers = append(numbers, target.PR.GetNumber())
+		}
+	}
+
+	reviews, err := gh.LoadReviews(ctx, ghRepo, numbers)
+	if err != nil {
+		return nil, fmt.Errorf("load reviews: %w", err)
+	}
+
+	result := make([]Revision, len(targets))
+	for i, target := range targets {
+		result[i] = Revision{Target: target}
+		if target.PR != nil {
+			result[i].Reviews = reviews[target.PR.GetNumber()]
+		}
+	}
+	return result, nil
+}
+
+// Unresolved returns the number of unresolved threads across revisions.
+func Unresolved(revisions []Revision) int {
+	count := 0
+	for _, revision := range revisions {
+		if revision.Reviews != nil {
+			count += len(revision.Reviews.Threads)
+		}
+	}
+	return count
+}
+
+// Render lists the revisions top first, like jj log, with each pull
+// request's review decision, the latest review of each reviewer and the
+// location of every unresolved thread.
+func Render(revisions []Revision) string {
+	var sb strings.Builder
+	for _, revision := range slices.Backward(revisions) {
+		title, _, _ := strings.Cut(revision.Change.Description, "\n")
+		fmt.Fprintf(&sb, "%s %s %s",
+			components.MutedStyle.Render(components.GraphPending),
+			components.ChangeIDShortStyle.Render(revision.Change.ShortID),
+			title)
+
+		reviews := revision.Reviews
+		if revision.PR == nil || reviews == nil {
+			sb.WriteString("  " + components.MutedStyle.Render("no pull request") + "\n")
+			continue
+		}
+
+		fmt.Fprintf(&sb, "  %s  %s", components.PRLinkStyle.Render(fmt.Sprintf("#%d", revision.PR.GetNumber())), Decision(reviews))
+		switch len(reviews.Threads) {
+		case 0:
+		case 1:
+			sb.WriteString("  " + components.YellowStyle.Render("1 unresolved thread"))
+		default:
+			sb.WriteString("  " + components.YellowStyle.Render(fmt.Sprintf("%d unresolved threads", len(reviews.Threads))))
+		}
+		sb.WriteString("\n")
+
+		if len(reviews.Reviewers) > 0 {
+			states := make([]string, len(reviews.Reviewers))
+			for i, reviewer := range reviews.Reviewers {
+				states[i] = reviewer.Login + " " + humanize(reviewer.State)
+			}
+			sb.WriteString("    " + components.MutedStyle.Render(strings.Join(states, " · ")) + "\n")
+		}
+
+		for _, thread := range reviews.Threads {
+			location := thread.Path
+			if thread.Line > 0 {
+				location = fmt.Sprintf("%s:%d", thread.Path, thread.Line)
+			}
+			if thread.Outdated {
+				location += " (outdated)"
+			}
+			fmt.Fprintf(&sb, "    %s  %s: %s\n", location, thread.Author, summarize(thread.Body))
+		}
+	}
+	return sb.String()
+}
+
+// Decision renders a pull request's review decision.
+func Decision(reviews *github.Reviews) string {
+	switch reviews.Decision {
+	case "APPROVED":
+		return components.SuccessStyle.Render(components.GraphSuccess + " approved")
+	case "CHANGES_REQUESTED":
+		return components.ErrorStyle.Render(components.GraphError + " changes requested")
+	case "REVIEW_REQUIRED":
+		return components.YellowStyle.Render("review required")
+	}
+	if len(reviews.Reviewers) == 0 {
+		return components.MutedStyle.Render("no reviews")
+	}
+	return components.MutedStyle.Render("reviewed")
+}
+
+// humanize turns a GitHub enum such as CHANGES_REQUESTED into "changes requested".
+func humanize(state string) string {
+	return strings.ToLower(strings.ReplaceAll(state, "_", " "))
+}
+
+// summarize returns the first line of a comment, shortened to maxBodyWidth.
+func summarize(body string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(body), "\n")
+	if runes := []rune(line); len(runes) > maxBodyWidth {
+		return string(runes[:maxBodyWidth-1]) + "…"
+	}
+	return line
+}
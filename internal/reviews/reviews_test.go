@@ -0,0 +1,62 @@
+package reviews
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/browse"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+func TestLoadAndRender(t *testing.T) {
+	ctx := context.Background()
+	gh := githubtest.NewFake()
+	a := gh.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-aaaaaaaa", Base: "main"}, "commit-aaaaaaaa")
+	b := gh.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-bbbbbbbb", Base: "push-aaaaaaaa"}, "commit-bbbbbbbb")
+	gh.AddReview(a.GetNumber(), "alice", "APPROVED")
+	gh.AddReview(b.GetNumber(), "alice", "CHANGES_REQUESTED")
+	gh.AddReview(b.GetNumber(), "bob", "COMMENTED")
+	gh.AddReviewThread(b.GetNumber(), github.ReviewThread{Path: "main.go", Line: 12, Author: "alice", Body: "Handle the error\n\nIt can fail."})
+	gh.AddReviewThread(b.GetNumber(), github.ReviewThread{Path: "old.go", Line: 3, Outdated: true, Author: "bob", Body: strings.Repeat("x", 80)})
+	gh.AddReviewThread(a.GetNumber(), github.ReviewThread{Path: "done.go", Resolved: true})
+
+	targets := []browse.Target{
+		{Change: jjtest.Change("aaaaaaaa", "Add feature A", "trunk"), PR: a},
+		{Change: jjtest.Change("bbbbbbbb", "Add feature B", "aaaaaaaa"), PR: b},
+		{Change: jjtest.Change("cccccccc", "Add feature C", "bbbbbbbb")},
+	}
+
+	revisions, err := Load(ctx, gh, testRepo, targets)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Len(t, revisions[0].Reviews.Threads, 0)
+	assert.Len(t, revisions[1].Reviews.Threads, 2)
+	assert.Nil(t, revisions[2].Reviews)
+	assert.Equal(t, 2, Unresolved(revisions))
+
+	assert.Equal(t, ""+
+		"○ ccc Add feature C  no pull request\n"+
+		"○ bbb Add feature B  #2  ✗ changes requested  2 unresolved threads\n"+
+		"    alice changes requested · bob commented\n"+
+		"    main.go:12  alice: Handle the error\n"+
+		"    old.go:3 (outdated)  bob: "+strings.Repeat("x", 59)+"…\n"+
+		"○ aaa Add feature A  #1  ✓ approved\n"+
+		"    alice approved\n",
+		Render(revisions))
+}
+
+func TestLoadError(t *testing.T) {
+	gh := githubtest.NewFake()
+	gh.FailNext("LoadReviews", errors.New("boom"))
+
+	_, err := Load(context.Background(), gh, testRepo, nil)
+	assert.ErrorContains(t, err, "load reviews: boom")
+}
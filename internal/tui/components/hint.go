@@ -0,0 +1,13 @@
+package components
+
+import "github.com/cbrewster/jj-github/internal/jj"
+
+// HintView renders the remediation hint for err on its own line, or "" if
+// there is no hint for it.
+func HintView(err error) string {
+	hint := jj.Hint(err)
+	if hint == "" {
+		return ""
+	}
+	return MutedStyle.Render("Hint: "+hint) + "\n"
+}
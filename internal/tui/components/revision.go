@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/cbrewster/jj-github/internal/github"
 	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/rivo/uniseg"
 )
 
@@ -22,11 +24,13 @@ const (
 type Revision struct {
 	Change      jj.Change
 	State       RevisionState
-	StatusMsg   string // Sub-status message (e.g., "Pushing...", "Creating PR...")
-	PRNumber    int    // PR number if created/exists
-	Error       error  // Error if state is StateError
-	IsImmutable bool   // Is this an immutable revision (trunk)?
-	NeedsSync   bool   // Whether this revision needs to be synced
+	StatusMsg   string         // Sub-status message (e.g., "Pushing...", "Creating PR..." or a warning once synced)
+	PRNumber    int            // PR number if created/exists
+	Error       error          // Error if state is StateError
+	IsImmutable bool           // Is this an immutable revision (trunk)?
+	NeedsSync   bool           // Whether this revision needs to be synced
+	Checks      *github.Checks // CI checks on the PR's head commit, if loaded
+	AutoMerge   string         // Merge method the PR is auto-merged with, if enabled
 }
 
 // NewRevision creates a new revision from a jj.Change
@@ -90,14 +94,18 @@ func (r Revision) View(spinner Spinner, showConnector bool, opts ViewOptions) st
 		}
 
 		// Calculate available width for description
-		// Layout: symbol(1-2) + "  " + changeID(8) + "  " + description + "  " + prLink
-		// Symbol width varies (✓, ○, etc.) but we'll use 2 as a safe estimate
-		symbolWidth := 2  // graph symbol width
-		spacing := 2 + 2 + 2  // three "  " separators
-		changeIDWidth := 8    // fixed change ID width
-		prTextWidth := uniseg.StringWidth(prText)
-		
-		fixedWidth := symbolWidth + spacing + changeIDWidth + prTextWidth
+		// Layout: symbol + "  " + changeID + "  " + description + "  " + prLink
+		// The symbol and change ID are measured after styling, since graph
+		// symbols and spinner frames vary in width.
+		checks := ChecksView(r.Checks)
+		fixedWidth := lipgloss.Width(symbol) + lipgloss.Width(changeIDStr) + 2 + 2 + 2 + uniseg.StringWidth(prText)
+		if checks != "" {
+			fixedWidth += 2 + lipgloss.Width(checks)
+		}
+		autoMerge := AutoMergeView(r.AutoMerge)
+		if autoMerge != "" {
+			fixedWidth += 2 + lipgloss.Width(autoMerge)
+		}
 		availableWidth := opts.Width - fixedWidth
 		if availableWidth < 10 {
 			availableWidth = 10 // Minimum width for description
@@ -105,12 +113,23 @@ func (r Revision) View(spinner Spinner, showConnector bool, opts ViewOptions) st
 
 		// Description (first line, truncated based on available width)
 		desc := r.firstLine(r.Change.Description)
-		desc = truncateString(desc, availableWidth)
+		desc = TruncateString(desc, availableWidth)
 		sb.WriteString(desc)
 
 		// PR link
 		sb.WriteString("  ")
 		sb.WriteString(PRLinkStyle.Render(prText))
+
+		// Check status
+		if checks != "" {
+			sb.WriteString("  ")
+			sb.WriteString(checks)
+		}
+
+		if autoMerge != "" {
+			sb.WriteString("  ")
+			sb.WriteString(autoMerge)
+		}
 	}
 
 	sb.WriteString("\n")
@@ -120,14 +139,20 @@ func (r Revision) View(spinner Spinner, showConnector bool, opts ViewOptions) st
 		sb.WriteString(GraphLine)
 	}
 
-	// Status message line (if in progress or error)
-	if r.StatusMsg != "" && (r.State == StateInProgress || r.State == StateError) {
+	// Status message line (if in progress, error or synced with a warning)
+	if r.StatusMsg != "" && r.State != StatePending {
 		sb.WriteString("  ")
-		if r.State == StateError {
+		switch r.State {
+		case StateError:
 			sb.WriteString(ErrorStyle.Render(r.StatusMsg))
-		} else {
+		case StateSuccess:
+			sb.WriteString(YellowStyle.Render(r.StatusMsg))
+		default:
 			sb.WriteString(MutedStyle.Render(r.StatusMsg))
 		}
+	} else if r.Checks != nil && len(r.Checks.Failed()) > 0 {
+		sb.WriteString("  ")
+		sb.WriteString(ErrorStyle.Render("Failing: " + strings.Join(r.Checks.Failed(), ", ")))
 	}
 
 	sb.WriteString("\n")
@@ -153,6 +178,34 @@ func (r Revision) graphSymbol(spinner Spinner) string {
 	}
 }
 
+// ChecksView renders the combined status of a PR's checks, or "" if there
+// are none.
+func ChecksView(checks *github.Checks) string {
+	if checks == nil {
+		return ""
+	}
+	switch checks.Status {
+	case github.CheckPending:
+		return YellowStyle.Render(GraphPending + " checks")
+	case github.CheckSuccess:
+		return SuccessStyle.Render(GraphSuccess + " checks")
+	case github.CheckFailure:
+		return ErrorStyle.Render(GraphError + " checks")
+	case github.CheckSkipped:
+		return MutedStyle.Render("- checks skipped")
+	}
+	return ""
+}
+
+// AutoMergeView renders the merge method a PR is auto-merged with, or "" if
+// auto-merge is off.
+func AutoMergeView(method string) string {
+	if method == "" {
+		return ""
+	}
+	return AccentStyle.Render("auto-merge: " + method)
+}
+
 func (r Revision) firstLine(s string) string {
 	if idx := strings.Index(s, "\n"); idx != -1 {
 		return s[:idx]
@@ -160,9 +213,9 @@ func (r Revision) firstLine(s string) string {
 	return s
 }
 
-// truncateString truncates a string to the specified width, adding "..." if truncated.
+// TruncateString truncates a string to the specified width, adding "..." if truncated.
 // It uses grapheme clustering to handle Unicode correctly.
-func truncateString(s string, maxWidth int) string {
+func TruncateString(s string, maxWidth int) string {
 	if maxWidth <= 0 {
 		return ""
 	}
@@ -3,6 +3,7 @@ package components
 import (
 	"strings"
 
+	"github.com/cbrewster/jj-github/internal/github"
 	"github.com/cbrewster/jj-github/internal/jj"
 )
 
@@ -57,6 +58,37 @@ func (s *Stack) SetRevisionPR(changeID string, prNumber int) {
 	}
 }
 
+// SetRevisionChecks sets the CI checks for a revision
+func (s *Stack) SetRevisionChecks(changeID string, checks *github.Checks) {
+	for i := range s.Revisions {
+		if s.Revisions[i].Change.ID == changeID {
+			s.Revisions[i].Checks = checks
+			return
+		}
+	}
+}
+
+// SetRevisionAutoMerge sets the merge method a revision's PR is auto-merged with
+func (s *Stack) SetRevisionAutoMerge(changeID string, method string) {
+	for i := range s.Revisions {
+		if s.Revisions[i].Change.ID == changeID {
+			s.Revisions[i].AutoMerge = method
+			return
+		}
+	}
+}
+
+// CheckStatus returns the combined status of the checks of every revision
+func (s *Stack) CheckStatus() github.CheckStatus {
+	var runs []github.Check
+	for _, r := range s.Revisions {
+		if r.Checks != nil {
+			runs = append(runs, r.Checks.Runs...)
+		}
+	}
+	return github.NewChecks(runs).Status
+}
+
 // SetRevisionError sets an error state for a revision
 func (s *Stack) SetRevisionError(changeID string, err error) {
 	for i := range s.Revisions {
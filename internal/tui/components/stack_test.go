@@ -4,7 +4,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/cbrewster/jj-github/internal/github"
 	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -142,7 +144,7 @@ func TestTruncateString(t *testing.T) {
 		},
 	} {
 		t.Run(tc.Name, func(t *testing.T) {
-			result := truncateString(tc.Input, tc.MaxWidth)
+			result := TruncateString(tc.Input, tc.MaxWidth)
 			assert.Equal(t, tc.Expected, result)
 		})
 	}
@@ -219,3 +221,64 @@ func TestRevisionViewTruncation(t *testing.T) {
 	assert.True(t, strings.Contains(output, "...") || len(rev.Change.Description) <= 40,
 		"Long descriptions should be truncated")
 }
+
+func TestRevisionViewFillsWidth(t *testing.T) {
+	rev := Revision{
+		Change: jj.Change{
+			ID:          "abcdefgh12345678",
+			ShortID:     "abc",
+			Description: "This is a very long description that should be truncated to fit the available width",
+		},
+		State:     StatePending,
+		NeedsSync: true,
+		PRNumber:  123,
+	}
+
+	for _, width := range []int{80, 120} {
+		output := rev.View(NewSpinner(), false, ViewOptions{RepoOwner: "owner", RepoName: "repo", Width: width})
+		line, _, _ := strings.Cut(output, "\n")
+		assert.Equal(t, width, lipgloss.Width(line), "width %d", width)
+	}
+}
+
+func TestRevisionViewChecks(t *testing.T) {
+	spinner := NewSpinner()
+	opts := ViewOptions{RepoOwner: "owner", RepoName: "repo", Width: 120}
+
+	rev := Revision{
+		Change:   jj.Change{ID: "abcdefgh12345678", ShortID: "abc", Description: "Add feature"},
+		State:    StateSuccess,
+		PRNumber: 1,
+		Checks: github.NewChecks([]github.Check{
+			{Name: "build", Status: github.CheckSuccess},
+			{Name: "lint", Status: github.CheckFailure},
+			{Name: "test", Status: github.CheckFailure},
+		}),
+	}
+	output := rev.View(spinner, true, opts)
+	assert.Contains(t, output, "pull/1  "+GraphError+" checks\n")
+	assert.Contains(t, output, "Failing: lint, test")
+
+	rev.Checks = github.NewChecks([]github.Check{{Name: "build", Status: github.CheckPending}})
+	assert.Contains(t, rev.View(spinner, true, opts), GraphPending+" checks")
+
+	rev.Checks = github.NewChecks(nil)
+	assert.NotContains(t, rev.View(spinner, true, opts), "checks")
+
+	rev.AutoMerge = "squash"
+	assert.Contains(t, rev.View(spinner, true, opts), "pull/1  auto-merge: squash\n")
+}
+
+func TestStackCheckStatus(t *testing.T) {
+	stack := NewStack([]jj.Change{{ID: "a"}, {ID: "b"}}, "main")
+	assert.Equal(t, github.CheckNone, stack.CheckStatus())
+
+	stack.SetRevisionChecks("a", github.NewChecks([]github.Check{{Name: "build", Status: github.CheckSuccess}}))
+	assert.Equal(t, github.CheckSuccess, stack.CheckStatus())
+
+	stack.SetRevisionChecks("b", github.NewChecks([]github.Check{{Name: "build", Status: github.CheckPending}}))
+	assert.Equal(t, github.CheckPending, stack.CheckStatus())
+
+	stack.SetRevisionChecks("b", github.NewChecks([]github.Check{{Name: "build", Status: github.CheckFailure}}))
+	assert.Equal(t, github.CheckFailure, stack.CheckStatus())
+}
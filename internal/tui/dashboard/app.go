@@ -0,0 +1,475 @@
+// Package dashboard is a full-screen view of the pull requests in a stack,
+// refreshed on an interval, from which revisions can be submitted, opened in
+// the browser and landed.
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cbrewster/jj-github/internal/browse"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/journal"
+	"github.com/cbrewster/jj-github/internal/land"
+	"github.com/cbrewster/jj-github/internal/reviews"
+	"github.com/cbrewster/jj-github/internal/tui/components"
+	"github.com/cbrewster/jj-github/internal/tui/submit"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// refreshInterval is how often the dashboard is refreshed by default
+const refreshInterval = 30 * time.Second
+
+// titleWidth is the width of the title column
+const titleWidth = 40
+
+// Help separator between key bindings
+const helpSeparator = " • "
+
+// GitHub is the GitHub functionality used by the dashboard.
+type GitHub interface {
+	reviews.GitHub
+}
+
+// Row is a revision in the stack with the state of its pull request.
+type Row struct {
+	reviews.Revision
+	NeedsPush bool // The revision differs from its pull request, or has none
+}
+
+// Messages for async operations
+type (
+	RefreshedMsg struct {
+		Rows []Row
+		At   time.Time
+		Err  error
+	}
+
+	// ExecDoneMsg is sent when a submit or land run for a row exits.
+	ExecDoneMsg struct {
+		Action string
+		Err    error
+	}
+
+	// OpenedMsg is sent once a URL was opened in the browser.
+	OpenedMsg struct {
+		URL string
+		Err error
+	}
+
+	// tickMsg asks for the next automatic refresh.
+	tickMsg struct{}
+)
+
+// Options configures optional dashboard behavior
+type Options struct {
+	// Interval is how often the stack is refreshed. Defaults to
+	// refreshInterval. If negative, the stack is only refreshed on demand.
+	Interval time.Duration
+	// Branches maps change IDs to the branches of adopted pull requests.
+	Branches journal.Branches
+	// Command is the jj-github command run with `submit` or `land` and the
+	// selected change ID, e.g. the running executable.
+	Command []string
+	// ExecProcess runs Command, suspending the program until it exits.
+	// Defaults to tea.ExecProcess.
+	ExecProcess func(*exec.Cmd, tea.ExecCallback) tea.Cmd
+	// Open opens a URL in the browser. If nil, URLs are shown instead.
+	Open func(url string) error
+}
+
+// Model is the bubbletea model for the dashboard
+type Model struct {
+	rows        []Row
+	cursor      int // Index into rows, which are ordered parents first
+	refreshing  bool
+	refreshedAt time.Time
+	err         error  // Error from the last refresh
+	notice      string // Result of the last action
+	noticeErr   error
+	spinner     components.Spinner
+	keys        KeyMap
+	width       int
+
+	interval    time.Duration
+	branches    journal.Branches
+	command     []string
+	execProcess func(*exec.Cmd, tea.ExecCallback) tea.Cmd
+	open        func(url string) error
+
+	ctx    context.Context
+	jjRepo browse.Repo
+	gh     GitHub
+	repo   github.Repo
+	revset string
+}
+
+// NewModel creates a dashboard for the stacks containing revset
+func NewModel(ctx context.Context, jjRepo browse.Repo, gh GitHub, repo github.Repo, revset string, opts Options) Model {
+	m := Model{
+		refreshing:  true,
+		spinner:     components.NewSpinner(),
+		keys:        DefaultKeyMap(),
+		interval:    opts.Interval,
+		branches:    opts.Branches,
+		command:     opts.Command,
+		execProcess: opts.ExecProcess,
+		open:        opts.Open,
+		ctx:         ctx,
+		jjRepo:      jjRepo,
+		gh:          gh,
+		repo:        repo,
+		revset:      revset,
+	}
+	if m.interval == 0 {
+		m.interval = refreshInterval
+	}
+	if m.execProcess == nil {
+		m.execProcess = tea.ExecProcess
+	}
+	return m
+}
+
+// Init starts loading the stack and the refresh timer
+func (m Model) Init() tea.Cmd {
+	return tea.Batch(m.spinner.Tick(), m.refreshCmd(), m.tickCmd())
+}
+
+// Update handles messages and updates the model
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Up):
+			m.cursor = max(min(m.cursor+1, len(m.rows)-1), 0)
+		case key.Matches(msg, m.keys.Down):
+			m.cursor = max(m.cursor-1, 0)
+		case key.Matches(msg, m.keys.Refresh):
+			return m.refresh()
+		case key.Matches(msg, m.keys.Submit):
+			if row, ok := m.selected(); ok {
+				return m.run("submit", row)
+			}
+		case key.Matches(msg, m.keys.Land):
+			if row, ok := m.selected(); ok {
+				if err := land.Check(m.targets(), row.Target); err != nil {
+					m.notice, m.noticeErr = "", err
+					return m, nil
+				}
+				return m.run("land", row)
+			}
+		case key.Matches(msg, m.keys.Open):
+			if row, ok := m.selected(); ok {
+				return m.openRow(row)
+			}
+		}
+		return m, nil
+
+	case tickMsg:
+		if m.refreshing {
+			return m, m.tickCmd()
+		}
+		m.refreshing = true
+		return m, tea.Batch(m.refreshCmd(), m.tickCmd())
+
+	case RefreshedMsg:
+		m.refreshing = false
+		m.err = msg.Err
+		if msg.Err != nil {
+			// Keep showing the last known state.
+			return m, nil
+		}
+
+		var selected string
+		if row, ok := m.selected(); ok {
+			selected = row.Change.ID
+		}
+		m.rows = msg.Rows
+		m.refreshedAt = msg.At
+		m.cursor = slices.IndexFunc(m.rows, func(row Row) bool { return row.Change.ID == selected })
+		if m.cursor == -1 {
+			// Start at the top of the stack.
+			m.cursor = max(len(m.rows)-1, 0)
+		}
+		return m, nil
+
+	case ExecDoneMsg:
+		if msg.Err != nil {
+			m.notice, m.noticeErr = "", fmt.Errorf("%s: %w", msg.Action, msg.Err)
+		} else {
+			m.notice, m.noticeErr = msg.Action+" finished", nil
+		}
+		return m.refresh()
+
+	case OpenedMsg:
+		if msg.Err != nil {
+			m.notice, m.noticeErr = "", fmt.Errorf("open %s: %w", msg.URL, msg.Err)
+		} else {
+			m.notice, m.noticeErr = "Opened "+msg.URL, nil
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.spinner, cmd = m.spinner.Update(msg)
+	return m, cmd
+}
+
+// refresh starts reloading the stack, unless a refresh is already running.
+func (m Model) refresh() (tea.Model, tea.Cmd) {
+	if m.refreshing {
+		return m, nil
+	}
+	m.refreshing = true
+	return m, m.refreshCmd()
+}
+
+// run runs the jj-github command action, e.g. submit, for row.
+func (m Model) run(action string, row Row) (tea.Model, tea.Cmd) {
+	if len(m.command) == 0 {
+		m.notice, m.noticeErr = "", fmt.Errorf("%s: no jj-github command configured", action)
+		return m, nil
+	}
+	m.notice, m.noticeErr = "", nil
+	args := append(slices.Clone(m.command[1:]), action, row.Change.ID)
+	return m, m.execProcess(exec.Command(m.command[0], args...), func(err error) tea.Msg {
+		return ExecDoneMsg{Action: action, Err: err}
+	})
+}
+
+// openRow opens row's pull request, or the page to create one.
+func (m Model) openRow(row Row) (tea.Model, tea.Cmd) {
+	url := browse.CompareURL(m.repo, row.Target)
+	if row.PR != nil {
+		url = row.PR.GetHTMLURL()
+	}
+
+	if m.open == nil {
+		m.notice, m.noticeErr = url, nil
+		return m, nil
+	}
+	open := m.open
+	return m, func() tea.Msg {
+		return OpenedMsg{URL: url, Err: open(url)}
+	}
+}
+
+// selected returns the row under the cursor.
+func (m Model) selected() (Row, bool) {
+	if m.cursor >= len(m.rows) {
+		return Row{}, false
+	}
+	return m.rows[m.cursor], true
+}
+
+// targets returns the targets of the rows.
+func (m Model) targets() []browse.Target {
+	targets := make([]browse.Target, len(m.rows))
+	for i, row := range m.rows {
+		targets[i] = row.Target
+	}
+	return targets
+}
+
+// View renders the UI
+func (m Model) View() string {
+	var sb strings.Builder
+
+	sb.WriteString(components.TitleStyle.Render(m.repo.Owner + "/" + m.repo.Name))
+	switch {
+	case m.refreshing:
+		sb.WriteString("  " + m.spinner.View() + " Refreshing...")
+	case !m.refreshedAt.IsZero():
+		sb.WriteString("  " + components.MutedStyle.Render("Refreshed at "+m.refreshedAt.Format(time.TimeOnly)))
+	}
+	sb.WriteString("\n\n")
+
+	for i, row := range slices.Backward(m.rows) {
+		sb.WriteString(m.renderRow(row, i == m.cursor))
+		sb.WriteString("\n")
+	}
+	if len(m.rows) > 0 {
+		fmt.Fprintf(&sb, "  %s %s\n", components.MutedStyle.Render(components.GraphTrunk), components.MutedStyle.Render(m.rows[0].Base))
+	}
+
+	if row, ok := m.selected(); ok {
+		if details := renderDetails(row); details != "" {
+			sb.WriteString("\n")
+			sb.WriteString(details)
+		}
+	}
+
+	sb.WriteString("\n")
+	switch {
+	case m.err != nil:
+		sb.WriteString(components.ErrorStyle.Render(m.err.Error()))
+		sb.WriteString("\n")
+		sb.WriteString(components.HintView(m.err))
+	case m.noticeErr != nil:
+		sb.WriteString(components.ErrorStyle.Render(m.noticeErr.Error()))
+		sb.WriteString("\n")
+	case m.notice != "":
+		sb.WriteString(components.MutedStyle.Render(m.notice))
+		sb.WriteString("\n")
+	}
+	sb.WriteString(renderHelp(m.keys))
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// renderRow renders a revision and the state of its pull request on one line.
+func (m Model) renderRow(row Row, selected bool) string {
+	cursor := "  "
+	if selected {
+		cursor = components.AccentStyle.Render("> ")
+	}
+
+	title, _, _ := strings.Cut(row.Change.Description, "\n")
+	parts := []string{
+		cursor + components.MutedStyle.Render(components.GraphPending) + " " +
+			components.ChangeIDShortStyle.Render(row.Change.ShortID) + " " +
+			lipgloss.NewStyle().Width(titleWidth).Render(components.TruncateString(title, titleWidth)),
+	}
+
+	if row.PR == nil {
+		parts = append(parts, components.MutedStyle.Render("no pull request"))
+	} else {
+		parts = append(parts, prState(row))
+		if row.PR.GetState() == "open" {
+			if row.Reviews != nil {
+				parts = append(parts, reviews.Decision(row.Reviews))
+				if threads := len(row.Reviews.Threads); threads > 0 {
+					parts = append(parts, components.YellowStyle.Render(fmt.Sprintf("%d unresolved", threads)))
+				}
+			}
+			if checks := components.ChecksView(row.Checks); checks != "" {
+				parts = append(parts, checks)
+			}
+			switch row.Mergeable {
+			case "MERGEABLE":
+				parts = append(parts, components.SuccessStyle.Render("mergeable"))
+			case "CONFLICTING":
+				parts = append(parts, components.ErrorStyle.Render("conflicts"))
+			}
+			if autoMerge := components.AutoMergeView(github.AutoMergeMethod(row.PR)); autoMerge != "" {
+				parts = append(parts, autoMerge)
+			}
+		}
+	}
+
+	if row.NeedsPush {
+		parts = append(parts, components.YellowStyle.Render("needs push"))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// prState renders the number and state of row's pull request.
+func prState(row Row) string {
+	number := components.PRLinkStyle.Render(fmt.Sprintf("#%d", row.PR.GetNumber()))
+	switch {
+	case row.PR.GetMerged():
+		return number + " " + components.MutedStyle.Render("merged")
+	case row.PR.GetState() != "open":
+		return number + " " + components.MutedStyle.Render(row.PR.GetState())
+	case row.PR.GetDraft():
+		return number + " " + components.MutedStyle.Render("draft")
+	}
+	return number
+}
+
+// renderDetails renders the failing checks and unresolved threads of the selected row.
+func renderDetails(row Row) string {
+	var sb strings.Builder
+	if row.Checks != nil {
+		if failed := row.Checks.Failed(); len(failed) > 0 {
+			sb.WriteString(components.ErrorStyle.Render("Failing: " + strings.Join(failed, ", ")))
+			sb.WriteString("\n")
+		}
+	}
+	if row.Reviews != nil {
+		for _, thread := range row.Reviews.Threads {
+			location := thread.Path
+			if thread.Line > 0 {
+				location = fmt.Sprintf("%s:%d", thread.Path, thread.Line)
+			}
+			if thread.Outdated {
+				location += " (outdated)"
+			}
+			sb.WriteString(components.MutedStyle.Render(location + "  " + thread.Author))
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// renderHelp renders the key bindings, with quit muted
+func renderHelp(keys KeyMap) string {
+	var b strings.Builder
+	for _, k := range keys.ShortHelp() {
+		if b.Len() > 0 {
+			b.WriteString(components.MutedStyle.Render(helpSeparator))
+		}
+
+		style := components.AccentStyle
+		if k.Help().Key == keys.Quit.Help().Key {
+			style = components.MutedStyle
+		}
+		b.WriteString(style.Render(k.Help().Key))
+		b.WriteString(" ")
+		b.WriteString(style.Render(k.Help().Desc))
+	}
+	return b.String()
+}
+
+// Commands for async operations
+
+func (m Model) tickCmd() tea.Cmd {
+	if m.interval < 0 {
+		return nil
+	}
+	return tea.Tick(m.interval, func(time.Time) tea.Msg { return tickMsg{} })
+}
+
+func (m Model) refreshCmd() tea.Cmd {
+	return func() tea.Msg {
+		targets, err := browse.Resolve(m.ctx, m.gh, m.jjRepo, m.repo, m.revset, true, m.branches)
+		if err != nil {
+			return RefreshedMsg{Err: err}
+		}
+
+		revisions, err := reviews.Load(m.ctx, m.gh, m.repo, targets)
+		if err != nil {
+			return RefreshedMsg{Err: err}
+		}
+
+		inStack := make(map[string]bool, len(targets))
+		for _, target := range targets {
+			inStack[target.Change.ID] = true
+		}
+
+		rows := make([]Row, len(revisions))
+		for i, revision := range revisions {
+			// The dashboard submits without --auto-merge, so only trailers count.
+			bottom := len(revision.Change.Parents) == 0 || !inStack[revision.Change.Parents[0].ChangeID]
+			needsPush, err := submit.NeedsSync(revision.Change, revision.PR, revision.Base, bottom, "")
+			if err != nil {
+				return RefreshedMsg{Err: err}
+			}
+			rows[i] = Row{Revision: revision, NeedsPush: needsPush}
+		}
+		return RefreshedMsg{Rows: rows, At: time.Now()}
+	}
+}
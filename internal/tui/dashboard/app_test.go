@@ -0,0 +1,172 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/tui/tuitest"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+// newTestModel returns a dashboard over trunk <- a <- b <- c, where a and b
+// have PRs and b was amended since it was pushed.
+func newTestModel(t *testing.T, opts Options) (*tuitest.Driver, *githubtest.Fake) {
+	t.Helper()
+
+	trunk := jjtest.Trunk("trunk", "main")
+	a := jjtest.Change("aaaaaaaa", "Add feature A", "trunk")
+	b := jjtest.Change("bbbbbbbb", "Add feature B", "aaaaaaaa")
+	c := jjtest.Change("cccccccc", "Add feature C", "bbbbbbbb")
+
+	gh := githubtest.NewFake()
+	gh.AddStack("main", a, b)
+	b.CommitID = "amended"
+
+	jjRepo, runner := jjtest.NewClient()
+	runner.OnStack(trunk, a, b, c)
+
+	opts.Interval = -1
+	model := NewModel(context.Background(), jjRepo, gh, testRepo, "@", opts)
+	return tuitest.New(t, model), gh
+}
+
+func rows(d *tuitest.Driver) []Row {
+	return d.Model().(Model).rows
+}
+
+func selected(d *tuitest.Driver) string {
+	row, _ := d.Model().(Model).selected()
+	return row.Change.ID
+}
+
+func TestDashboardShowsStack(t *testing.T) {
+	d, gh := newTestModel(t, Options{})
+	gh.AddReview(1, "alice", "APPROVED")
+	gh.SetChecks(1, github.Check{Name: "build", Status: github.CheckSuccess})
+	gh.AddReviewThread(2, github.ReviewThread{Path: "main.go", Line: 12, Author: "bob", Body: "Typo"})
+	gh.SetChecks(2, github.Check{Name: "lint", Status: github.CheckFailure})
+	gh.SetMergeable(2, "CONFLICTING")
+	require.NoError(t, gh.EnableAutoMerge(context.Background(), testRepo, gh.PullRequests()[0], "squash"))
+
+	d.Init().Resize(120, 40)
+	require.Len(t, rows(d), 3)
+	assert.False(t, d.Quit())
+
+	rs := rows(d)
+	assert.False(t, rs[0].NeedsPush)
+	assert.True(t, rs[1].NeedsPush, "the amended revision differs from its pull request")
+	assert.True(t, rs[2].NeedsPush, "revisions without a pull request need to be submitted")
+	assert.Equal(t, "MERGEABLE", rs[0].Mergeable)
+	assert.Equal(t, "CONFLICTING", rs[1].Mergeable)
+
+	view := d.View()
+	assert.Contains(t, view, "#1  ✓ approved  ✓ checks  mergeable  auto-merge: squash")
+	assert.Contains(t, view, "#2  no reviews  1 unresolved  ✗ checks  conflicts  needs push")
+	assert.Contains(t, view, "no pull request  needs push")
+	assert.Less(t, strings.Index(view, "ccc"), strings.Index(view, "aaa"), "the top of the stack is shown first")
+
+	// The details of the selected row are shown below the stack.
+	assert.Equal(t, "cccccccc", selected(d))
+	assert.NotContains(t, view, "Failing: lint")
+	d.Key("down")
+	assert.Equal(t, "bbbbbbbb", selected(d))
+	assert.Contains(t, d.View(), "Failing: lint")
+	assert.Contains(t, d.View(), "main.go:12  bob")
+
+	d.Key("q")
+	assert.True(t, d.Quit())
+}
+
+func TestDashboardActions(t *testing.T) {
+	var ran [][]string
+	var opened []string
+	d, _ := newTestModel(t, Options{
+		Command: []string{"jj-github", "--verbose"},
+		ExecProcess: func(cmd *exec.Cmd, done tea.ExecCallback) tea.Cmd {
+			ran = append(ran, cmd.Args)
+			return func() tea.Msg { return done(nil) }
+		},
+		Open: func(url string) error {
+			opened = append(opened, url)
+			return nil
+		},
+	})
+
+	refreshes := 0
+	d.OnMsg = func(msg tea.Msg) {
+		if _, ok := msg.(RefreshedMsg); ok {
+			refreshes++
+		}
+	}
+	d.Init()
+	require.Equal(t, 1, refreshes)
+
+	d.Key("s")
+	assert.Equal(t, [][]string{{"jj-github", "--verbose", "submit", "cccccccc"}}, ran)
+	assert.Equal(t, 2, refreshes, "the stack is refreshed once submit exits")
+	assert.Contains(t, d.View(), "submit finished")
+
+	d.Key("o")
+	require.Len(t, opened, 1)
+	assert.Contains(t, opened[0], "https://github.com/owner/repo/compare/push-bbbbbbbb...push-cccccccc?")
+	assert.Contains(t, d.View(), "Opened https://github.com/owner/repo/compare/")
+
+	// Only the bottom of the stack can be landed.
+	d.Key("down").Key("l")
+	assert.Len(t, ran, 1)
+	assert.Contains(t, d.View(), "pull request #2 merges into push-aaaaaaaa, land the pull requests below it first")
+
+	d.Key("down").Key("l")
+	assert.Equal(t, []string{"jj-github", "--verbose", "land", "aaaaaaaa"}, ran[1])
+	assert.Equal(t, 3, refreshes)
+	assert.Equal(t, "aaaaaaaa", selected(d), "the selection is kept across refreshes")
+}
+
+func TestDashboardActionFails(t *testing.T) {
+	d, _ := newTestModel(t, Options{
+		Command: []string{"jj-github"},
+		ExecProcess: func(cmd *exec.Cmd, done tea.ExecCallback) tea.Cmd {
+			return func() tea.Msg { return done(errors.New("exit status 1")) }
+		},
+	})
+
+	d.Init().Key("s")
+	assert.Contains(t, d.View(), "submit: exit status 1")
+
+	// Without a browser, the URL is shown instead.
+	d.Key("o")
+	assert.Contains(t, d.View(), "https://github.com/owner/repo/compare/push-bbbbbbbb...push-cccccccc?")
+}
+
+func TestDashboardRefresh(t *testing.T) {
+	d, gh := newTestModel(t, Options{})
+	d.Init().Key("down")
+	require.Equal(t, "bbbbbbbb", selected(d))
+
+	gh.SetChecks(2, github.Check{Name: "lint", Status: github.CheckPending})
+	calls := len(gh.Calls())
+	d.Send(tickMsg{})
+	assert.Equal(t, github.CheckPending, rows(d)[1].Checks.Status)
+	assert.Equal(t, []string{"LoadStackState", "LoadReviews"}, gh.Calls()[calls:], "a refresh costs one query for the stack and one for reviews")
+	assert.Equal(t, "bbbbbbbb", selected(d))
+
+	// A failed refresh keeps showing the last known state.
+	gh.FailNext("LoadReviews", errors.New("boom"))
+	d.Key("r")
+	assert.Contains(t, d.View(), "load reviews: boom")
+	assert.Contains(t, d.View(), "○ checks")
+	assert.False(t, d.Quit())
+
+	d.Key("r")
+	assert.NotContains(t, d.View(), "boom")
+}
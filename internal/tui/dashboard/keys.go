@@ -0,0 +1,53 @@
+package dashboard
+
+import "github.com/charmbracelet/bubbles/key"
+
+// KeyMap defines the key bindings for the dashboard TUI
+type KeyMap struct {
+	Up      key.Binding
+	Down    key.Binding
+	Submit  key.Binding
+	Open    key.Binding
+	Land    key.Binding
+	Refresh key.Binding
+	Quit    key.Binding
+}
+
+// DefaultKeyMap returns the default key bindings
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Up: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑/k", "up"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓/j", "down"),
+		),
+		Submit: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "submit"),
+		),
+		Open: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "open"),
+		),
+		Land: key.NewBinding(
+			key.WithKeys("l"),
+			key.WithHelp("l", "land"),
+		),
+		Refresh: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "refresh"),
+		),
+		Quit: key.NewBinding(
+			key.WithKeys("q", "ctrl+c"),
+			key.WithHelp("q", "quit"),
+		),
+	}
+}
+
+// ShortHelp returns the bindings shown in the footer
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Up, k.Down, k.Submit, k.Open, k.Land, k.Refresh, k.Quit}
+}
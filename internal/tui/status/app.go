@@ -0,0 +1,286 @@
+// Package status shows the pull requests in a stack with the status of their
+// CI checks, optionally polling until the checks finish.
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cbrewster/jj-github/internal/browse"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/journal"
+	"github.com/cbrewster/jj-github/internal/tui/components"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Phase represents the current phase of the status view
+type Phase int
+
+const (
+	PhaseLoading Phase = iota
+	PhaseWatching
+	PhaseComplete
+	PhaseError
+)
+
+// pollInterval is how often checks are polled with --watch
+const pollInterval = 10 * time.Second
+
+// GitHub is the GitHub functionality used to show the stack's status.
+type GitHub interface {
+	browse.GitHub
+	LoadChecks(ctx context.Context, repo github.Repo, numbers []int) (map[int]*github.Checks, error)
+}
+
+// Messages for async operations
+type (
+	LoadedMsg struct {
+		Targets []browse.Target
+		Err     error
+	}
+
+	ChecksLoadedMsg struct {
+		Checks map[int]*github.Checks // Keyed by PR number
+		Err    error
+	}
+
+	// pollMsg asks for the checks to be loaded again.
+	pollMsg struct{}
+)
+
+// Options configures optional status behavior
+type Options struct {
+	// Watch keeps the TUI open, polling until every PR's checks finish.
+	Watch bool
+	// PollInterval is how often checks are polled with Watch. Defaults to pollInterval.
+	PollInterval time.Duration
+	// Branches maps change IDs to the branches of adopted pull requests.
+	Branches journal.Branches
+}
+
+// Model is the bubbletea model for the status view
+type Model struct {
+	phase   Phase
+	stack   components.Stack
+	spinner components.Spinner
+	keys    KeyMap
+	err     error
+	width   int
+
+	watch        bool
+	pollInterval time.Duration
+	branches     journal.Branches
+	targets      []browse.Target
+
+	ctx    context.Context
+	jjRepo browse.Repo
+	gh     GitHub
+	repo   github.Repo
+	revset string
+}
+
+// NewModel creates a status model for the stacks containing revset
+func NewModel(ctx context.Context, jjRepo browse.Repo, gh GitHub, repo github.Repo, revset string, opts Options) Model {
+	m := Model{
+		phase:        PhaseLoading,
+		spinner:      components.NewSpinner(),
+		keys:         DefaultKeyMap(),
+		watch:        opts.Watch,
+		pollInterval: opts.PollInterval,
+		branches:     opts.Branches,
+		ctx:          ctx,
+		jjRepo:       jjRepo,
+		gh:           gh,
+		repo:         repo,
+		revset:       revset,
+	}
+	if m.pollInterval == 0 {
+		m.pollInterval = pollInterval
+	}
+	return m
+}
+
+// Init starts loading the stack
+func (m Model) Init() tea.Cmd {
+	return tea.Batch(m.spinner.Tick(), m.loadCmd())
+}
+
+// Update handles messages and updates the model
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
+
+	case tea.KeyMsg:
+		if key.Matches(msg, m.keys.Quit) {
+			return m, tea.Quit
+		}
+
+	case LoadedMsg:
+		if msg.Err != nil {
+			m.phase = PhaseError
+			m.err = msg.Err
+			return m, tea.Quit
+		}
+
+		m.targets = msg.Targets
+		changes := make([]jj.Change, len(msg.Targets))
+		for i, target := range msg.Targets {
+			changes[i] = target.Change
+		}
+		m.stack = components.NewStack(changes, msg.Targets[0].Base)
+		for _, target := range msg.Targets {
+			if target.PR != nil {
+				m.stack.SetRevisionPR(target.Change.ID, target.PR.GetNumber())
+				m.stack.SetRevisionState(target.Change.ID, components.StateSuccess, "")
+			}
+		}
+		// Revisions without a PR need to be submitted.
+		for i := range m.stack.Revisions {
+			m.stack.Revisions[i].NeedsSync = m.stack.Revisions[i].PRNumber == 0
+		}
+		checks := make(map[int]*github.Checks)
+		for _, target := range msg.Targets {
+			if target.PR != nil {
+				checks[target.PR.GetNumber()] = target.Checks
+			}
+		}
+		return m.checksLoaded(checks)
+
+	case pollMsg:
+		return m, m.loadChecksCmd()
+
+	case ChecksLoadedMsg:
+		if msg.Err != nil {
+			m.phase = PhaseError
+			m.err = msg.Err
+			return m, tea.Quit
+		}
+		return m.checksLoaded(msg.Checks)
+	}
+
+	var cmd tea.Cmd
+	m.spinner, cmd = m.spinner.Update(msg)
+	return m, cmd
+}
+
+// checksLoaded shows the checks, polling again with --watch while any are pending.
+func (m Model) checksLoaded(checks map[int]*github.Checks) (tea.Model, tea.Cmd) {
+	for _, target := range m.targets {
+		if target.PR != nil {
+			m.stack.SetRevisionChecks(target.Change.ID, checks[target.PR.GetNumber()])
+		}
+	}
+
+	if m.watch && m.stack.CheckStatus() == github.CheckPending {
+		m.phase = PhaseWatching
+		return m, tea.Tick(m.pollInterval, func(time.Time) tea.Msg { return pollMsg{} })
+	}
+
+	m.phase = PhaseComplete
+	return m, tea.Quit
+}
+
+// Err returns the error that stopped the status from loading. With --watch,
+// it also returns an error unless every check finished and none failed, e.g.
+// because watching was quit.
+func (m Model) Err() error {
+	if m.phase == PhaseError {
+		return m.err
+	}
+	if !m.watch {
+		return nil
+	}
+	if m.phase != PhaseComplete {
+		return errors.New("stopped before every check finished")
+	}
+	if m.stack.CheckStatus() == github.CheckFailure {
+		return errors.New("checks failed")
+	}
+	return nil
+}
+
+// View renders the UI
+func (m Model) View() string {
+	var sb strings.Builder
+
+	width := m.width
+	if width == 0 {
+		width = 80
+	}
+	viewOpts := components.ViewOptions{
+		RepoOwner: m.repo.Owner,
+		RepoName:  m.repo.Name,
+		Width:     width,
+	}
+
+	switch m.phase {
+	case PhaseLoading:
+		sb.WriteString(m.spinner.View())
+		sb.WriteString(" Fetching remote state...\n")
+
+	case PhaseWatching:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		sb.WriteString(m.spinner.View())
+		sb.WriteString(" Waiting for checks to finish...\n\n")
+		sb.WriteString(components.MutedStyle.Render(m.keys.Quit.Help().Key + " " + m.keys.Quit.Help().Desc))
+		sb.WriteString("\n")
+
+	case PhaseComplete:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		switch m.stack.CheckStatus() {
+		case github.CheckFailure:
+			sb.WriteString(components.ErrorStyle.Render("Some checks failed."))
+		case github.CheckPending:
+			sb.WriteString(components.YellowStyle.Render("Some checks are still running."))
+		case github.CheckSuccess:
+			sb.WriteString(components.SuccessStyle.Render("All checks passed."))
+		default:
+			sb.WriteString(components.MutedStyle.Render("No checks ran."))
+		}
+		sb.WriteString("\n")
+
+	case PhaseError:
+		sb.WriteString(components.ErrorStyle.Render(m.err.Error()))
+		sb.WriteString("\n")
+		sb.WriteString(components.HintView(m.err))
+	}
+
+	return sb.String()
+}
+
+// Commands for async operations
+
+func (m Model) loadCmd() tea.Cmd {
+	return func() tea.Msg {
+		targets, err := browse.Resolve(m.ctx, m.gh, m.jjRepo, m.repo, m.revset, true, m.branches)
+		return LoadedMsg{Targets: targets, Err: err}
+	}
+}
+
+func (m Model) loadChecksCmd() tea.Cmd {
+	return func() tea.Msg {
+		checks, err := m.gh.LoadChecks(m.ctx, m.repo, prNumbers(m.targets))
+		if err != nil {
+			return ChecksLoadedMsg{Err: fmt.Errorf("load checks: %w", err)}
+		}
+		return ChecksLoadedMsg{Checks: checks}
+	}
+}
+
+// prNumbers returns the numbers of the targets' pull requests.
+func prNumbers(targets []browse.Target) []int {
+	var numbers []int
+	for _, target := range targets {
+		if target.PR != nil {
+			numbers = append(numbers, target.PR.GetNumber())
+		}
+	}
+	return numbers
+}
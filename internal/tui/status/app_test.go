@@ -0,0 +1,112 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cbrewster/jj-github/internal/browse"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/tui/tuitest"
+	tea "github.com/charmbracelet/bubbletea"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+// newTestModel returns a model over trunk <- a <- b, where a and b have PRs.
+func newTestModel(t *testing.T, opts Options) (*tuitest.Driver, *githubtest.Fake) {
+	t.Helper()
+
+	trunk := jjtest.Trunk("trunk", "main")
+	a := jjtest.Change("aaaaaaaa", "Add feature A", "trunk")
+	b := jjtest.Change("bbbbbbbb", "Add feature B", "aaaaaaaa")
+
+	jjRepo, runner := jjtest.NewClient()
+	runner.OnStack(trunk, a, b)
+	gh := githubtest.NewFake()
+	gh.AddStack("main", a, b)
+
+	opts.PollInterval = time.Millisecond
+	model := NewModel(context.Background(), jjRepo, gh, testRepo, "@", opts)
+	return tuitest.New(t, model), gh
+}
+
+func phase(d *tuitest.Driver) Phase {
+	return d.Model().(Model).phase
+}
+
+func TestStatusShowsChecks(t *testing.T) {
+	d, gh := newTestModel(t, Options{})
+	gh.SetChecks(1, github.Check{Name: "build", Status: github.CheckSuccess})
+	gh.SetChecks(2, github.Check{Name: "build", Status: github.CheckPending})
+
+	d.Init()
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.True(t, d.Quit(), "without --watch, pending checks aren't waited for")
+	assert.Contains(t, d.View(), "pull/1  ✓ checks")
+	assert.Contains(t, d.View(), "pull/2  ○ checks")
+	assert.Contains(t, d.View(), "Some checks are still running.")
+	assert.NoError(t, d.Model().(Model).Err())
+}
+
+func TestStatusWatch(t *testing.T) {
+	d, gh := newTestModel(t, Options{Watch: true})
+	gh.SetChecks(1, github.Check{Name: "build", Status: github.CheckPending})
+	gh.SetChecks(2, github.Check{Name: "lint", Status: github.CheckPending})
+
+	polls := 0
+	d.OnMsg = func(msg tea.Msg) {
+		if _, ok := msg.(ChecksLoadedMsg); !ok {
+			return
+		}
+		// The checks finish over two polls.
+		polls++
+		switch polls {
+		case 1:
+			gh.SetChecks(1, github.Check{Name: "build", Status: github.CheckSuccess})
+		case 2:
+			gh.SetChecks(2, github.Check{Name: "lint", Status: github.CheckFailure})
+		}
+	}
+
+	d.Init()
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.True(t, d.Quit())
+	assert.Equal(t, 3, polls)
+	assert.Contains(t, d.View(), "Failing: lint")
+	assert.Contains(t, d.View(), "Some checks failed.")
+	assert.EqualError(t, d.Model().(Model).Err(), "checks failed")
+}
+
+func TestStatusError(t *testing.T) {
+	d, gh := newTestModel(t, Options{Watch: true})
+	gh.SetChecks(1, github.Check{Name: "build", Status: github.CheckPending})
+	gh.FailNext("LoadChecks", errors.New("boom"))
+
+	d.Init()
+	require.Equal(t, PhaseError, phase(d))
+	assert.True(t, d.Quit())
+	assert.Contains(t, d.View(), "load checks: boom")
+	assert.EqualError(t, d.Model().(Model).Err(), "load checks: boom")
+}
+
+func TestStatusWatchQuit(t *testing.T) {
+	a := jjtest.Change("aaaaaaaa", "Add feature A", "trunk")
+	pr := &gogithub.PullRequest{Number: gogithub.Ptr(1)}
+	m := NewModel(context.Background(), nil, nil, testRepo, "@", Options{Watch: true})
+
+	checks := github.NewChecks([]github.Check{{Name: "build", Status: github.CheckPending}})
+	model, _ := m.Update(LoadedMsg{
+		Targets: []browse.Target{{Change: a, Branch: "push-aaaaaaaa", Base: "main", PR: pr, Checks: checks}},
+	})
+	require.Equal(t, PhaseWatching, model.(Model).phase)
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	assert.EqualError(t, model.(Model).Err(), "stopped before every check finished")
+}
@@ -0,0 +1,18 @@
+package status
+
+import "github.com/charmbracelet/bubbles/key"
+
+// KeyMap defines the key bindings for the status TUI
+type KeyMap struct {
+	Quit key.Binding
+}
+
+// DefaultKeyMap returns the default key bindings
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Quit: key.NewBinding(
+			key.WithKeys("q", "ctrl+c"),
+			key.WithHelp("q", "quit"),
+		),
+	}
+}
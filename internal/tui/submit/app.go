@@ -2,11 +2,14 @@ package submit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/cbrewster/jj-github/internal/github"
 	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/journal"
 	"github.com/cbrewster/jj-github/internal/tui/components"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
@@ -23,6 +26,7 @@ const (
 	PhaseConfirmation
 	PhaseSyncing
 	PhaseUpdatingComments
+	PhaseWaitingForChecks
 	PhaseComplete
 	PhaseError
 )
@@ -30,32 +34,53 @@ const (
 // Help separator between key bindings
 const helpSeparator = " • "
 
+// lowRateLimitFraction is the fraction of the API budget below which a warning is shown
+const lowRateLimitFraction = 0.1
+
+// checkPollInterval is how often checks are polled with --wait
+const checkPollInterval = 10 * time.Second
+
+// stackCommentMarker identifies the stack comment managed by jj-github
+const stackCommentMarker = "<!-- managed-by: jj-github -->"
+
 // Messages for async operations
 type (
 	RevisionsLoadedMsg struct {
 		Changes       []jj.Change
 		TrunkName     string
 		ExistingPRs   map[string]*gogithub.PullRequest
+		StackComments map[int]*gogithub.IssueComment
+		Checks        map[int]*github.Checks // Keyed by PR number
 		NeedsSync     bool
 		NeedsSyncByID map[string]bool // Maps change ID to whether it needs sync
 		Err           error
 	}
 
 	RevisionPushedMsg struct {
-		Change jj.Change
-		Err    error
+		Change  jj.Change
+		Created bool // The branch didn't exist on origin before the push
+		Err     error
 	}
 
 	RevisionSyncedMsg struct {
 		ChangeID string
-		PRNumber int
+		PR       *gogithub.PullRequest
 		Created  bool
+		Warning  string // Set if the PR was synced but a follow-up step failed
 		Err      error
 	}
 
 	AllCommentsUpdatedMsg struct {
 		Err error
 	}
+
+	ChecksLoadedMsg struct {
+		Checks map[int]*github.Checks // Keyed by PR number
+		Err    error
+	}
+
+	// pollChecksMsg asks for the checks to be loaded again.
+	pollChecksMsg struct{}
 )
 
 // Model is the main bubbletea model for the TUI
@@ -69,12 +94,29 @@ type Model struct {
 	width   int // Terminal width
 
 	// Tracking sync progress
-	currentIndex int
-	totalCount   int
+	sched      scheduler
+	totalCount int
+	retryPhase Phase             // Phase to return to when retrying after an error
+	journal    *journal.Journal  // Progress of this run, persisted for --resume
+	snapshot   *journal.Snapshot // Operation to restore and GitHub changes, for `jj github undo`
+	stateDir   string
+	resume     bool
+	branches   journal.Branches // Branches of adopted PRs, keyed by change ID
+
+	// autoMerge is the merge method to auto-merge PRs with, unless a
+	// revision's Auto-Merge trailer says otherwise
+	autoMerge string
+
+	// Waiting for checks
+	wait         bool
+	pollInterval time.Duration
+	donePhase    Phase // Phase to show once checks finish
+	checksDone   bool  // Every check finished while waiting
 
 	// Dependencies
 	ctx    context.Context
-	gh     *github.Client
+	jjRepo jj.Repo
+	gh     github.API
 	repo   github.Repo
 	revset string
 
@@ -85,18 +127,67 @@ type Model struct {
 	stackComments map[int]*gogithub.IssueComment
 }
 
+// Options configures optional submit behavior
+type Options struct {
+	// StateDir is where the run journal is kept. If empty, no journal is kept.
+	StateDir string
+	// Resume continues the run recorded in this journal instead of starting a new one.
+	Resume *journal.Journal
+	// Snapshot is saved once pushing starts, and records the branches and
+	// PRs this run creates. If nil, no snapshot is kept.
+	Snapshot *journal.Snapshot
+	// Branches maps change IDs to the branches of adopted pull requests,
+	// which are pushed to instead of the changes' push bookmarks.
+	Branches journal.Branches
+	// Wait keeps the TUI open after submitting, polling until every PR's
+	// checks finish.
+	Wait bool
+	// PollInterval is how often checks are polled with Wait. Defaults to
+	// checkPollInterval.
+	PollInterval time.Duration
+	// AutoMerge enables auto-merge with this merge method for PRs at the
+	// bottom of the stack. Revisions can also ask for it with an Auto-Merge
+	// trailer.
+	AutoMerge string
+}
+
 // NewModel creates a new TUI model
-func NewModel(ctx context.Context, gh *github.Client, repo github.Repo, revset string) Model {
-	return Model{
-		phase:       PhaseLoading,
-		spinner:     components.NewSpinner(),
-		keys:        DefaultKeyMap(),
-		ctx:         ctx,
-		gh:          gh,
-		repo:        repo,
-		revset:      revset,
-		existingPRs: make(map[string]*gogithub.PullRequest),
+func NewModel(
+	ctx context.Context,
+	jjRepo jj.Repo,
+	gh github.API,
+	repo github.Repo,
+	revset string,
+	opts Options,
+) Model {
+	m := Model{
+		phase:        PhaseLoading,
+		spinner:      components.NewSpinner(),
+		keys:         DefaultKeyMap(),
+		ctx:          ctx,
+		jjRepo:       jjRepo,
+		gh:           gh,
+		repo:         repo,
+		revset:       revset,
+		existingPRs:  make(map[string]*gogithub.PullRequest),
+		stateDir:     opts.StateDir,
+		snapshot:     opts.Snapshot,
+		branches:     opts.Branches,
+		wait:         opts.Wait,
+		pollInterval: opts.PollInterval,
+		autoMerge:    opts.AutoMerge,
+	}
+	if m.pollInterval == 0 {
+		m.pollInterval = checkPollInterval
+	}
+
+	if opts.Resume != nil {
+		m.journal = opts.Resume
+		m.revset = opts.Resume.Revset
+		m.resume = true
 	}
+
+	return m
 }
 
 // Init initializes the model and starts loading revisions
@@ -121,9 +212,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, m.keys.Quit):
 			return m, tea.Quit
 		case key.Matches(msg, m.keys.Submit) && m.phase == PhaseConfirmation:
-			m.phase = PhaseSyncing
-			m.currentIndex = 0
-			return m, m.pushNextRevisionCmd()
+			return m.startSyncing()
+		case key.Matches(msg, m.keys.Retry) && m.phase == PhaseError:
+			return m.retry()
+		case key.Matches(msg, m.keys.Skip) && m.phase == PhaseError:
+			return m.skip()
 		}
 
 	case RevisionsLoadedMsg:
@@ -136,6 +229,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.changes = msg.Changes
 		m.trunkName = msg.TrunkName
 		m.existingPRs = msg.ExistingPRs
+		m.stackComments = msg.StackComments
 		m.stack = components.NewStack(msg.Changes, msg.TrunkName)
 		m.totalCount = len(m.stack.MutableRevisions())
 
@@ -151,6 +245,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			if pr, ok := m.existingPRs[rev.Change.GitPushBookmark]; ok {
 				rev.PRNumber = pr.GetNumber()
+				rev.Checks = msg.Checks[pr.GetNumber()]
+				rev.AutoMerge = github.AutoMergeMethod(pr)
 				if !msg.NeedsSync {
 					// Mark as success if everything is up to date
 					rev.State = components.StateSuccess
@@ -158,9 +254,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		if m.resume {
+			return m.startSyncing()
+		}
+
 		if !msg.NeedsSync {
 			m.phase = PhaseUpToDate
-			return m, tea.Quit
+			return m.waitForChecks()
 		}
 
 		m.phase = PhaseConfirmation
@@ -169,43 +269,85 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case RevisionPushedMsg:
 		if msg.Err != nil {
 			m.stack.SetRevisionError(msg.Change.ID, msg.Err)
-			m.phase = PhaseError
-			m.err = msg.Err
-			return m, nil
+			for _, id := range m.sched.pushFailed(msg.Change.ID, msg.Err) {
+				m.stack.SetRevisionState(id, components.StateError, "Skipped: parent failed to push")
+			}
+		} else {
+			m.sched.pushed(msg.Change.ID)
+			m.stack.SetRevisionState(msg.Change.ID, components.StateInProgress, "Waiting to sync PR...")
+			// Checks ran on the previous commit.
+			m.stack.SetRevisionChecks(msg.Change.ID, nil)
+			if m.journal != nil {
+				entry := m.journal.Entry(msg.Change.ID)
+				entry.CommitID = msg.Change.CommitID
+				entry.Branch = msg.Change.GitPushBookmark
+				entry.Pushed = true
+				entry.Synced = false
+				m.saveJournal()
+			}
+			if m.snapshot != nil {
+				m.snapshot.Pushed(msg.Change.GitPushBookmark, msg.Created)
+				m.saveSnapshot()
+			}
 		}
 
-		// Push succeeded, now sync the PR
-		return m, m.syncRevisionPRCmd(msg.Change)
+		return m.afterStepCompleted()
 
 	case RevisionSyncedMsg:
+		// The PR is recorded even if a later step failed, so a retry
+		// doesn't open it again.
+		if msg.PR != nil {
+			m.existingPRs[msg.PR.GetHead().GetRef()] = msg.PR
+			m.stack.SetRevisionPR(msg.ChangeID, msg.PR.GetNumber())
+			m.stack.SetRevisionAutoMerge(msg.ChangeID, github.AutoMergeMethod(msg.PR))
+			if m.snapshot != nil && msg.Created {
+				m.snapshot.CreatedPR(msg.PR.GetNumber())
+				m.saveSnapshot()
+			}
+		}
+
 		if msg.Err != nil {
 			m.stack.SetRevisionError(msg.ChangeID, msg.Err)
-			m.phase = PhaseError
-			m.err = msg.Err
-			return m, nil
+			m.sched.syncFailed(msg.ChangeID, msg.Err)
+		} else {
+			m.stack.SetRevisionState(msg.ChangeID, components.StateSuccess, msg.Warning)
+			m.sched.synced(msg.ChangeID)
+			if m.journal != nil {
+				entry := m.journal.Entry(msg.ChangeID)
+				entry.PRNumber = msg.PR.GetNumber()
+				entry.Synced = true
+				m.saveJournal()
+			}
 		}
 
-		m.stack.SetRevisionPR(msg.ChangeID, msg.PRNumber)
-		m.stack.SetRevisionState(msg.ChangeID, components.StateSuccess, "")
-		m.currentIndex++
+		return m.afterStepCompleted()
 
-		mutableRevs := m.stack.MutableRevisions()
-		if m.currentIndex < len(mutableRevs) {
-			return m, m.pushNextRevisionCmd()
+	case AllCommentsUpdatedMsg:
+		if msg.Err != nil {
+			return m.fail(PhaseUpdatingComments, msg.Err)
 		}
 
-		// Move to comments phase
-		m.phase = PhaseUpdatingComments
-		return m, m.updateAllCommentsCmd()
+		return m.complete()
 
-	case AllCommentsUpdatedMsg:
+	case pollChecksMsg:
+		return m, m.loadChecksCmd()
+
+	case ChecksLoadedMsg:
 		if msg.Err != nil {
-			m.phase = PhaseError
-			m.err = msg.Err
-			return m, nil
+			return m.fail(PhaseWaitingForChecks, msg.Err)
+		}
+
+		for _, rev := range m.stack.MutableRevisions() {
+			if pr, ok := m.existingPRs[rev.Change.GitPushBookmark]; ok {
+				m.stack.SetRevisionChecks(rev.Change.ID, msg.Checks[pr.GetNumber()])
+			}
+		}
+		if m.stack.CheckStatus() == github.CheckPending {
+			return m, m.pollChecksCmd()
 		}
 
-		m.phase = PhaseComplete
+		m.checksDone = true
+		m.phase = m.donePhase
 		return m, tea.Quit
 	}
 
@@ -217,6 +359,178 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// afterStepCompleted schedules more work, or moves on once every revision
+// has either succeeded or failed.
+func (m Model) afterStepCompleted() (tea.Model, tea.Cmd) {
+	if !m.sched.done() {
+		return m, m.scheduleCmd()
+	}
+
+	if err := m.sched.err(); err != nil {
+		return m.fail(PhaseSyncing, err)
+	}
+
+	// Move to comments phase
+	m.phase = PhaseUpdatingComments
+	return m, m.updateAllCommentsCmd()
+}
+
+// startSyncing starts pushing and syncing revisions. When resuming, steps
+// recorded in the journal for unchanged commits are not repeated.
+func (m Model) startSyncing() (tea.Model, tea.Cmd) {
+	m.phase = PhaseSyncing
+
+	var mutableChanges []jj.Change
+	for _, change := range m.changes {
+		if !change.Immutable {
+			mutableChanges = append(mutableChanges, change)
+		}
+	}
+	m.sched = newScheduler(mutableChanges, syncConcurrency)
+
+	if m.journal == nil && m.stateDir != "" {
+		m.journal = journal.New(m.stateDir, m.revset)
+		m.saveJournal()
+	}
+	m.saveSnapshot()
+
+	if m.resume {
+		for _, change := range mutableChanges {
+			entry, ok := m.journal.Revisions[change.ID]
+			if !ok || !entry.Pushed || entry.CommitID != change.CommitID {
+				continue
+			}
+
+			m.sched.pushed(change.ID)
+			if !entry.Synced {
+				continue
+			}
+
+			m.sched.synced(change.ID)
+			m.stack.SetRevisionPR(change.ID, entry.PRNumber)
+			m.stack.SetRevisionState(change.ID, components.StateSuccess, "")
+			// The PR may not be visible to the lookup yet if it was just created.
+			if _, ok := m.existingPRs[change.GitPushBookmark]; !ok {
+				m.existingPRs[change.GitPushBookmark] = &gogithub.PullRequest{
+					Number: gogithub.Ptr(entry.PRNumber),
+					State:  gogithub.Ptr("open"),
+					Head:   &gogithub.PullRequestBranch{Ref: gogithub.Ptr(change.GitPushBookmark)},
+				}
+			}
+		}
+	}
+
+	return m.afterStepCompleted()
+}
+
+// fail moves to the error phase, remembering which phase to retry.
+func (m Model) fail(phase Phase, err error) (tea.Model, tea.Cmd) {
+	m.phase = PhaseError
+	m.retryPhase = phase
+	m.err = err
+	m.keys = ErrorKeyMap()
+	return m, nil
+}
+
+// retry re-attempts whatever failed.
+func (m Model) retry() (tea.Model, tea.Cmd) {
+	m.err = nil
+	m.keys = DefaultKeyMap()
+
+	if m.retryPhase == PhaseUpdatingComments {
+		m.phase = PhaseUpdatingComments
+		return m, m.updateAllCommentsCmd()
+	}
+	if m.retryPhase == PhaseWaitingForChecks {
+		m.phase = PhaseWaitingForChecks
+		return m, m.loadChecksCmd()
+	}
+
+	m.phase = PhaseSyncing
+	for _, id := range m.sched.retryFailed() {
+		m.stack.SetRevisionState(id, components.StatePending, "")
+	}
+	return m, m.scheduleCmd()
+}
+
+// skip moves on without whatever failed.
+func (m Model) skip() (tea.Model, tea.Cmd) {
+	m.err = nil
+	m.keys = DefaultKeyMap()
+
+	if m.retryPhase == PhaseUpdatingComments {
+		return m.complete()
+	}
+	if m.retryPhase == PhaseWaitingForChecks {
+		m.phase = m.donePhase
+		return m, tea.Quit
+	}
+
+	for _, id := range m.sched.skipFailed() {
+		m.stack.SetRevisionState(id, components.StateError, "Skipped")
+	}
+	return m.afterStepCompleted()
+}
+
+// complete finishes the run and removes its journal.
+func (m Model) complete() (tea.Model, tea.Cmd) {
+	if m.journal != nil {
+		// Best-effort: a stale journal only matters if --resume is used.
+		_ = m.journal.Remove()
+	}
+
+	m.phase = PhaseComplete
+	return m.waitForChecks()
+}
+
+// waitForChecks polls the checks of every PR with --wait, returning to the
+// current phase once they finish. Otherwise it quits right away.
+func (m Model) waitForChecks() (tea.Model, tea.Cmd) {
+	if !m.wait {
+		return m, tea.Quit
+	}
+
+	m.donePhase = m.phase
+	m.phase = PhaseWaitingForChecks
+	if m.donePhase == PhaseComplete {
+		// Give GitHub time to start checks on the pushed commits, so they
+		// aren't mistaken for having none.
+		return m, m.pollChecksCmd()
+	}
+	return m, m.loadChecksCmd()
+}
+
+// ChecksErr returns an error with --wait unless every check finished and none
+// failed, e.g. because the submit or loading the checks failed, or the wait
+// was quit or skipped. Returns nil without --wait.
+func (m Model) ChecksErr() error {
+	if !m.wait {
+		return nil
+	}
+	if !m.checksDone {
+		return errors.New("stopped before every check finished")
+	}
+	if m.stack.CheckStatus() == github.CheckFailure {
+		return errors.New("checks failed")
+	}
+	return nil
+}
+
+// saveJournal persists the journal. This is best-effort: failing to record
+// progress shouldn't fail the submit itself.
+func (m Model) saveJournal() {
+	if m.journal != nil {
+		_ = m.journal.Save()
+	}
+}
+
+// saveSnapshot persists the undo snapshot. Like the journal, this is best-effort.
+func (m Model) saveSnapshot() {
+	if m.snapshot != nil {
+		_ = m.snapshot.Save()
+	}
+}
+
 // View renders the UI
 func (m Model) View() string {
 	var sb strings.Builder
@@ -243,6 +557,7 @@ func (m Model) View() string {
 		sb.WriteString("\n")
 		sb.WriteString(components.SuccessStyle.Render("All PRs are up to date!"))
 		sb.WriteString("\n")
+		sb.WriteString(m.renderChecks())
 
 	case PhaseConfirmation:
 		sb.WriteString(m.stack.View(m.spinner, viewOpts))
@@ -266,10 +581,23 @@ func (m Model) View() string {
 		sb.WriteString(m.spinner.View())
 		sb.WriteString(" Updating stack comments...\n\n")
 
+	case PhaseWaitingForChecks:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		sb.WriteString(m.spinner.View())
+		sb.WriteString(" Waiting for checks to finish...\n\n")
+		sb.WriteString(renderHelp(m.keys))
+		sb.WriteString("\n")
+
 	case PhaseComplete:
 		sb.WriteString(m.stack.View(m.spinner, viewOpts))
 		count := len(m.stack.MutableRevisions())
-		fmt.Fprintf(&sb, "%d pull request(s) synced successfully.\n", count)
+		skipped := m.sched.count(stepSkipped)
+		if skipped > 0 {
+			fmt.Fprintf(&sb, "%d pull request(s) synced successfully, %d skipped.\n", count-skipped, skipped)
+		} else {
+			fmt.Fprintf(&sb, "%d pull request(s) synced successfully.\n", count)
+		}
+		sb.WriteString(m.renderChecks())
 
 	case PhaseError:
 		sb.WriteString(m.stack.View(m.spinner, viewOpts))
@@ -278,12 +606,61 @@ func (m Model) View() string {
 		if m.err != nil {
 			sb.WriteString(components.ErrorStyle.Render(m.err.Error()))
 			sb.WriteString("\n")
+			sb.WriteString(components.HintView(m.err))
+		}
+		if m.keys.Retry.Enabled() {
+			sb.WriteString("\n")
+			if m.journal != nil {
+				sb.WriteString(components.MutedStyle.Render("Progress was saved. Run `jj github submit --resume` to continue later."))
+				sb.WriteString("\n\n")
+			}
+			sb.WriteString(renderHelp(m.keys))
+			sb.WriteString("\n")
 		}
 	}
 
+	if m.phase != PhaseLoading {
+		sb.WriteString(m.renderRateLimit())
+	}
+
 	return sb.String()
 }
 
+// renderChecks summarizes the checks once they finish with --wait
+func (m Model) renderChecks() string {
+	if !m.wait {
+		return ""
+	}
+
+	switch m.stack.CheckStatus() {
+	case github.CheckFailure:
+		return components.ErrorStyle.Render("Some checks failed.") + "\n"
+	case github.CheckSuccess:
+		return components.SuccessStyle.Render("All checks passed.") + "\n"
+	default:
+		return components.MutedStyle.Render("No checks ran.") + "\n"
+	}
+}
+
+// renderRateLimit renders a warning when the GitHub API budget is running low
+func (m Model) renderRateLimit() string {
+	if m.gh == nil {
+		return ""
+	}
+
+	rateLimit, ok := m.gh.RateLimit()
+	if !ok || float64(rateLimit.Remaining) > float64(rateLimit.Limit)*lowRateLimitFraction {
+		return ""
+	}
+
+	return components.YellowStyle.Render(fmt.Sprintf(
+		"GitHub API budget low: %d of %d requests remaining, resets at %s",
+		rateLimit.Remaining,
+		rateLimit.Limit,
+		rateLimit.ResetAt.Local().Format("15:04"),
+	)) + "\n"
+}
+
 // Commands for async operations
 
 func (m Model) loadRevisionsAndPRsCmd() tea.Cmd {
@@ -291,13 +668,18 @@ func (m Model) loadRevisionsAndPRsCmd() tea.Cmd {
 		// Load revisions - include the immutable parent of the first mutable commit
 		// (for determining base branch) plus all commits in the revset.
 		// This works even if the revset is not directly on top of trunk().
-		changes, err := jj.GetChanges(fmt.Sprintf("(roots(::(%s) & mutable())- | ::(%s) & mutable()) & ~empty()", m.revset, m.revset))
+		changes, err := m.jjRepo.GetChanges(fmt.Sprintf("(roots(::(%s) & mutable())- | ::(%s) & mutable()) & ~empty()", m.revset, m.revset))
 		if err != nil {
 			return RevisionsLoadedMsg{Err: err}
 		}
+		for i := range changes {
+			if branch, ok := m.branches[changes[i].ID]; ok {
+				changes[i].GitPushBookmark = branch
+			}
+		}
 
 		// Determine trunk name using jj's trunk() revset
-		trunkName, err := jj.GetTrunkName()
+		trunkName, err := m.jjRepo.GetTrunkName()
 		if err != nil {
 			return RevisionsLoadedMsg{Err: fmt.Errorf("get trunk name: %w", err)}
 		}
@@ -315,7 +697,7 @@ func (m Model) loadRevisionsAndPRsCmd() tea.Cmd {
 		// Fetch only mutable bookmarks from remote to get latest state.
 		// We deliberately avoid fetching trunk to prevent confusion when
 		// changes are not based on the latest trunk.
-		if err := jj.GitFetchBranches(branches); err != nil {
+		if err := m.jjRepo.GitFetchBranches(branches); err != nil {
 			return RevisionsLoadedMsg{Err: fmt.Errorf("git fetch: %w", err)}
 		}
 
@@ -327,11 +709,20 @@ func (m Model) loadRevisionsAndPRsCmd() tea.Cmd {
 			}
 		}
 
-		// Fetch existing PRs
-		existingPRs, err := m.gh.GetPullRequestsForBranches(m.ctx, m.repo, branches)
+		// Fetch existing PRs along with their stack comments
+		state, err := m.gh.LoadStackState(m.ctx, m.repo, branches, stackCommentMarker)
 		if err != nil {
 			return RevisionsLoadedMsg{Err: err}
 		}
+		existingPRs := state.PullRequests
+
+		// Closed PRs' checks are stale, so only open ones are shown.
+		checks := make(map[int]*github.Checks)
+		for _, pr := range existingPRs {
+			if pr.GetState() == "open" {
+				checks[pr.GetNumber()] = state.Checks[pr.GetNumber()]
+			}
+		}
 
 		// Check if sync is needed per revision
 		needsSync := false
@@ -357,74 +748,130 @@ func (m Model) loadRevisionsAndPRsCmd() tea.Cmd {
 				base = parent.GitPushBookmark
 			}
 
-			title, body, _ := strings.Cut(change.Description, "\n")
-			isDraft := strings.Contains(strings.ToLower(title), "wip")
-
-			pr, exists := existingPRs[change.GitPushBookmark]
-			if !exists {
-				needsSync = true
-				needsSyncByID[change.ID] = true
-				continue
-			}
-
-			// Check if local commit matches remote head (need to push if different)
-			if pr.GetHead().GetSHA() != change.CommitID {
-				needsSync = true
-				needsSyncByID[change.ID] = true
-				continue
-			}
-
-			// Check if PR metadata needs update
-			// Normalize body comparison by trimming trailing whitespace, as GitHub may strip it
-			if pr.GetTitle() != title ||
-				strings.TrimRight(pr.GetBody(), " \t\n\r") != strings.TrimRight(body, " \t\n\r") ||
-				pr.GetBase().GetRef() != base ||
-				pr.GetDraft() != isDraft {
-				needsSync = true
-				needsSyncByID[change.ID] = true
-				continue
+			changeNeedsSync, err := NeedsSync(change, existingPRs[change.GitPushBookmark], base, parent == nil || parent.Immutable, m.autoMerge)
+			if err != nil {
+				return RevisionsLoadedMsg{Err: err}
 			}
-
-			// This revision doesn't need sync
-			needsSyncByID[change.ID] = false
+			needsSync = needsSync || changeNeedsSync
+			needsSyncByID[change.ID] = changeNeedsSync
 		}
 
 		return RevisionsLoadedMsg{
 			Changes:       changes,
 			TrunkName:     trunkName,
 			ExistingPRs:   existingPRs,
+			StackComments: state.Comments,
+			Checks:        checks,
 			NeedsSync:     needsSync,
 			NeedsSyncByID: needsSyncByID,
 		}
 	}
 }
 
-func (m Model) pushNextRevisionCmd() tea.Cmd {
-	mutableRevs := m.stack.MutableRevisions()
-	// Revisions are in reverse order (current at top), so we process from the end
-	idx := len(mutableRevs) - 1 - m.currentIndex
-	if idx < 0 {
-		return nil
+// NeedsSync returns true if submitting change would push it or update pr, its
+// pull request, which is nil if it has none. base is the branch the pull
+// request should merge into, bottom is true if change is at the bottom of
+// its stack, and autoMerge is the merge method passed with --auto-merge.
+func NeedsSync(change jj.Change, pr *gogithub.PullRequest, base string, bottom bool, autoMerge string) (bool, error) {
+	method, manageAutoMerge, err := wantAutoMerge(change, bottom, autoMerge)
+	if err != nil {
+		return false, err
+	}
+
+	// Closed PRs are reopened, and merged ones replaced by a new PR.
+	if pr == nil || pr.GetState() != "open" {
+		return true, nil
+	}
+
+	// Check if local commit matches remote head (need to push if different)
+	if pr.GetHead().GetSHA() != change.CommitID {
+		return true, nil
+	}
+
+	// Check if PR metadata needs update
+	// Normalize body comparison by trimming trailing whitespace, as GitHub may strip it
+	title, body, _ := strings.Cut(change.Description, "\n")
+	if pr.GetTitle() != title ||
+		strings.TrimRight(pr.GetBody(), " \t\n\r") != strings.TrimRight(body, " \t\n\r") ||
+		pr.GetBase().GetRef() != base ||
+		pr.GetDraft() != strings.Contains(strings.ToLower(title), "wip") {
+		return true, nil
+	}
+
+	return manageAutoMerge && github.AutoMergeMethod(pr) != method, nil
+}
+
+// loadChecksCmd loads the checks of every PR in the stack.
+func (m Model) loadChecksCmd() tea.Cmd {
+	var numbers []int
+	for _, rev := range m.stack.MutableRevisions() {
+		if pr, ok := m.existingPRs[rev.Change.GitPushBookmark]; ok {
+			numbers = append(numbers, pr.GetNumber())
+		}
+	}
+
+	return func() tea.Msg {
+		checks, err := m.gh.LoadChecks(m.ctx, m.repo, numbers)
+		if err != nil {
+			return ChecksLoadedMsg{Err: fmt.Errorf("load checks: %w", err)}
+		}
+		return ChecksLoadedMsg{Checks: checks}
+	}
+}
+
+// pollChecksCmd loads the checks again after the poll interval.
+func (m Model) pollChecksCmd() tea.Cmd {
+	return tea.Tick(m.pollInterval, func(time.Time) tea.Msg { return pollChecksMsg{} })
+}
+
+// scheduleCmd starts every push and PR sync the scheduler allows right now.
+func (m Model) scheduleCmd() tea.Cmd {
+	var cmds []tea.Cmd
+	if change, ok := m.sched.nextPush(); ok {
+		cmds = append(cmds, m.pushRevisionCmd(change))
+	}
+	for _, change := range m.sched.nextSyncs() {
+		cmds = append(cmds, m.syncRevisionPRCmd(change))
 	}
-	rev := mutableRevs[idx]
-	m.stack.SetRevisionState(rev.Change.ID, components.StateInProgress, "Pushing...")
+	return tea.Batch(cmds...)
+}
+
+func (m Model) pushRevisionCmd(change jj.Change) tea.Cmd {
+	m.stack.SetRevisionState(change.ID, components.StateInProgress, "Pushing...")
 
 	return func() tea.Msg {
-		change := rev.Change
+		_, adopted := m.branches[change.ID]
+
+		// Whether the push creates the branch is only needed for undo.
+		// Adopted branches always existed, and if origin can't be checked the
+		// branch is assumed to exist, so undo never deletes it.
+		created := false
+		if m.snapshot != nil && !adopted {
+			exists, err := m.jjRepo.HasRemoteBookmark(change.GitPushBookmark)
+			created = err == nil && !exists
+		}
 
 		// Push the branch
-		if err := jj.GitPush(change.ID); err != nil {
+		push := m.jjRepo.GitPush
+		if adopted {
+			push = func(changeID string) error { return m.jjRepo.PushBranch(change.GitPushBookmark, changeID) }
+		}
+		if err := push(change.ID); err != nil {
 			return RevisionPushedMsg{Change: change, Err: fmt.Errorf("push: %w", err)}
 		}
 
-		return RevisionPushedMsg{Change: change}
+		return RevisionPushedMsg{Change: change, Created: created}
 	}
 }
 
 func (m Model) syncRevisionPRCmd(change jj.Change) tea.Cmd {
-	// Determine if we're creating or updating
-	_, exists := m.existingPRs[change.GitPushBookmark]
-	if exists {
+	// Determine if we're creating or updating. The lookup happens here rather
+	// than in the command since other syncs may update existingPRs concurrently.
+	existingPR, exists := m.existingPRs[change.GitPushBookmark]
+	reopen := exists && existingPR.GetState() != "open"
+	if reopen {
+		m.stack.SetRevisionState(change.ID, components.StateInProgress, "Reopening PR...")
+	} else if exists {
 		m.stack.SetRevisionState(change.ID, components.StateInProgress, "Updating PR...")
 	} else {
 		m.stack.SetRevisionState(change.ID, components.StateInProgress, "Creating PR...")
@@ -456,33 +903,52 @@ func (m Model) syncRevisionPRCmd(change jj.Change) tea.Cmd {
 		title, body, _ := strings.Cut(change.Description, "\n")
 		isDraft := strings.Contains(strings.ToLower(title), "wip")
 
-		if pr, ok := m.existingPRs[change.GitPushBookmark]; ok {
+		// Trailers were validated while loading.
+		autoMerge, manageAutoMerge, _ := wantAutoMerge(change, parent == nil || parent.Immutable, m.autoMerge)
+		if manageAutoMerge && autoMerge == "" && exists && github.AutoMergeMethod(existingPR) != "" {
+			// Turn auto-merge off first, so the PR can't be merged while
+			// it is being marked as a draft.
+			if err := m.gh.DisableAutoMerge(m.ctx, m.repo, existingPR); err != nil {
+				return RevisionSyncedMsg{ChangeID: change.ID, Err: fmt.Errorf("disable auto-merge: %w", err)}
+			}
+			pr := *existingPR
+			pr.AutoMerge = nil
+			existingPR = &pr
+		}
+
+		if pr := existingPR; exists {
 			// Check if update needed
 			// Normalize body comparison by trimming trailing whitespace, as GitHub may strip it
-			if pr.GetTitle() == title &&
+			if !reopen &&
+				pr.GetTitle() == title &&
 				strings.TrimRight(pr.GetBody(), " \t\n\r") == strings.TrimRight(body, " \t\n\r") &&
 				pr.GetHead().GetRef() == change.GitPushBookmark &&
 				pr.GetBase().GetRef() == base &&
 				pr.GetDraft() == isDraft {
-				return RevisionSyncedMsg{
+				return m.enableAutoMerge(RevisionSyncedMsg{
 					ChangeID: change.ID,
-					PRNumber: pr.GetNumber(),
+					PR:       pr,
 					Created:  false,
-				}
+				}, autoMerge)
 			}
 
-			err := m.gh.UpdatePullRequest(m.ctx, m.repo, *pr.Number, github.PullRequestOptions{
+			updated, err := m.gh.UpdatePullRequest(m.ctx, m.repo, pr.GetNumber(), github.PullRequestOptions{
 				Title:  title,
 				Body:   body,
 				Branch: change.GitPushBookmark,
 				Base:   base,
 				Draft:  isDraft,
+				Reopen: reopen,
 			})
-			return RevisionSyncedMsg{
-				ChangeID: change.ID,
-				PRNumber: pr.GetNumber(),
-				Created:  false,
-				Err:      err,
+			// GitHub refuses to reopen some PRs, e.g. if the branch was
+			// recreated since it was closed. Open a new PR instead.
+			if !reopen || !github.IsUnprocessable(err) {
+				return m.enableAutoMerge(RevisionSyncedMsg{
+					ChangeID: change.ID,
+					PR:       updated,
+					Created:  false,
+					Err:      err,
+				}, autoMerge)
 			}
 		}
 
@@ -497,33 +963,19 @@ func (m Model) syncRevisionPRCmd(change jj.Change) tea.Cmd {
 		if err != nil {
 			return RevisionSyncedMsg{ChangeID: change.ID, Err: err}
 		}
-		// Store for later use
-		m.existingPRs[change.GitPushBookmark] = pr
-		return RevisionSyncedMsg{
+		return m.enableAutoMerge(RevisionSyncedMsg{
 			ChangeID: change.ID,
-			PRNumber: pr.GetNumber(),
+			PR:       pr,
 			Created:  true,
-		}
+		}, autoMerge)
 	}
 }
 
 func (m Model) updateAllCommentsCmd() tea.Cmd {
 	return func() tea.Msg {
-		// Fetch existing stack comments
-		var prNumbers []int
-		for _, pr := range m.existingPRs {
-			prNumbers = append(prNumbers, pr.GetNumber())
-		}
-
-		stackComments, err := m.gh.GetPRCommentsContaining(
-			m.ctx,
-			m.repo,
-			prNumbers,
-			"<!-- managed-by: jj-github -->",
-		)
-		if err != nil {
-			return AllCommentsUpdatedMsg{Err: err}
-		}
+		// Stack comments were loaded along with the PRs. PRs created during
+		// this run have no comments yet.
+		stackComments := m.stackComments
 
 		// Update comments for each PR
 		for _, rev := range m.stack.Revisions {
@@ -538,7 +990,7 @@ func (m Model) updateAllCommentsCmd() tea.Cmd {
 
 			// Build the stack comment
 			builder := &strings.Builder{}
-			builder.WriteString("<!-- managed-by: jj-github -->\n")
+			builder.WriteString(stackCommentMarker + "\n")
 			builder.WriteString("**Pull Request Stack**\n\n")
 
 			// Show PRs in display order (current at top)
@@ -595,7 +1047,7 @@ func (m Model) updateAllCommentsCmd() tea.Cmd {
 	}
 }
 
-// renderHelp renders the help view with custom styling for submit (magenta) and quit (muted)
+// renderHelp renders the help view with custom styling for actions (magenta) and quit (muted)
 func renderHelp(keys KeyMap) string {
 	var b strings.Builder
 
@@ -604,6 +1056,17 @@ func renderHelp(keys KeyMap) string {
 		renderKey(&b, keys.Submit, components.AccentStyle)
 	}
 
+	// Render error recovery keys in magenta
+	for _, k := range []key.Binding{keys.Retry, keys.Skip} {
+		if !k.Enabled() {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(components.MutedStyle.Render(helpSeparator))
+		}
+		renderKey(&b, k, components.AccentStyle)
+	}
+
 	// Render separator and quit key in muted
 	if keys.Quit.Enabled() {
 		if b.Len() > 0 {
@@ -0,0 +1,452 @@
+package submit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/journal"
+	"github.com/cbrewster/jj-github/internal/tui/tuitest"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+// testStack is trunk <- a <- b.
+func testStack() []jj.Change {
+	return []jj.Change{
+		jjtest.Trunk("trunk", "main"),
+		jjtest.Change("aaaaaaaa", "Add feature A\n\nBody A", "trunk"),
+		jjtest.Change("bbbbbbbb", "Add feature B", "aaaaaaaa"),
+	}
+}
+
+// newTestModel returns a model over testStack with fake jj and GitHub.
+func newTestModel(t *testing.T, opts Options) (*tuitest.Driver, *jjtest.Runner, *githubtest.Fake) {
+	t.Helper()
+
+	jjRepo, runner := jjtest.NewClient()
+	runner.On("log").ReturnChanges(testStack()...)
+	runner.On("log", "-r", "trunk()").ReturnChanges(jjtest.Trunk("trunk", "main"))
+	runner.On("git", "fetch")
+	runner.On("git", "push")
+
+	gh := githubtest.NewFake()
+	model := NewModel(context.Background(), jjRepo, gh, testRepo, "@", opts)
+	return tuitest.New(t, model), runner, gh
+}
+
+func phase(d *tuitest.Driver) Phase {
+	return d.Model().(Model).phase
+}
+
+func TestSubmitCreatesPullRequests(t *testing.T) {
+	d, runner, gh := newTestModel(t, Options{})
+
+	d.Init()
+	require.Equal(t, PhaseConfirmation, phase(d))
+	assert.False(t, d.Quit())
+	assert.Contains(t, d.View(), "2 revision(s) will be synced to GitHub.")
+
+	d.Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.True(t, d.Quit())
+
+	assert.Equal(t, 2, runner.Called("git", "push"))
+
+	prs := gh.PullRequests()
+	require.Len(t, prs, 2)
+	assert.Equal(t, "Add feature A", prs[0].GetTitle())
+	assert.Equal(t, "Body A", strings.TrimSpace(prs[0].GetBody()))
+	assert.Equal(t, "push-aaaaaaaa", prs[0].GetHead().GetRef())
+	assert.Equal(t, "main", prs[0].GetBase().GetRef())
+	assert.Equal(t, "push-bbbbbbbb", prs[1].GetHead().GetRef())
+	assert.Equal(t, "push-aaaaaaaa", prs[1].GetBase().GetRef())
+
+	for _, pr := range prs {
+		comments := gh.Comments(pr.GetNumber())
+		require.Len(t, comments, 1)
+		assert.Contains(t, comments[0].GetBody(), stackCommentMarker)
+		assert.Contains(t, comments[0].GetBody(), "- #1")
+		assert.Contains(t, comments[0].GetBody(), "- #2")
+	}
+}
+
+func TestSubmitUpToDate(t *testing.T) {
+	d, runner, gh := newTestModel(t, Options{})
+	gh.AddPullRequest(github.PullRequestOptions{
+		Title:  "Add feature A",
+		Body:   "\nBody A",
+		Branch: "push-aaaaaaaa",
+		Base:   "main",
+	}, "commit-aaaaaaaa")
+	gh.AddPullRequest(github.PullRequestOptions{
+		Title:  "Add feature B",
+		Branch: "push-bbbbbbbb",
+		Base:   "push-aaaaaaaa",
+	}, "commit-bbbbbbbb")
+
+	d.Init()
+	assert.Equal(t, PhaseUpToDate, phase(d))
+	assert.True(t, d.Quit())
+	assert.Contains(t, d.View(), "All PRs are up to date!")
+	assert.Zero(t, runner.Called("git", "push"))
+}
+
+func TestSubmitUpdatesChangedPullRequest(t *testing.T) {
+	d, _, gh := newTestModel(t, Options{})
+	gh.AddPullRequest(github.PullRequestOptions{
+		Title:  "Old title",
+		Branch: "push-aaaaaaaa",
+		Base:   "main",
+	}, "commit-aaaaaaaa")
+
+	d.Init()
+	require.Equal(t, PhaseConfirmation, phase(d))
+
+	d.Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+
+	prs := gh.PullRequests()
+	require.Len(t, prs, 2)
+	assert.Equal(t, "Add feature A", prs[0].GetTitle())
+}
+
+func TestSubmitReopensClosedPullRequest(t *testing.T) {
+	d, _, gh := newTestModel(t, Options{})
+	pr := gh.AddPullRequest(github.PullRequestOptions{
+		Title:  "Add feature A",
+		Branch: "push-aaaaaaaa",
+		Base:   "main",
+	}, "commit-aaaaaaaa")
+	gh.ClosePullRequest(pr.GetNumber(), false)
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+
+	prs := gh.PullRequests()
+	require.Len(t, prs, 2)
+	assert.Equal(t, "open", prs[0].GetState())
+}
+
+func TestSubmitLoadError(t *testing.T) {
+	d, runner, _ := newTestModel(t, Options{})
+	runner.On("log").Fail("Error: Revision `@` doesn't exist")
+
+	d.Init()
+	assert.Equal(t, PhaseError, phase(d))
+	assert.True(t, d.Quit())
+}
+
+func TestSubmitPushFailureRetry(t *testing.T) {
+	d, runner, gh := newTestModel(t, Options{})
+	runner.On("git", "push", "change_id(aaaaaaaa)").Fail("Error: failed to push")
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseError, phase(d))
+	assert.False(t, d.Quit(), "errors can be retried, so the TUI stays open")
+	assert.Empty(t, gh.PullRequests(), "b is blocked by its parent")
+	assert.Contains(t, d.View(), "r retry")
+
+	// The push succeeds on retry.
+	runner.On("git", "push")
+	d.Key("r")
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.Len(t, gh.PullRequests(), 2)
+}
+
+func TestSubmitCreateFailureSkip(t *testing.T) {
+	d, _, gh := newTestModel(t, Options{})
+	gh.FailNext("CreatePullRequest", errors.New("validation failed"))
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseError, phase(d))
+	assert.Contains(t, d.View(), "validation failed")
+
+	d.Key("s")
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.Contains(t, d.View(), "1 pull request(s) synced successfully, 1 skipped.")
+	assert.Len(t, gh.PullRequests(), 1)
+}
+
+func TestSubmitCommentFailureRetry(t *testing.T) {
+	d, _, gh := newTestModel(t, Options{})
+	gh.FailNext("CreatePullRequestComment", errors.New("server error"))
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseError, phase(d))
+
+	d.Key("r")
+	require.Equal(t, PhaseComplete, phase(d))
+	for _, pr := range gh.PullRequests() {
+		assert.Len(t, gh.Comments(pr.GetNumber()), 1)
+	}
+}
+
+func TestSubmitQuitDuringConfirmation(t *testing.T) {
+	d, runner, _ := newTestModel(t, Options{})
+
+	d.Init().Key("q")
+	assert.Equal(t, PhaseConfirmation, phase(d))
+	assert.True(t, d.Quit())
+	assert.Zero(t, runner.Called("git", "push"))
+}
+
+func TestSubmitJournal(t *testing.T) {
+	dir := t.TempDir()
+	d, _, gh := newTestModel(t, Options{StateDir: dir})
+	gh.FailNext("CreatePullRequest", nil)
+	gh.FailNext("CreatePullRequest", errors.New("validation failed"))
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseError, phase(d))
+
+	j, err := journal.Load(dir)
+	require.NoError(t, err)
+	require.NotNil(t, j)
+	assert.Equal(t, "@", j.Revset)
+	assert.True(t, j.Revisions["aaaaaaaa"].Synced)
+	assert.True(t, j.Revisions["bbbbbbbb"].Pushed)
+	assert.False(t, j.Revisions["bbbbbbbb"].Synced)
+
+	// Resume in a new run: a is not pushed again, b's PR is created.
+	d, runner, _ := newTestModel(t, Options{StateDir: dir, Resume: j})
+	d.Init()
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.Zero(t, runner.Called("git", "push"))
+
+	j, err = journal.Load(dir)
+	require.NoError(t, err)
+	assert.Nil(t, j, "the journal is removed once the run completes")
+}
+
+func TestSubmitRecordsSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	d, runner, gh := newTestModel(t, Options{Snapshot: journal.NewSnapshot(dir, "submit", "op1")})
+
+	// A was submitted before, so its branch and PR already exist. B has a
+	// local bookmark that was never pushed, so its branch is still created.
+	stack := testStack()
+	stack[1].Bookmarks = []jj.BookmarkRef{{Name: "push-aaaaaaaa"}}
+	stack[2].Bookmarks = []jj.BookmarkRef{{Name: "push-bbbbbbbb"}}
+	runner.On("log").ReturnChanges(stack...)
+	runner.On("log", "-r", `remote_bookmarks(exact:"push-aaaaaaaa", remote=exact:"origin")`).Return("old-commit\n")
+	runner.On("log", "-r", `remote_bookmarks(exact:"push-bbbbbbbb", remote=exact:"origin")`).Return("")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Add feature A", Branch: "push-aaaaaaaa", Base: "main"}, "old-commit")
+
+	d.Init()
+	snapshot, err := journal.LoadSnapshot(dir)
+	require.NoError(t, err)
+	assert.Nil(t, snapshot, "nothing is saved until the submit is confirmed")
+
+	d.Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+
+	snapshot, err = journal.LoadSnapshot(dir)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	assert.Equal(t, "op1", snapshot.OperationID)
+	assert.Equal(t, []string{"push-bbbbbbbb"}, snapshot.CreatedBranches)
+	assert.Equal(t, []string{"push-aaaaaaaa"}, snapshot.UpdatedBranches)
+	assert.Equal(t, []int{2}, snapshot.CreatedPRs)
+}
+
+func TestSubmitAdoptedBranch(t *testing.T) {
+	dir := t.TempDir()
+	d, runner, gh := newTestModel(t, Options{
+		Snapshot: journal.NewSnapshot(dir, "submit", "op1"),
+		Branches: journal.Branches{"aaaaaaaa": "fix-login"},
+	})
+	runner.On("bookmark", "set")
+	runner.On("log", "-r", `remote_bookmarks(exact:"push-bbbbbbbb", remote=exact:"origin")`).Return("")
+	// A's PR was opened on GitHub, on a branch jj-github didn't name.
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Login fix", Branch: "fix-login", Base: "main"}, "old-commit")
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+
+	assert.Equal(t, 1, runner.Called("bookmark", "set", "fix-login", "-r", "change_id(aaaaaaaa)"))
+	assert.Equal(t, 1, runner.Called("git", "push", "-b", "fix-login"))
+	assert.Equal(t, 1, runner.Called("git", "push", "-c", "change_id(bbbbbbbb)"))
+
+	prs := gh.PullRequests()
+	require.Len(t, prs, 2, "the adopted PR is reused")
+	assert.Equal(t, "Add feature A", prs[0].GetTitle())
+	assert.Equal(t, "fix-login", prs[0].GetHead().GetRef())
+	assert.Equal(t, "fix-login", prs[1].GetBase().GetRef())
+	require.Len(t, gh.Comments(1), 1)
+
+	snapshot, err := journal.LoadSnapshot(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fix-login"}, snapshot.UpdatedBranches, "adopted branches are never deleted by undo")
+	assert.Equal(t, []string{"push-bbbbbbbb"}, snapshot.CreatedBranches)
+	assert.Zero(t, runner.Called("log", "-r", `remote_bookmarks(exact:"fix-login", remote=exact:"origin")`))
+}
+
+func TestSubmitWaitForChecks(t *testing.T) {
+	d, _, gh := newTestModel(t, Options{Wait: true, PollInterval: time.Millisecond})
+	gh.SetChecks(1, github.Check{Name: "build", Status: github.CheckSuccess})
+	gh.SetChecks(2, github.Check{Name: "build", Status: github.CheckFailure})
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.True(t, d.Quit())
+	assert.Contains(t, d.View(), "2 pull request(s) synced successfully.")
+	assert.Contains(t, d.View(), "Failing: build")
+	assert.Contains(t, d.View(), "Some checks failed.")
+	assert.EqualError(t, d.Model().(Model).ChecksErr(), "checks failed")
+}
+
+func TestSubmitWaitSkipped(t *testing.T) {
+	d, _, gh := newTestModel(t, Options{Wait: true, PollInterval: time.Millisecond})
+	gh.SetChecks(1, github.Check{Name: "build", Status: github.CheckSuccess})
+
+	d.Init()
+	gh.FailNext("LoadChecks", errors.New("boom"))
+	d.Key("enter")
+	require.Equal(t, PhaseError, phase(d))
+	assert.EqualError(t, d.Model().(Model).ChecksErr(), "stopped before every check finished")
+
+	d.Key("s")
+	assert.True(t, d.Quit())
+	assert.EqualError(t, d.Model().(Model).ChecksErr(), "stopped before every check finished", "skipping the wait isn't a pass")
+}
+
+func TestSubmitUpToDateWaitForChecks(t *testing.T) {
+	d, _, gh := newTestModel(t, Options{Wait: true, PollInterval: time.Millisecond})
+	a := gh.AddPullRequest(github.PullRequestOptions{
+		Title:  "Add feature A",
+		Body:   "\nBody A",
+		Branch: "push-aaaaaaaa",
+		Base:   "main",
+	}, "commit-aaaaaaaa")
+	gh.AddPullRequest(github.PullRequestOptions{
+		Title:  "Add feature B",
+		Branch: "push-bbbbbbbb",
+		Base:   "push-aaaaaaaa",
+	}, "commit-bbbbbbbb")
+	gh.SetChecks(a.GetNumber(), github.Check{Name: "build", Status: github.CheckPending})
+
+	var waited bool
+	d.OnMsg = func(msg tea.Msg) {
+		if _, ok := msg.(ChecksLoadedMsg); ok && phase(d) == PhaseWaitingForChecks {
+			waited = true
+			gh.SetChecks(a.GetNumber(), github.Check{Name: "build", Status: github.CheckSuccess})
+		}
+	}
+
+	d.Init()
+	assert.True(t, waited)
+	require.Equal(t, PhaseUpToDate, phase(d))
+	assert.True(t, d.Quit())
+	assert.Contains(t, d.View(), "All checks passed.")
+	assert.NoError(t, d.Model().(Model).ChecksErr())
+}
+
+func TestSubmitWithoutWaitShowsChecks(t *testing.T) {
+	d, _, gh := newTestModel(t, Options{})
+	a := gh.AddPullRequest(github.PullRequestOptions{Title: "Old", Branch: "push-aaaaaaaa", Base: "main"}, "old")
+	gh.SetChecks(a.GetNumber(), github.Check{Name: "build", Status: github.CheckFailure})
+
+	d.Init()
+	require.Equal(t, PhaseConfirmation, phase(d))
+	assert.Contains(t, d.View(), "Failing: build")
+	assert.NotContains(t, gh.Calls(), "LoadChecks", "checks are loaded with the stack state")
+
+	d.Key("enter")
+	assert.NotContains(t, d.View(), "Failing: build", "checks of the previous commit are cleared")
+	assert.NoError(t, d.Model().(Model).ChecksErr())
+}
+
+func TestSubmitAutoMerge(t *testing.T) {
+	d, _, gh := newTestModel(t, Options{AutoMerge: "squash"})
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+
+	prs := gh.PullRequests()
+	require.Len(t, prs, 2)
+	assert.Equal(t, "squash", github.AutoMergeMethod(prs[0]))
+	assert.Empty(t, github.AutoMergeMethod(prs[1]), "only the bottom PR merges into trunk")
+	assert.Contains(t, d.View(), "pull/1  auto-merge: squash")
+}
+
+func TestSubmitAutoMergeUpToDate(t *testing.T) {
+	d, runner, gh := newTestModel(t, Options{AutoMerge: "merge"})
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Add feature A", Body: "\nBody A", Branch: "push-aaaaaaaa", Base: "main"}, "commit-aaaaaaaa")
+	gh.AddPullRequest(github.PullRequestOptions{Title: "Add feature B", Branch: "push-bbbbbbbb", Base: "push-aaaaaaaa"}, "commit-bbbbbbbb")
+
+	d.Init()
+	require.Equal(t, PhaseConfirmation, phase(d), "enabling auto-merge is a change to sync")
+
+	d.Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.Len(t, gh.PullRequests(), 2)
+	assert.Equal(t, "merge", github.AutoMergeMethod(gh.PullRequests()[0]))
+
+	// Once enabled, there is nothing left to do.
+	model := NewModel(context.Background(), jj.NewClient(runner), gh, testRepo, "@", Options{AutoMerge: "merge"})
+	d = tuitest.New(t, model).Init()
+	assert.Equal(t, PhaseUpToDate, phase(d))
+}
+
+func TestSubmitAutoMergeTrailer(t *testing.T) {
+	d, runner, gh := newTestModel(t, Options{})
+	runner.On("log").ReturnChanges(
+		jjtest.Trunk("trunk", "main"),
+		jjtest.Change("aaaaaaaa", "Add feature A\n\nBody A\n\nAuto-Merge: rebase", "trunk"),
+	)
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+	require.Len(t, gh.PullRequests(), 1)
+	assert.Equal(t, "rebase", github.AutoMergeMethod(gh.PullRequests()[0]))
+}
+
+func TestSubmitAutoMergeInvalidTrailer(t *testing.T) {
+	d, runner, _ := newTestModel(t, Options{})
+	runner.On("log").ReturnChanges(
+		jjtest.Trunk("trunk", "main"),
+		jjtest.Change("aaaaaaaa", "Add feature A\n\nAuto-Merge: yes", "trunk"),
+	)
+
+	d.Init()
+	require.Equal(t, PhaseError, phase(d))
+	assert.Contains(t, d.View(), `aaa: unknown Auto-Merge method "yes", expected one of merge, squash, rebase`)
+}
+
+func TestSubmitAutoMergeDisabledForWIP(t *testing.T) {
+	d, runner, gh := newTestModel(t, Options{AutoMerge: "squash"})
+	runner.On("log").ReturnChanges(
+		jjtest.Trunk("trunk", "main"),
+		jjtest.Change("aaaaaaaa", "WIP: Add feature A", "trunk"),
+	)
+	pr := gh.AddPullRequest(github.PullRequestOptions{Title: "Add feature A", Branch: "push-aaaaaaaa", Base: "main"}, "commit-aaaaaaaa")
+	require.NoError(t, gh.EnableAutoMerge(context.Background(), testRepo, pr, "squash"))
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+	pr = gh.PullRequests()[0]
+	assert.True(t, pr.GetDraft())
+	assert.Empty(t, github.AutoMergeMethod(pr))
+	assert.NotContains(t, d.View(), "auto-merge")
+}
+
+func TestSubmitAutoMergeFailureWarns(t *testing.T) {
+	d, _, gh := newTestModel(t, Options{AutoMerge: "squash"})
+	gh.FailNext("EnableAutoMerge", errors.New("auto-merge is not allowed for this repository"))
+
+	d.Init().Key("enter")
+	require.Equal(t, PhaseComplete, phase(d), "the PR was still synced")
+	assert.Contains(t, d.View(), "Auto-merge not enabled: auto-merge is not allowed for this repository")
+	require.Len(t, gh.PullRequests(), 2)
+	assert.Empty(t, github.AutoMergeMethod(gh.PullRequests()[0]))
+}
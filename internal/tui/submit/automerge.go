@@ -0,0 +1,84 @@
+package submit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// autoMergeTrailer is the trailer that enables auto-merge for a revision's PR,
+// e.g. "Auto-Merge: squash".
+const autoMergeTrailer = "Auto-Merge"
+
+// autoMergeMethod returns the merge method requested by an Auto-Merge trailer
+// in the last paragraph of description, or "" if there is none.
+func autoMergeMethod(description string) (string, error) {
+	paragraphs := strings.Split(strings.TrimSpace(description), "\n\n")
+	if len(paragraphs) < 2 {
+		// The only paragraph is the title.
+		return "", nil
+	}
+
+	for line := range strings.SplitSeq(paragraphs[len(paragraphs)-1], "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), autoMergeTrailer) {
+			continue
+		}
+		method := strings.ToLower(strings.TrimSpace(value))
+		if !slices.Contains(github.MergeMethods, method) {
+			return "", fmt.Errorf("unknown %s method %q, expected one of %s",
+				autoMergeTrailer, method, strings.Join(github.MergeMethods, ", "))
+		}
+		return method, nil
+	}
+	return "", nil
+}
+
+// wantAutoMerge returns the merge method change's PR should be auto-merged
+// with, or "" if auto-merge should be off. Without an Auto-Merge trailer,
+// fallback is used. Returns false if submit should leave auto-merge as it is.
+//
+// Auto-merge is only enabled for PRs at the bottom of the stack, since the
+// others would be merged into their parent's branch rather than trunk. They
+// get it once they are restacked onto trunk. WIP revisions never auto-merge.
+func wantAutoMerge(change jj.Change, bottom bool, fallback string) (string, bool, error) {
+	title, _, _ := strings.Cut(change.Description, "\n")
+	if strings.Contains(strings.ToLower(title), "wip") {
+		return "", true, nil
+	}
+
+	method, err := autoMergeMethod(change.Description)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", change.ShortID, err)
+	}
+	if method == "" {
+		method = fallback
+	}
+	if method == "" || !bottom {
+		return "", false, nil
+	}
+	return method, true, nil
+}
+
+// enableAutoMerge turns on auto-merge with method for the PR in msg, unless
+// method is empty or the PR already has it. The PR is synced either way, so a
+// failure is shown as a warning on its row rather than failing the submit.
+// Repositories that don't allow auto-merge are the usual cause.
+func (m Model) enableAutoMerge(msg RevisionSyncedMsg, method string) RevisionSyncedMsg {
+	if msg.Err != nil || method == "" || github.AutoMergeMethod(msg.PR) == method {
+		return msg
+	}
+
+	if err := m.gh.EnableAutoMerge(m.ctx, m.repo, msg.PR, method); err != nil {
+		msg.Warning = "Auto-merge not enabled: " + err.Error()
+		return msg
+	}
+	pr := *msg.PR
+	pr.AutoMerge = &gogithub.PullRequestAutoMerge{MergeMethod: gogithub.Ptr(method)}
+	msg.PR = &pr
+	return msg
+}
@@ -0,0 +1,61 @@
+package submit
+
+import (
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoMergeMethod(t *testing.T) {
+	for _, tc := range []struct {
+		Name        string
+		Description string
+		Method      string
+		Err         string
+	}{
+		{Name: "no trailer", Description: "Add feature\n\nBody"},
+		{Name: "title only", Description: "Auto-Merge: squash"},
+		{Name: "trailer", Description: "Add feature\n\nBody\n\nAuto-Merge: squash", Method: "squash"},
+		{Name: "case insensitive", Description: "Add feature\n\nauto-merge:  Rebase\n", Method: "rebase"},
+		{Name: "among other trailers", Description: "Add feature\n\nFixes: #12\nAuto-Merge: merge\nSigned-off-by: A <a@example.com>", Method: "merge"},
+		{Name: "not in the last paragraph", Description: "Add feature\n\nAuto-Merge: squash\n\nMore body"},
+		{Name: "unknown method", Description: "Add feature\n\nAuto-Merge: fast-forward", Err: `unknown Auto-Merge method "fast-forward", expected one of merge, squash, rebase`},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			method, err := autoMergeMethod(tc.Description)
+			if tc.Err != "" {
+				assert.EqualError(t, err, tc.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Method, method)
+		})
+	}
+}
+
+func TestNeedsSyncAutoMerge(t *testing.T) {
+	change := jjtest.Change("aaaaaaaa", "Add feature\n\nAuto-Merge: squash", "trunk")
+	pr := &gogithub.PullRequest{
+		State: gogithub.Ptr("open"),
+		Title: gogithub.Ptr("Add feature"),
+		Body:  gogithub.Ptr("\nAuto-Merge: squash"),
+		Head:  &gogithub.PullRequestBranch{SHA: gogithub.Ptr(change.CommitID)},
+		Base:  &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	}
+
+	needsSync, err := NeedsSync(change, pr, "main", true, "")
+	require.NoError(t, err)
+	assert.True(t, needsSync, "the trailer asks for auto-merge, which is off")
+
+	needsSync, err = NeedsSync(change, pr, "main", false, "")
+	require.NoError(t, err)
+	assert.False(t, needsSync, "only the bottom of the stack is auto-merged")
+
+	pr.AutoMerge = &gogithub.PullRequestAutoMerge{MergeMethod: gogithub.Ptr("SQUASH")}
+	needsSync, err = NeedsSync(change, pr, "main", true, "")
+	require.NoError(t, err)
+	assert.False(t, needsSync)
+}
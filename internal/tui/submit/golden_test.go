@@ -0,0 +1,80 @@
+package submit
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/tui/tuitest"
+)
+
+// widths are the terminal widths golden files are rendered at.
+var widths = []int{40, 80, 120}
+
+func TestSubmitGolden(t *testing.T) {
+	for _, tc := range []struct {
+		Name string
+		Run  func(t *testing.T, width int) *tuitest.Driver
+	}{
+		{
+			Name: "create",
+			Run: func(t *testing.T, width int) *tuitest.Driver {
+				d, _, _ := newTestModel(t, Options{})
+				return d.Resize(width, 40).Init().Key("enter")
+			},
+		},
+		{
+			Name: "up-to-date",
+			Run: func(t *testing.T, width int) *tuitest.Driver {
+				d, _, gh := newTestModel(t, Options{})
+				gh.AddPullRequest(github.PullRequestOptions{
+					Title:  "Add feature A",
+					Body:   "\nBody A",
+					Branch: "push-aaaaaaaa",
+					Base:   "main",
+				}, "commit-aaaaaaaa")
+				gh.AddPullRequest(github.PullRequestOptions{
+					Title:  "Add feature B",
+					Branch: "push-bbbbbbbb",
+					Base:   "push-aaaaaaaa",
+				}, "commit-bbbbbbbb")
+				return d.Resize(width, 40).Init()
+			},
+		},
+		{
+			Name: "push-failure",
+			Run: func(t *testing.T, width int) *tuitest.Driver {
+				d, runner, _ := newTestModel(t, Options{})
+				runner.On("git", "push", "change_id(aaaaaaaa)").Fail("Error: Failed to push some bookmarks\n" +
+					"Hint: The following references unexpectedly moved on the remote:\n" +
+					"  refs/heads/push-aaaaaaaa (reason: stale info)")
+				return d.Resize(width, 40).Init().Key("enter")
+			},
+		},
+		{
+			Name: "skip",
+			Run: func(t *testing.T, width int) *tuitest.Driver {
+				d, _, gh := newTestModel(t, Options{})
+				gh.FailNext("CreatePullRequest", errors.New("validation failed"))
+				return d.Resize(width, 40).Init().Key("enter").Key("s")
+			},
+		},
+		{
+			Name: "load-error",
+			Run: func(t *testing.T, width int) *tuitest.Driver {
+				d, runner, _ := newTestModel(t, Options{})
+				runner.On("log").Fail("Error: Revision `@` doesn't exist")
+				return d.Resize(width, 40).Init()
+			},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			for _, width := range widths {
+				t.Run(fmt.Sprintf("%dcols", width), func(t *testing.T) {
+					tc.Run(t, width).AssertGoldenFrames()
+				})
+			}
+		})
+	}
+}
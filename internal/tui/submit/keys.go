@@ -6,18 +6,20 @@ import "github.com/charmbracelet/bubbles/key"
 // Implements help.KeyMap interface
 type KeyMap struct {
 	Submit key.Binding
+	Retry  key.Binding
+	Skip   key.Binding
 	Quit   key.Binding
 }
 
 // ShortHelp returns key bindings for the short help view
 func (k KeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Submit, k.Quit}
+	return []key.Binding{k.Submit, k.Retry, k.Skip, k.Quit}
 }
 
 // FullHelp returns key bindings for the full help view
 func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Submit, k.Quit},
+		{k.Submit, k.Retry, k.Skip, k.Quit},
 	}
 }
 
@@ -28,6 +30,16 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("enter"),
 			key.WithHelp("enter", "submit"),
 		),
+		Retry: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "retry"),
+			key.WithDisabled(),
+		),
+		Skip: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "skip"),
+			key.WithDisabled(),
+		),
 		Quit: key.NewBinding(
 			key.WithKeys("q", "ctrl+c"),
 			key.WithHelp("q", "quit"),
@@ -43,9 +55,17 @@ func ErrorKeyMap() KeyMap {
 			key.WithHelp("enter", "submit"),
 			key.WithDisabled(),
 		),
+		Retry: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "retry"),
+		),
+		Skip: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "skip"),
+		),
 		Quit: key.NewBinding(
 			key.WithKeys("q", "ctrl+c"),
-			key.WithHelp("q", "quit"),
+			key.WithHelp("q", "abort"),
 		),
 	}
 }
@@ -0,0 +1,232 @@
+package submit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cbrewster/jj-github/internal/jj"
+)
+
+// syncConcurrency is the maximum number of PR create/update calls in flight.
+const syncConcurrency = 8
+
+// step represents how far a single revision has progressed through submit
+type step int
+
+const (
+	stepPending step = iota // Waiting to be pushed
+	stepPushing             // Push in progress
+	stepPushed              // Pushed, waiting for a free PR sync slot
+	stepSyncing             // PR create/update in progress
+	stepDone                // PR is in sync
+	stepFailed              // Push or PR sync failed
+	stepBlocked             // An ancestor failed to push, so the base branch may be missing
+	stepSkipped             // Failed or blocked, and the user chose to move on without it
+)
+
+// scheduler decides which revisions can be pushed and synced next.
+//
+// jj operations on a repository must be serialized, so pushes happen one at a
+// time from the bottom of the stack upwards. A revision's PR only depends on
+// its base branch existing on the remote, so as soon as a revision has been
+// pushed its PR can be created or updated concurrently with the rest of the
+// stack. If a push fails, every descendant is blocked since its base branch
+// would be missing or stale.
+type scheduler struct {
+	order   []string             // Change IDs, bottom of the stack first
+	changes map[string]jj.Change // Change ID to change
+	parent  map[string]string    // Change ID to parent change ID within the stack
+	steps   map[string]step
+	errs    map[string]error
+	failed  map[string]step // Step each failed revision failed at
+	limit   int
+}
+
+// newScheduler creates a scheduler for the given changes, which must be in
+// topological order (bottom of the stack first).
+func newScheduler(changes []jj.Change, limit int) scheduler {
+	s := scheduler{
+		changes: make(map[string]jj.Change, len(changes)),
+		parent:  make(map[string]string, len(changes)),
+		steps:   make(map[string]step, len(changes)),
+		errs:    make(map[string]error),
+		failed:  make(map[string]step),
+		limit:   limit,
+	}
+
+	for _, change := range changes {
+		s.order = append(s.order, change.ID)
+		s.changes[change.ID] = change
+		s.steps[change.ID] = stepPending
+	}
+
+	for _, change := range changes {
+		if len(change.Parents) == 0 {
+			continue
+		}
+		if _, ok := s.changes[change.Parents[0].ChangeID]; ok {
+			s.parent[change.ID] = change.Parents[0].ChangeID
+		}
+	}
+
+	return s
+}
+
+// nextPush returns the next revision to push, if no push is currently running.
+func (s *scheduler) nextPush() (jj.Change, bool) {
+	for _, id := range s.order {
+		if s.steps[id] == stepPushing {
+			return jj.Change{}, false
+		}
+	}
+
+	for _, id := range s.order {
+		if s.steps[id] == stepPending {
+			s.steps[id] = stepPushing
+			return s.changes[id], true
+		}
+	}
+
+	return jj.Change{}, false
+}
+
+// nextSyncs returns the pushed revisions whose PRs can be synced now,
+// respecting the concurrency limit.
+func (s *scheduler) nextSyncs() []jj.Change {
+	inFlight := 0
+	for _, id := range s.order {
+		if s.steps[id] == stepSyncing {
+			inFlight++
+		}
+	}
+
+	var result []jj.Change
+	for _, id := range s.order {
+		if inFlight >= s.limit {
+			break
+		}
+		if s.steps[id] == stepPushed {
+			s.steps[id] = stepSyncing
+			result = append(result, s.changes[id])
+			inFlight++
+		}
+	}
+
+	return result
+}
+
+// pushed records a successful push.
+func (s *scheduler) pushed(changeID string) {
+	s.steps[changeID] = stepPushed
+}
+
+// synced records a successful PR sync.
+func (s *scheduler) synced(changeID string) {
+	s.steps[changeID] = stepDone
+}
+
+// syncFailed records a failed PR sync. Descendants are unaffected since the
+// base branch already exists on the remote.
+func (s *scheduler) syncFailed(changeID string, err error) {
+	s.steps[changeID] = stepFailed
+	s.failed[changeID] = stepSyncing
+	s.errs[changeID] = err
+}
+
+// pushFailed records a failed push and blocks all pending descendants of
+// revisions whose push failed. Returns the change IDs of the newly blocked
+// revisions.
+func (s *scheduler) pushFailed(changeID string, err error) []string {
+	s.steps[changeID] = stepFailed
+	s.failed[changeID] = stepPushing
+	s.errs[changeID] = err
+
+	var blocked []string
+	for _, id := range s.order {
+		if s.steps[id] != stepPending {
+			continue
+		}
+		for p := s.parent[id]; p != ""; p = s.parent[p] {
+			if s.failed[p] == stepPushing || s.steps[p] == stepBlocked {
+				s.steps[id] = stepBlocked
+				blocked = append(blocked, id)
+				break
+			}
+		}
+	}
+
+	return blocked
+}
+
+// done returns true once no revision has work left.
+func (s *scheduler) done() bool {
+	for _, id := range s.order {
+		switch s.steps[id] {
+		case stepDone, stepFailed, stepBlocked, stepSkipped:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// err returns all collected per-revision errors, or nil.
+func (s *scheduler) err() error {
+	var errs []error
+	for _, id := range s.order {
+		if err, ok := s.errs[id]; ok {
+			errs = append(errs, fmt.Errorf("%s: %w", s.changes[id].ShortID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// retryFailed resets failed and blocked revisions so they are attempted
+// again. Revisions that were pushed but failed to sync are not pushed again.
+// Returns the change IDs that were reset.
+func (s *scheduler) retryFailed() []string {
+	var retried []string
+	for _, id := range s.order {
+		switch s.steps[id] {
+		case stepFailed:
+			if s.failed[id] == stepSyncing {
+				s.steps[id] = stepPushed
+			} else {
+				s.steps[id] = stepPending
+			}
+		case stepBlocked:
+			s.steps[id] = stepPending
+		default:
+			continue
+		}
+		delete(s.errs, id)
+		delete(s.failed, id)
+		retried = append(retried, id)
+	}
+	return retried
+}
+
+// skipFailed gives up on failed and blocked revisions.
+// Returns the change IDs that were skipped.
+func (s *scheduler) skipFailed() []string {
+	var skipped []string
+	for _, id := range s.order {
+		if s.steps[id] == stepFailed || s.steps[id] == stepBlocked {
+			s.steps[id] = stepSkipped
+			delete(s.errs, id)
+			skipped = append(skipped, id)
+		}
+	}
+	return skipped
+}
+
+// count returns the number of revisions at the given step.
+func (s *scheduler) count(st step) int {
+	n := 0
+	for _, id := range s.order {
+		if s.steps[id] == st {
+			n++
+		}
+	}
+	return n
+}
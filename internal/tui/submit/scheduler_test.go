@@ -0,0 +1,196 @@
+package submit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func change(id, parent string) jj.Change {
+	return jj.Change{ID: id, ShortID: id, Parents: []jj.Parent{{ChangeID: parent}}}
+}
+
+func ids(changes []jj.Change) []string {
+	var result []string
+	for _, c := range changes {
+		result = append(result, c.ID)
+	}
+	return result
+}
+
+func TestSchedulerPushesSeriallyAndSyncsConcurrently(t *testing.T) {
+	s := newScheduler([]jj.Change{
+		change("a", "trunk"),
+		change("b", "a"),
+		change("c", "b"),
+	}, syncConcurrency)
+
+	next, ok := s.nextPush()
+	require.True(t, ok)
+	assert.Equal(t, "a", next.ID)
+
+	// Only one push may run at a time.
+	_, ok = s.nextPush()
+	assert.False(t, ok)
+
+	s.pushed("a")
+	next, ok = s.nextPush()
+	require.True(t, ok)
+	assert.Equal(t, "b", next.ID)
+	assert.Equal(t, []string{"a"}, ids(s.nextSyncs()))
+
+	s.pushed("b")
+	next, ok = s.nextPush()
+	require.True(t, ok)
+	assert.Equal(t, "c", next.ID)
+	// b's PR can sync while a's is still in flight.
+	assert.Equal(t, []string{"b"}, ids(s.nextSyncs()))
+
+	s.pushed("c")
+	assert.Equal(t, []string{"c"}, ids(s.nextSyncs()))
+	assert.False(t, s.done())
+
+	s.synced("a")
+	s.synced("b")
+	s.synced("c")
+	assert.True(t, s.done())
+	assert.NoError(t, s.err())
+}
+
+func TestSchedulerRespectsConcurrencyLimit(t *testing.T) {
+	s := newScheduler([]jj.Change{
+		change("a", "trunk"),
+		change("b", "trunk"),
+		change("c", "trunk"),
+	}, 2)
+
+	for range 3 {
+		next, ok := s.nextPush()
+		require.True(t, ok)
+		s.pushed(next.ID)
+	}
+
+	assert.Equal(t, []string{"a", "b"}, ids(s.nextSyncs()))
+	assert.Empty(t, s.nextSyncs())
+
+	s.synced("a")
+	assert.Equal(t, []string{"c"}, ids(s.nextSyncs()))
+}
+
+func TestSchedulerPushFailureBlocksDescendants(t *testing.T) {
+	s := newScheduler([]jj.Change{
+		change("a", "trunk"),
+		change("b", "a"),
+		change("c", "b"),
+		change("d", "trunk"),
+	}, syncConcurrency)
+
+	next, _ := s.nextPush()
+	require.Equal(t, "a", next.ID)
+
+	blocked := s.pushFailed("a", errors.New("rejected"))
+	assert.Equal(t, []string{"b", "c"}, blocked)
+
+	// Independent revisions still run.
+	next, ok := s.nextPush()
+	require.True(t, ok)
+	assert.Equal(t, "d", next.ID)
+	s.pushed("d")
+	s.nextSyncs()
+	s.synced("d")
+
+	assert.True(t, s.done())
+	assert.ErrorContains(t, s.err(), "a: rejected")
+}
+
+func TestSchedulerSyncFailureDoesNotBlockDescendants(t *testing.T) {
+	s := newScheduler([]jj.Change{
+		change("a", "trunk"),
+		change("b", "a"),
+	}, syncConcurrency)
+
+	s.nextPush()
+	s.pushed("a")
+	s.nextSyncs()
+	s.syncFailed("a", errors.New("validation failed"))
+
+	next, ok := s.nextPush()
+	require.True(t, ok)
+	assert.Equal(t, "b", next.ID)
+	s.pushed("b")
+	assert.Equal(t, []string{"b"}, ids(s.nextSyncs()))
+	s.synced("b")
+
+	assert.True(t, s.done())
+	assert.ErrorContains(t, s.err(), "a: validation failed")
+}
+
+func TestSchedulerPushFailureIgnoresSyncFailures(t *testing.T) {
+	s := newScheduler([]jj.Change{
+		change("a", "trunk"),
+		change("d", "trunk"),
+		change("b", "a"),
+	}, syncConcurrency)
+
+	s.nextPush()
+	s.pushed("a")
+	s.nextSyncs()
+	s.syncFailed("a", errors.New("validation failed"))
+
+	next, _ := s.nextPush()
+	require.Equal(t, "d", next.ID)
+	assert.Empty(t, s.pushFailed("d", errors.New("rejected")), "a's branch was pushed, so b can still be")
+
+	next, ok := s.nextPush()
+	require.True(t, ok)
+	assert.Equal(t, "b", next.ID)
+}
+
+func TestSchedulerRetryFailed(t *testing.T) {
+	s := newScheduler([]jj.Change{
+		change("a", "trunk"),
+		change("b", "a"),
+		change("c", "trunk"),
+	}, syncConcurrency)
+
+	s.nextPush()
+	s.pushed("a")
+	s.nextSyncs()
+	s.syncFailed("a", errors.New("validation failed"))
+
+	s.nextPush()
+	s.pushFailed("b", errors.New("rejected"))
+	s.nextPush()
+	s.pushed("c")
+	s.nextSyncs()
+	s.synced("c")
+	require.True(t, s.done())
+
+	assert.Equal(t, []string{"a", "b"}, s.retryFailed())
+	assert.NoError(t, s.err())
+
+	// a was already pushed, so only its PR is synced again.
+	assert.Equal(t, []string{"a"}, ids(s.nextSyncs()))
+	next, ok := s.nextPush()
+	require.True(t, ok)
+	assert.Equal(t, "b", next.ID)
+}
+
+func TestSchedulerSkipFailed(t *testing.T) {
+	s := newScheduler([]jj.Change{
+		change("a", "trunk"),
+		change("b", "a"),
+	}, syncConcurrency)
+
+	s.nextPush()
+	s.pushFailed("a", errors.New("rejected"))
+	require.True(t, s.done())
+
+	assert.Equal(t, []string{"a", "b"}, s.skipFailed())
+	assert.True(t, s.done())
+	assert.NoError(t, s.err())
+	assert.Equal(t, 2, s.count(stepSkipped))
+}
@@ -2,10 +2,16 @@ package sync
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
 	"strings"
 
 	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/journal"
 	"github.com/cbrewster/jj-github/internal/tui/components"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
@@ -17,6 +23,7 @@ type Phase int
 const (
 	PhaseFetching Phase = iota
 	PhaseUpToDate
+	PhaseSelecting
 	PhaseRebasing
 	PhaseComplete
 	PhaseError
@@ -29,16 +36,33 @@ const (
 	StatePending BookmarkState = iota
 	StateInProgress
 	StateSuccess
-	StateSkipped // Commit became empty and was abandoned (e.g., after squash-merge)
-	StateConflict
+	StateSkipped  // Every commit became empty and was abandoned (e.g., after squash-merge)
+	StateConflict // Rebased, but some revisions have conflicts
 	StateError
+	StateLeftInPlace // Not selected, so not rebased
+	StateResolved    // Had conflicts, which were resolved after the rebase
 )
 
+// Help separator between key bindings
+const helpSeparator = " • "
+
 // BookmarkItem represents a bookmark being synced
 type BookmarkItem struct {
 	Bookmark jj.Bookmark
 	State    BookmarkState
+	Result   jj.RebaseResult
 	Error    error
+	Selected bool // Whether the stack will be rebased
+
+	// Conflicts lists the stack's conflicted revisions as of the last check,
+	// bottom first.
+	Conflicts []Conflict
+}
+
+// Conflict is a conflicted revision and its conflicted files
+type Conflict struct {
+	Change jj.Change
+	Files  []string
 }
 
 // Messages for async operations
@@ -46,14 +70,42 @@ type (
 	FetchCompleteMsg struct {
 		Bookmarks []jj.Bookmark
 		TrunkName string
+		Selected  map[string]bool // Change IDs of the roots in the revset, or nil for all
 		Err       error
 	}
 
+	// RebaseAllCompleteMsg reports the combined rebase of the selected
+	// stacks, one jj operation per destination.
+	RebaseAllCompleteMsg struct {
+		Results map[string]jj.RebaseResult // Keyed by root change ID
+		Errs    map[string]error           // Roots of stacks that failed on their own
+		Retry   []string                   // Roots of stacks whose combined rebase failed
+	}
+
+	// RebaseCompleteMsg reports the rebase of a single stack, used when a
+	// combined rebase fails.
 	RebaseCompleteMsg struct {
-		ChangeID     string
-		HasConflict  bool
-		SkippedEmpty bool
-		Err          error
+		ChangeID string
+		Result   jj.RebaseResult
+		Err      error
+	}
+
+	// ConflictsMsg reports the conflicts remaining in conflicted stacks.
+	ConflictsMsg struct {
+		Conflicts map[string][]Conflict // Keyed by root change ID
+		Err       error
+	}
+
+	// EditReadyMsg reports that a conflicted revision has been checked out
+	// so its files can be opened in the editor.
+	EditReadyMsg struct {
+		Files    []string
+		Previous jj.Change // The working-copy revision before the edit
+	}
+
+	// ExecDoneMsg reports that `jj resolve` or the editor has exited.
+	ExecDoneMsg struct {
+		Err error
 	}
 )
 
@@ -73,18 +125,62 @@ type Model struct {
 	successCount  int
 	skippedCount  int
 	conflictCount int
+	leftCount     int
+
+	// Stack selection, and conflict selection once complete
+	cursor      int
+	interactive bool
+
+	// Conflict follow-up
+	followUpErr error
+	// movedFrom is the working-copy revision before the first edit, so the
+	// user can be told how to get back to it
+	movedFrom *jj.Change
 
 	// Dependencies
-	ctx context.Context
+	ctx         context.Context
+	jjRepo      jj.Repo
+	snapshot    *journal.Snapshot
+	revset      string
+	onto        string
+	execProcess func(*exec.Cmd, tea.ExecCallback) tea.Cmd
+}
+
+// Options configures optional sync behavior
+type Options struct {
+	// Snapshot is saved before the first rebase so that `jj github undo` can
+	// restore the repository. If nil, no snapshot is kept.
+	Snapshot *journal.Snapshot
+	// Revset limits the sync to stacks containing one of its revisions.
+	// Other stacks are left in place. If empty, every stack is rebased.
+	Revset string
+	// Select shows a screen to choose which stacks to rebase before starting.
+	Select bool
+	// Onto is the revset every stack is rebased onto. If empty, each stack
+	// is rebased onto the updated position of the branch it is based on,
+	// which is usually trunk.
+	Onto string
+	// ExecProcess runs `jj resolve` or the editor, suspending the program
+	// until it exits. Defaults to tea.ExecProcess.
+	ExecProcess func(*exec.Cmd, tea.ExecCallback) tea.Cmd
 }
 
 // NewModel creates a new sync TUI model
-func NewModel(ctx context.Context) Model {
+func NewModel(ctx context.Context, jjRepo jj.Repo, opts Options) Model {
+	if opts.ExecProcess == nil {
+		opts.ExecProcess = tea.ExecProcess
+	}
 	return Model{
-		phase:   PhaseFetching,
-		spinner: components.NewSpinner(),
-		keys:    DefaultKeyMap(),
-		ctx:     ctx,
+		phase:       PhaseFetching,
+		spinner:     components.NewSpinner(),
+		keys:        DefaultKeyMap(),
+		ctx:         ctx,
+		jjRepo:      jjRepo,
+		snapshot:    opts.Snapshot,
+		revset:      opts.Revset,
+		onto:        opts.Onto,
+		execProcess: opts.ExecProcess,
+		interactive: opts.Select,
 	}
 }
 
@@ -109,7 +205,32 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch {
 		case key.Matches(msg, m.keys.Quit):
 			return m, tea.Quit
+		case m.phase != PhaseSelecting && m.phase != PhaseComplete:
+		case key.Matches(msg, m.keys.Up):
+			m.cursor = max(m.cursor-1, 0)
+		case key.Matches(msg, m.keys.Down):
+			m.cursor = max(min(m.cursor+1, m.rowCount()-1), 0)
+		case key.Matches(msg, m.keys.Toggle):
+			m.bookmarks[m.cursor].Selected = !m.bookmarks[m.cursor].Selected
+		case key.Matches(msg, m.keys.ToggleAll):
+			all := !slices.ContainsFunc(m.bookmarks, func(item BookmarkItem) bool { return !item.Selected })
+			for i := range m.bookmarks {
+				m.bookmarks[i].Selected = !all
+			}
+		case key.Matches(msg, m.keys.Confirm):
+			return m.startRebasing()
+		case key.Matches(msg, m.keys.Resolve):
+			if conflict, ok := m.selectedConflict(); ok {
+				m.followUpErr = nil
+				return m, m.execProcess(exec.Command("jj", "resolve", "-r", conflict.Change.ID), execDone)
+			}
+		case key.Matches(msg, m.keys.Edit):
+			if conflict, ok := m.selectedConflict(); ok {
+				m.followUpErr = nil
+				return m, m.editCmd(conflict)
+			}
 		}
+		return m, nil
 
 	case FetchCompleteMsg:
 		if msg.Err != nil {
@@ -119,6 +240,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		m.trunkName = msg.TrunkName
+		if m.onto != "" {
+			m.trunkName = m.onto
+		}
 
 		if len(msg.Bookmarks) == 0 {
 			m.phase = PhaseUpToDate
@@ -131,44 +255,98 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.bookmarks[i] = BookmarkItem{
 				Bookmark: b,
 				State:    StatePending,
+				Selected: msg.Selected == nil || msg.Selected[b.ChangeID],
 			}
 		}
 
-		// Start rebasing
-		m.phase = PhaseRebasing
-		m.currentIndex = 0
-		return m, m.rebaseNextCmd()
+		if m.interactive {
+			m.phase = PhaseSelecting
+			m.keys = SelectKeyMap()
+			return m, nil
+		}
+
+		return m.startRebasing()
+
+	case RebaseAllCompleteMsg:
+		for i := range m.bookmarks {
+			item := &m.bookmarks[i]
+			if !item.Selected {
+				continue
+			}
+			if slices.Contains(msg.Retry, item.Bookmark.ChangeID) {
+				// jj rebases atomically, so nothing moved. Rebase the stacks
+				// one at a time to find which failed and still move the others.
+				item.State = StatePending
+				continue
+			}
+			m.applyResult(i, msg.Results[item.Bookmark.ChangeID], msg.Errs[item.Bookmark.ChangeID])
+		}
+
+		m.currentIndex = m.nextPending(0)
+		if m.currentIndex < len(m.bookmarks) {
+			return m, m.rebaseNextCmd()
+		}
+
+		return m.complete()
 
 	case RebaseCompleteMsg:
 		// Find the bookmark and update its state
 		for i := range m.bookmarks {
 			if m.bookmarks[i].Bookmark.ChangeID == msg.ChangeID {
-				if msg.Err != nil {
-					m.bookmarks[i].State = StateError
-					m.bookmarks[i].Error = msg.Err
-				} else if msg.SkippedEmpty {
-					m.bookmarks[i].State = StateSkipped
-					m.skippedCount++
-				} else if msg.HasConflict {
-					m.bookmarks[i].State = StateConflict
-					m.conflictCount++
-				} else {
-					m.bookmarks[i].State = StateSuccess
-					m.successCount++
-				}
+				m.applyResult(i, msg.Result, msg.Err)
 				break
 			}
 		}
 
-		m.currentIndex++
+		m.currentIndex = m.nextPending(m.currentIndex + 1)
 
 		if m.currentIndex < len(m.bookmarks) {
 			return m, m.rebaseNextCmd()
 		}
 
 		// All done
-		m.phase = PhaseComplete
-		return m, tea.Quit
+		return m.complete()
+
+	case ConflictsMsg:
+		if msg.Err != nil {
+			m.followUpErr = msg.Err
+			return m, nil
+		}
+
+		for i := range m.bookmarks {
+			item := &m.bookmarks[i]
+			if item.State != StateConflict {
+				continue
+			}
+			item.Conflicts = msg.Conflicts[item.Bookmark.ChangeID]
+			if len(item.Conflicts) == 0 {
+				item.State = StateResolved
+				m.conflictCount--
+				m.successCount++
+			}
+		}
+		m.cursor = max(min(m.cursor, m.rowCount()-1), 0)
+
+		if m.conflictCount == 0 {
+			m.keys = DefaultKeyMap()
+			return m, tea.Quit
+		}
+		m.keys = ConflictKeyMap()
+		return m, nil
+
+	case EditReadyMsg:
+		if m.movedFrom == nil {
+			m.movedFrom = &msg.Previous
+		}
+		return m, m.execProcess(editorCommand(msg.Files), execDone)
+
+	case ExecDoneMsg:
+		if msg.Err != nil {
+			m.followUpErr = msg.Err
+		}
+		// Check every stack again, since resolving a revision can resolve
+		// or cause conflicts in its descendants.
+		return m, m.conflictsCmd()
 	}
 
 	// Update spinner
@@ -179,6 +357,137 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// startRebasing rebases the selected stacks one at a time.
+func (m Model) startRebasing() (tea.Model, tea.Cmd) {
+	m.keys = DefaultKeyMap()
+	for i := range m.bookmarks {
+		if !m.bookmarks[i].Selected {
+			m.bookmarks[i].State = StateLeftInPlace
+			m.leftCount++
+		}
+	}
+
+	m.currentIndex = m.nextSelected(0)
+	if m.currentIndex == len(m.bookmarks) {
+		m.phase = PhaseComplete
+		return m, tea.Quit
+	}
+
+	if m.snapshot != nil {
+		// Best-effort: without a snapshot, sync can still be undone with `jj op restore`.
+		_ = m.snapshot.Save()
+	}
+
+	m.phase = PhaseRebasing
+	return m, m.rebaseAllCmd()
+}
+
+// complete finishes the sync. If any stacks have conflicts, they are listed
+// so they can be resolved before quitting.
+func (m Model) complete() (tea.Model, tea.Cmd) {
+	m.phase = PhaseComplete
+	if m.conflictCount == 0 {
+		return m, tea.Quit
+	}
+
+	m.cursor = 0
+	return m, m.conflictsCmd()
+}
+
+// Err returns the error that stopped the sync, or an error if it didn't
+// finish cleanly: a stack failed to rebase, conflicts are left or the sync
+// was quit before it finished.
+func (m Model) Err() error {
+	switch m.phase {
+	case PhaseError:
+		return m.err
+	case PhaseUpToDate:
+		return nil
+	case PhaseComplete:
+	default:
+		return errors.New("stopped before the sync finished")
+	}
+
+	failed := 0
+	for _, item := range m.bookmarks {
+		if item.State == StateError {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d stack(s) failed to rebase", failed)
+	}
+	if m.conflictCount > 0 {
+		return fmt.Errorf("%d stack(s) have unresolved conflicts", m.conflictCount)
+	}
+	return nil
+}
+
+// conflicts returns every conflicted revision, in the order they are shown
+func (m Model) conflicts() []Conflict {
+	var conflicts []Conflict
+	for _, item := range m.bookmarks {
+		if item.State == StateConflict {
+			conflicts = append(conflicts, item.Conflicts...)
+		}
+	}
+	return conflicts
+}
+
+// selectedConflict returns the conflicted revision under the cursor
+func (m Model) selectedConflict() (Conflict, bool) {
+	conflicts := m.conflicts()
+	if m.cursor >= len(conflicts) {
+		return Conflict{}, false
+	}
+	return conflicts[m.cursor], true
+}
+
+// rowCount returns the number of rows the cursor can move over
+func (m Model) rowCount() int {
+	if m.phase == PhaseSelecting {
+		return len(m.bookmarks)
+	}
+	return len(m.conflicts())
+}
+
+// applyResult records the outcome of rebasing the stack at index i
+func (m *Model) applyResult(i int, result jj.RebaseResult, err error) {
+	item := &m.bookmarks[i]
+	item.Result = result
+	if err != nil {
+		item.State = StateError
+		item.Error = err
+	} else if result.Rebased == 0 && len(result.Abandoned) > 0 {
+		item.State = StateSkipped
+		m.skippedCount++
+	} else if len(result.Conflicted) > 0 {
+		item.State = StateConflict
+		m.conflictCount++
+	} else {
+		item.State = StateSuccess
+		m.successCount++
+	}
+}
+
+// nextSelected returns the index of the first selected stack at or after i,
+// or len(m.bookmarks) if there is none.
+func (m Model) nextSelected(i int) int {
+	for i < len(m.bookmarks) && !m.bookmarks[i].Selected {
+		i++
+	}
+	return i
+}
+
+// nextPending returns the index of the first selected stack at or after i
+// that is waiting to be rebased on its own, or len(m.bookmarks) if there is none.
+func (m Model) nextPending(i int) int {
+	for i < len(m.bookmarks) && (!m.bookmarks[i].Selected || m.bookmarks[i].State != StatePending) {
+		i++
+	}
+	return i
+}
+
 // View renders the UI
 func (m Model) View() string {
 	var sb strings.Builder
@@ -192,17 +501,42 @@ func (m Model) View() string {
 		sb.WriteString(components.SuccessStyle.Render(components.GraphSuccess))
 		sb.WriteString(" Already up to date - no bookmarks to rebase.\n")
 
+	case PhaseSelecting:
+		sb.WriteString(m.renderHeader("Select stacks to rebase", "Select stacks to rebase"))
+		sb.WriteString(m.renderSelection())
+		sb.WriteString("\n")
+		sb.WriteString(renderHelp(m.keys))
+		sb.WriteString("\n")
+
 	case PhaseRebasing:
-		sb.WriteString(fmt.Sprintf("Rebasing onto %s:\n\n", components.AccentStyle.Render(m.trunkName)))
+		sb.WriteString(m.renderHeader("Rebasing", "Rebasing stacks"))
 		sb.WriteString(m.renderBookmarks())
 		sb.WriteString("\n")
 
 	case PhaseComplete:
-		sb.WriteString(fmt.Sprintf("Rebased onto %s:\n\n", components.AccentStyle.Render(m.trunkName)))
+		sb.WriteString(m.renderHeader("Rebased", "Rebased stacks"))
 		sb.WriteString(m.renderBookmarks())
 		sb.WriteString("\n")
+		if conflicts := m.renderConflicts(); conflicts != "" {
+			sb.WriteString(conflicts)
+			sb.WriteString("\n")
+		}
+		if m.followUpErr != nil {
+			sb.WriteString(components.ErrorStyle.Render(m.followUpErr.Error()))
+			sb.WriteString("\n\n")
+		}
+		if m.movedFrom != nil {
+			sb.WriteString(components.MutedStyle.Render(fmt.Sprintf(
+				"The working copy was moved to edit conflicts. Run `jj edit %s` to go back.", m.movedFrom.ShortID)))
+			sb.WriteString("\n\n")
+		}
 		sb.WriteString(m.renderSummary())
 		sb.WriteString("\n")
+		if m.keys.Resolve.Enabled() {
+			sb.WriteString("\n")
+			sb.WriteString(renderHelp(m.keys))
+			sb.WriteString("\n")
+		}
 
 	case PhaseError:
 		sb.WriteString(components.ErrorStyle.Render(components.GraphError + " Sync failed"))
@@ -210,6 +544,7 @@ func (m Model) View() string {
 		if m.err != nil {
 			sb.WriteString(components.ErrorStyle.Render(m.err.Error()))
 			sb.WriteString("\n")
+			sb.WriteString(components.HintView(m.err))
 		}
 	}
 
@@ -221,24 +556,29 @@ const idDisplayExtra = 4
 
 // renderBookmarks renders the list of bookmarks with their states
 func (m Model) renderBookmarks() string {
-	// Calculate max ID width for alignment
-	maxIDWidth := 0
-	for _, item := range m.bookmarks {
-		idWidth := len(item.Bookmark.ShortID) + idDisplayExtra
-		if idWidth > maxIDWidth {
-			maxIDWidth = idWidth
-		}
-	}
+	idWidth := m.idWidth()
 
 	var sb strings.Builder
 	for _, item := range m.bookmarks {
-		sb.WriteString(m.renderBookmarkItem(item, maxIDWidth))
+		sb.WriteString(m.renderBookmarkItem(item, idWidth))
 		sb.WriteString("\n")
 	}
 
 	return sb.String()
 }
 
+// idWidth returns the width of the widest change ID, for alignment
+func (m Model) idWidth() int {
+	maxIDWidth := 0
+	for _, item := range m.bookmarks {
+		idWidth := len(item.Bookmark.ShortID) + idDisplayExtra
+		if idWidth > maxIDWidth {
+			maxIDWidth = idWidth
+		}
+	}
+	return maxIDWidth
+}
+
 // renderBookmarkItem renders a single bookmark item with fixed-width ID column
 func (m Model) renderBookmarkItem(item BookmarkItem, idWidth int) string {
 	var sb strings.Builder
@@ -257,9 +597,59 @@ func (m Model) renderBookmarkItem(item BookmarkItem, idWidth int) string {
 		sb.WriteString(components.ErrorStyle.Render(components.GraphError))
 	case StateError:
 		sb.WriteString(components.ErrorStyle.Render(components.GraphError))
+	case StateLeftInPlace:
+		sb.WriteString(components.MutedStyle.Render(components.GraphPending))
+	case StateResolved:
+		sb.WriteString(components.SuccessStyle.Render(components.GraphSuccess))
 	}
 
 	sb.WriteString(" ")
+	sb.WriteString(m.renderStack(item, idWidth))
+
+	// Status suffix
+	switch item.State {
+	case StateInProgress:
+		sb.WriteString(components.MutedStyle.Render("  Rebasing..."))
+	case StateLeftInPlace:
+		sb.WriteString(components.MutedStyle.Render("  left in place"))
+	case StateSkipped:
+		sb.WriteString(components.MutedStyle.Render("  skipped (already in " + m.destinationName(item.Bookmark) + ")"))
+	case StateConflict:
+		var ids []string
+		for _, change := range item.Result.Conflicted {
+			ids = append(ids, change.ShortID)
+		}
+		sb.WriteString(components.YellowStyle.Render("  conflicts in " + strings.Join(ids, ", ")))
+	case StateResolved:
+		sb.WriteString(components.MutedStyle.Render("  conflicts resolved"))
+	case StateSuccess:
+		if n := len(item.Result.Abandoned); n > 0 {
+			sb.WriteString(components.MutedStyle.Render(fmt.Sprintf("  %d abandoned (already in %s)", n, m.destinationName(item.Bookmark))))
+		}
+		if len(item.Result.AlreadyConflicted) > 0 {
+			var ids []string
+			for _, change := range item.Result.AlreadyConflicted {
+				ids = append(ids, change.ShortID)
+			}
+			sb.WriteString(components.MutedStyle.Render("  still conflicted: " + strings.Join(ids, ", ")))
+		}
+	case StateError:
+		if item.Error != nil {
+			sb.WriteString(components.ErrorStyle.Render("  " + item.Error.Error()))
+			if hint := components.HintView(item.Error); hint != "" {
+				sb.WriteString("\n  ")
+				sb.WriteString(strings.TrimSuffix(hint, "\n"))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// renderStack renders a stack's root change ID, padded to idWidth, its
+// description, and its destination if it isn't the one in the header
+func (m Model) renderStack(item BookmarkItem, idWidth int) string {
+	var sb strings.Builder
 
 	// Change ID (short part highlighted, rest muted) with padding for alignment
 	shortID := item.Bookmark.ShortID
@@ -297,25 +687,147 @@ func (m Model) renderBookmarkItem(item BookmarkItem, idWidth int) string {
 		sb.WriteString(description)
 	}
 
-	// Status suffix
-	switch item.State {
-	case StateInProgress:
-		sb.WriteString(components.MutedStyle.Render("  Rebasing..."))
-	case StateSkipped:
-		sb.WriteString(components.MutedStyle.Render("  skipped (already in trunk)"))
-	case StateConflict:
-		sb.WriteString(components.YellowStyle.Render("  conflict"))
-	case StateError:
-		if item.Error != nil {
-			sb.WriteString(components.ErrorStyle.Render("  " + item.Error.Error()))
+	if _, ok := m.commonDestination(); !ok {
+		sb.WriteString(components.MutedStyle.Render("  → " + m.destinationName(item.Bookmark)))
+	}
+
+	return sb.String()
+}
+
+// commonDestination returns the name of the destination every stack is
+// rebased onto, and false if they go to different places. In that case each
+// stack shows its own destination instead of the header.
+func (m Model) commonDestination() (string, bool) {
+	if m.onto != "" {
+		return m.onto, true
+	}
+	if len(m.bookmarks) == 0 {
+		return m.trunkName, true
+	}
+	name := m.destinationName(m.bookmarks[0].Bookmark)
+	for _, item := range m.bookmarks[1:] {
+		if m.destinationName(item.Bookmark) != name {
+			return "", false
 		}
 	}
+	return name, true
+}
+
+// destinationName returns how the destination of the stack rooted at b is shown.
+func (m Model) destinationName(b jj.Bookmark) string {
+	if b.DestinationName == "" {
+		return m.trunkName
+	}
+	return b.DestinationName
+}
+
+// renderHeader renders a heading such as "Rebased onto main:", naming the
+// destination only if every stack goes to the same place. Otherwise mixed is
+// used, e.g. "Rebased stacks:", and each stack shows its own destination.
+func (m Model) renderHeader(action, mixed string) string {
+	name, ok := m.commonDestination()
+	if !ok {
+		return mixed + ":\n\n"
+	}
+	return fmt.Sprintf("%s onto %s:\n\n", action, components.AccentStyle.Render(name))
+}
+
+// renderSelection renders the stacks with their checkboxes and the cursor
+func (m Model) renderSelection() string {
+	idWidth := m.idWidth()
+
+	var sb strings.Builder
+	for i, item := range m.bookmarks {
+		if i == m.cursor {
+			sb.WriteString(components.AccentStyle.Render(">"))
+		} else {
+			sb.WriteString(" ")
+		}
+
+		if item.Selected {
+			sb.WriteString(components.AccentStyle.Render(" [x] "))
+		} else {
+			sb.WriteString(components.MutedStyle.Render(" [ ] "))
+		}
+
+		sb.WriteString(m.renderStack(item, idWidth))
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// renderConflicts renders the conflicted revisions with their files and the cursor
+func (m Model) renderConflicts() string {
+	conflicts := m.conflicts()
+	if len(conflicts) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Conflicts:\n")
+	for i, conflict := range conflicts {
+		if i == m.cursor {
+			sb.WriteString(components.AccentStyle.Render(">"))
+		} else {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(" ")
+		sb.WriteString(components.ChangeIDShortStyle.Render(conflict.Change.ShortID))
+		sb.WriteString(" ")
+		description, _, _ := strings.Cut(conflict.Change.Description, "\n")
+		if description == "" {
+			sb.WriteString(components.MutedStyle.Render("(no description)"))
+		} else {
+			sb.WriteString(description)
+		}
+		sb.WriteString("\n")
 
+		for _, file := range conflict.Files {
+			sb.WriteString(components.MutedStyle.Render("    " + file))
+			sb.WriteString("\n")
+		}
+	}
 	return sb.String()
 }
 
+// renderHelp renders the enabled key bindings, with quit muted
+func renderHelp(keys KeyMap) string {
+	var b strings.Builder
+	for _, k := range []key.Binding{keys.Up, keys.Down, keys.Toggle, keys.ToggleAll, keys.Confirm, keys.Resolve, keys.Edit, keys.Quit} {
+		if !k.Enabled() {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(components.MutedStyle.Render(helpSeparator))
+		}
+
+		style := components.AccentStyle
+		if k.Help().Key == keys.Quit.Help().Key {
+			style = components.MutedStyle
+		}
+		b.WriteString(style.Render(k.Help().Key))
+		b.WriteString(" ")
+		b.WriteString(style.Render(k.Help().Desc))
+	}
+	return b.String()
+}
+
 // renderSummary renders the completion summary
 func (m Model) renderSummary() string {
+	summary := m.renderRebaseSummary()
+	if m.leftCount > 0 {
+		left := components.MutedStyle.Render(fmt.Sprintf("%d stack(s) left in place.", m.leftCount))
+		if m.leftCount == len(m.bookmarks) {
+			return left
+		}
+		summary += "\n" + left
+	}
+	return summary
+}
+
+// renderRebaseSummary summarizes the stacks that were rebased
+func (m Model) renderRebaseSummary() string {
 	if m.conflictCount == 0 && m.skippedCount == 0 {
 		return components.SuccessStyle.Render(fmt.Sprintf("%d stack(s) rebased successfully.", m.successCount))
 	}
@@ -334,6 +846,9 @@ func (m Model) renderSummary() string {
 	summary := strings.Join(parts, ", ")
 	if m.conflictCount > 0 {
 		summary += "\n" + components.MutedStyle.Render("Run `jj resolve` to fix conflicts.")
+		if m.snapshot != nil {
+			summary += "\n" + components.MutedStyle.Render("Run `jj github undo` to go back to before the sync.")
+		}
 	}
 	return summary
 }
@@ -342,27 +857,89 @@ func (m Model) renderSummary() string {
 
 func (m Model) fetchCmd() tea.Cmd {
 	return func() tea.Msg {
+		// Remember which branches the stacks were based on, since the
+		// fetch moves them
+		var upstreams map[string][]string
+		if m.onto == "" {
+			var err error
+			upstreams, err = m.jjRepo.GetStackUpstreams()
+			if err != nil {
+				return FetchCompleteMsg{Err: err}
+			}
+		}
+
 		// Fetch from remote
-		if err := jj.GitFetch(); err != nil {
-			return FetchCompleteMsg{Err: fmt.Errorf("git fetch: %w", err)}
+		if err := m.jjRepo.GitFetch(); err != nil {
+			return FetchCompleteMsg{Err: err}
 		}
 
 		// Get trunk name
-		trunkName, err := jj.GetTrunkName()
+		trunkName, err := m.jjRepo.GetTrunkName()
 		if err != nil {
 			return FetchCompleteMsg{Err: fmt.Errorf("get trunk name: %w", err)}
 		}
 
 		// Get stack roots that need rebasing onto current trunk
-		bookmarks, err := jj.GetStackRootsToRebase()
+		bookmarks, err := m.jjRepo.GetStackRootsToRebase(m.onto, upstreams)
 		if err != nil {
 			return FetchCompleteMsg{Err: err}
 		}
 
-		return FetchCompleteMsg{
+		msg := FetchCompleteMsg{
 			Bookmarks: bookmarks,
 			TrunkName: trunkName,
 		}
+		if m.revset == "" || len(bookmarks) == 0 {
+			return msg
+		}
+
+		roots, err := m.jjRepo.GetStackRoots(m.revset)
+		if err != nil {
+			return FetchCompleteMsg{Err: err}
+		}
+		msg.Selected = make(map[string]bool)
+		for _, root := range roots {
+			msg.Selected[root.ID] = true
+		}
+		return msg
+	}
+}
+
+// rebaseAllCmd rebases the selected stacks with a single jj operation for
+// each destination
+func (m Model) rebaseAllCmd() tea.Cmd {
+	var destinations []string
+	roots := make(map[string][]string)
+	for i := range m.bookmarks {
+		item := &m.bookmarks[i]
+		if !item.Selected {
+			continue
+		}
+		item.State = StateInProgress
+		dest := destinationOf(item.Bookmark)
+		if _, ok := roots[dest]; !ok {
+			destinations = append(destinations, dest)
+		}
+		roots[dest] = append(roots[dest], item.Bookmark.ChangeID)
+	}
+
+	return func() tea.Msg {
+		msg := RebaseAllCompleteMsg{
+			Results: make(map[string]jj.RebaseResult),
+			Errs:    make(map[string]error),
+		}
+		for _, dest := range destinations {
+			results, err := m.jjRepo.RebaseStacks(roots[dest], dest)
+			switch {
+			case err == nil:
+				maps.Copy(msg.Results, results)
+			case len(roots[dest]) > 1:
+				msg.Retry = append(msg.Retry, roots[dest]...)
+			default:
+				msg.Errs[roots[dest][0]] = err
+			}
+		}
+		return msg
 	}
 }
 
@@ -374,14 +951,82 @@ func (m Model) rebaseNextCmd() tea.Cmd {
 	item := &m.bookmarks[m.currentIndex]
 	item.State = StateInProgress
 	changeID := item.Bookmark.ChangeID
+	dest := destinationOf(item.Bookmark)
 
 	return func() tea.Msg {
-		result, err := jj.Rebase(changeID, "trunk()")
+		results, err := m.jjRepo.RebaseStacks([]string{changeID}, dest)
 		return RebaseCompleteMsg{
-			ChangeID:     changeID,
-			HasConflict:  result.HasConflict,
-			SkippedEmpty: result.SkippedEmpty,
-			Err:          err,
+			ChangeID: changeID,
+			Result:   results[changeID],
+			Err:      err,
+		}
+	}
+}
+
+// conflictsCmd looks up the conflicted revisions and files in every stack
+// that had conflicts
+func (m Model) conflictsCmd() tea.Cmd {
+	var roots []string
+	for _, item := range m.bookmarks {
+		if item.State == StateConflict {
+			roots = append(roots, item.Bookmark.ChangeID)
+		}
+	}
+
+	return func() tea.Msg {
+		conflicts := make(map[string][]Conflict)
+		for _, root := range roots {
+			changes, err := m.jjRepo.GetChanges(root + ":: & conflicts()")
+			if err != nil {
+				return ConflictsMsg{Err: fmt.Errorf("check conflicts: %w", err)}
+			}
+			for _, change := range changes {
+				files, err := m.jjRepo.ConflictedFiles(change.ID)
+				if err != nil {
+					return ConflictsMsg{Err: err}
+				}
+				conflicts[root] = append(conflicts[root], Conflict{Change: change, Files: files})
+			}
 		}
+		return ConflictsMsg{Conflicts: conflicts}
+	}
+}
+
+// editCmd checks out the conflicted revision so its files can be edited,
+// moving the working copy
+func (m Model) editCmd(conflict Conflict) tea.Cmd {
+	return func() tea.Msg {
+		workingCopy, err := m.jjRepo.GetChanges("@")
+		if err != nil {
+			return ExecDoneMsg{Err: err}
+		}
+		if len(workingCopy) != 1 {
+			return ExecDoneMsg{Err: fmt.Errorf("working copy not found")}
+		}
+		if err := m.jjRepo.Edit(conflict.Change.ID); err != nil {
+			return ExecDoneMsg{Err: err}
+		}
+		return EditReadyMsg{Files: conflict.Files, Previous: workingCopy[0]}
+	}
+}
+
+// editorCommand opens files in $EDITOR, falling back to vi
+func editorCommand(files []string) *exec.Cmd {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	return exec.Command(editor[0], append(editor[1:], files...)...)
+}
+
+func execDone(err error) tea.Msg {
+	return ExecDoneMsg{Err: err}
+}
+
+// destinationOf returns the revset the stack rooted at b is rebased onto
+func destinationOf(b jj.Bookmark) string {
+	if b.Destination == "" {
+		return "trunk()"
 	}
+	return b.Destination
 }
@@ -0,0 +1,445 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/journal"
+	"github.com/cbrewster/jj-github/internal/tui/tuitest"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestModel returns a model whose mutable roots are roots, with trunk at
+// a commit none of them are parented on.
+func newTestModel(t *testing.T, roots ...jj.Change) (*tuitest.Driver, *jjtest.Runner) {
+	t.Helper()
+
+	jjRepo, runner := newTestRepo(roots...)
+	return tuitest.New(t, NewModel(context.Background(), jjRepo, Options{})), runner
+}
+
+// newTestRepo returns a fake repository for newTestModel.
+func newTestRepo(roots ...jj.Change) (*jj.Client, *jjtest.Runner) {
+	jjRepo, runner := jjtest.NewClient()
+	runner.On("log", "-r", "parents(roots(mutable()))")
+	runner.On("git", "fetch")
+	runner.On("log", "-r", "trunk()").ReturnChanges(jjtest.Trunk("newtrunk", "main"))
+	runner.On("log", "-r", "roots(mutable())").ReturnChanges(roots...)
+	onTrunk(runner, roots...)
+	runner.On("rebase")
+	stacks := make([]stack, len(roots))
+	for i, root := range roots {
+		stacks[i] = unchanged(root)
+	}
+	onRebase(runner, stacks...)
+
+	return jjRepo, runner
+}
+
+// onTrunk scripts the parents of roots as being in trunk, so the stacks are
+// rebased onto trunk.
+func onTrunk(runner *jjtest.Runner, roots ...jj.Change) {
+	var commits []string
+	var parents []jj.Change
+	for _, root := range roots {
+		parent := root.Parents[0]
+		if !slices.Contains(commits, parent.CommitID) {
+			commits = append(commits, parent.CommitID)
+			parents = append(parents, jjtest.Change(parent.ChangeID, "", ""))
+		}
+	}
+	if len(commits) > 0 {
+		runner.On("log", "-r", fmt.Sprintf("(%s) & ::trunk()", strings.Join(commits, " | "))).ReturnChanges(parents...)
+	}
+}
+
+// stack is a stack rooted at before[0] as it looks before and after being
+// rebased. Changes missing from after were abandoned.
+type stack struct {
+	before []jj.Change
+	after  []jj.Change
+}
+
+// unchanged returns a single-revision stack that rebases cleanly.
+func unchanged(root jj.Change) stack {
+	return stack{before: []jj.Change{root}, after: []jj.Change{root}}
+}
+
+// onRebase scripts rebasing stacks together in one operation, as well as
+// each on its own.
+func onRebase(runner *jjtest.Runner, stacks ...stack) {
+	var subtrees []string
+	var before, after []jj.Change
+	for _, st := range stacks {
+		runner.On("log", "-r", st.before[0].ID+"::").ReturnChanges(st.before...)
+		runner.On("log", "-r", presentRevset(st.before)).ReturnChanges(st.after...)
+
+		subtrees = append(subtrees, st.before[0].ID+"::")
+		before = append(before, st.before...)
+		after = append(after, st.after...)
+	}
+	runner.On("log", "-r", strings.Join(subtrees, " | ")).ReturnChanges(before...)
+	runner.On("log", "-r", presentRevset(before)).ReturnChanges(after...)
+}
+
+// presentRevset is the revset jj.Client uses to look changes up after a rebase.
+func presentRevset(changes []jj.Change) string {
+	revsets := make([]string, len(changes))
+	for i, change := range changes {
+		revsets[i] = fmt.Sprintf("present(%s)", change.ID)
+	}
+	return strings.Join(revsets, " | ")
+}
+
+// onConflicts scripts the conflicted revisions found in the stack at root.
+func onConflicts(runner *jjtest.Runner, root string, conflicts ...Conflict) {
+	changes := make([]jj.Change, len(conflicts))
+	for i, conflict := range conflicts {
+		changes[i] = conflict.Change
+		var out strings.Builder
+		for _, file := range conflict.Files {
+			fmt.Fprintf(&out, "%s    2-sided conflict\n", file)
+		}
+		runner.On("resolve", "--list", "-r", conflict.Change.ID).Return(out.String())
+	}
+	runner.On("log", "-r", root+":: & conflicts()").ReturnChanges(changes...)
+}
+
+// conflicted returns change with conflicts.
+func conflicted(change jj.Change) jj.Change {
+	change.Conflict = true
+	return change
+}
+
+func phase(d *tuitest.Driver) Phase {
+	return d.Model().(Model).phase
+}
+
+func TestSyncUpToDate(t *testing.T) {
+	d, runner := newTestModel(t, jjtest.Change("aaaaaaaa", "A", "newtrunk"))
+
+	d.Init()
+	assert.Equal(t, PhaseUpToDate, phase(d))
+	assert.True(t, d.Quit())
+	assert.Contains(t, d.View(), "Already up to date")
+	assert.Zero(t, runner.Called("rebase"))
+	assert.NoError(t, d.Model().(Model).Err())
+}
+
+func TestSyncRebasesStacks(t *testing.T) {
+	a := jjtest.Change("aaaaaaaa", "Fix conflict handling", "oldtrunk")
+	b := jjtest.Change("bbbbbbbb", "B", "oldtrunk")
+	b2 := jjtest.Change("bbbbbbb2", "B2", "bbbbbbbb")
+	c := jjtest.Change("cccccccc", "C", "oldtrunk")
+	d1 := jjtest.Change("dddddddd", "D", "oldtrunk")
+	d2 := jjtest.Change("ddddddd2", "D2", "dddddddd")
+
+	d, runner := newTestModel(t, a, b, c, d1)
+	// Output mentioning conflicts doesn't matter, only what jj reports afterwards.
+	runner.On("rebase", "-s", "aaaaaaaa").Stderr("Rebased 1 commits (conflict-free)")
+	onRebase(runner,
+		unchanged(a),
+		stack{before: []jj.Change{b, b2}, after: []jj.Change{b, conflicted(b2)}},
+		stack{before: []jj.Change{c}},
+		stack{before: []jj.Change{d1, d2}, after: []jj.Change{d2}},
+	)
+	onConflicts(runner, "bbbbbbbb", Conflict{Change: conflicted(b2), Files: []string{"main.go"}})
+
+	d.Init()
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.False(t, d.Quit(), "waits for the conflicts to be followed up")
+
+	// Every stack is rebased in a single operation.
+	assert.Equal(t, 1, runner.Called("rebase"))
+	assert.Equal(t, 1, runner.Called("rebase", "-s", "aaaaaaaa", "-s", "bbbbbbbb", "-s", "cccccccc", "-s", "dddddddd", "-d", "trunk()"))
+	m := d.Model().(Model)
+	assert.Equal(t, []BookmarkState{StateSuccess, StateConflict, StateSkipped, StateSuccess}, []BookmarkState{
+		m.bookmarks[0].State, m.bookmarks[1].State, m.bookmarks[2].State, m.bookmarks[3].State,
+	})
+	assert.Equal(t, []string{"bbbbbbb2"}, ids(m.bookmarks[1].Result.Conflicted))
+	assert.Equal(t, []string{"cccccccc"}, ids(m.bookmarks[2].Result.Abandoned))
+	assert.Equal(t, []string{"dddddddd"}, ids(m.bookmarks[3].Result.Abandoned))
+
+	view := d.View()
+	assert.Contains(t, view, "conflicts in bbb")
+	assert.Contains(t, view, "1 abandoned (already in main)")
+	assert.Contains(t, view, "Run `jj resolve` to fix conflicts.")
+	assert.Contains(t, view, "> bbb B2\n    main.go")
+	assert.EqualError(t, m.Err(), "1 stack(s) have unresolved conflicts")
+}
+
+func TestSyncAlreadyConflicted(t *testing.T) {
+	a := jjtest.Change("aaaaaaaa", "A", "oldtrunk")
+	a2 := conflicted(jjtest.Change("aaaaaaa2", "A2", "aaaaaaaa"))
+
+	d, runner := newTestModel(t, a)
+	onRebase(runner, stack{before: []jj.Change{a, a2}, after: []jj.Change{a, a2}})
+
+	d.Init()
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.True(t, d.Quit(), "the rebase didn't cause the conflict")
+
+	m := d.Model().(Model)
+	assert.Equal(t, StateSuccess, m.bookmarks[0].State)
+	assert.Empty(t, m.bookmarks[0].Result.Conflicted)
+	assert.Contains(t, d.View(), "still conflicted: aaa")
+}
+
+func TestSyncConflictFollowUp(t *testing.T) {
+	t.Setenv("EDITOR", "code --wait")
+
+	a := jjtest.Change("aaaaaaaa", "A", "oldtrunk")
+	a2 := jjtest.Change("aaaaaaa2", "A2", "aaaaaaaa")
+	b := jjtest.Change("bbbbbbbb", "B", "oldtrunk")
+	jjRepo, runner := newTestRepo(a, b)
+	onRebase(runner,
+		stack{before: []jj.Change{a, a2}, after: []jj.Change{conflicted(a), conflicted(a2)}},
+		stack{before: []jj.Change{b}, after: []jj.Change{conflicted(b)}},
+	)
+	onConflicts(runner, "aaaaaaaa",
+		Conflict{Change: conflicted(a), Files: []string{"go.mod"}},
+		Conflict{Change: conflicted(a2), Files: []string{"go.mod", "main.go"}},
+	)
+	onConflicts(runner, "bbbbbbbb", Conflict{Change: conflicted(b), Files: []string{"README.md"}})
+	runner.On("edit")
+
+	// Commands are recorded instead of suspending the test.
+	var commands [][]string
+	exec := func(cmd *exec.Cmd, fn tea.ExecCallback) tea.Cmd {
+		commands = append(commands, cmd.Args)
+		return func() tea.Msg { return fn(nil) }
+	}
+	d := tuitest.New(t, NewModel(context.Background(), jjRepo, Options{ExecProcess: exec}))
+
+	d.Init()
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.Len(t, d.Model().(Model).conflicts(), 3)
+
+	// Resolving the bottom of a stack resolves the revision above it too.
+	onConflicts(runner, "aaaaaaaa")
+	d.Key("enter")
+	assert.Equal(t, [][]string{{"jj", "resolve", "-r", "aaaaaaaa"}}, commands)
+	m := d.Model().(Model)
+	assert.Equal(t, StateResolved, m.bookmarks[0].State)
+	assert.Equal(t, StateConflict, m.bookmarks[1].State)
+	assert.Contains(t, d.View(), "conflicts resolved")
+	assert.False(t, d.Quit())
+
+	// Editing checks the revision out first, and quits once nothing is left.
+	onConflicts(runner, "bbbbbbbb")
+	runner.On("log", "-r", "@").ReturnChanges(jjtest.Change("wwwwwwww", "", "aaaaaaaa"))
+	d.Key("e")
+	assert.Equal(t, 1, runner.Called("edit", "bbbbbbbb"))
+	assert.Equal(t, []string{"code", "--wait", "README.md"}, commands[1])
+	assert.True(t, d.Quit())
+	assert.Contains(t, d.View(), "2 stack(s) rebased successfully.")
+	assert.Contains(t, d.View(), "The working copy was moved to edit conflicts. Run `jj edit www` to go back.")
+	assert.NoError(t, d.Model().(Model).Err())
+}
+
+func TestSyncConflictFollowUpError(t *testing.T) {
+	b := jjtest.Change("bbbbbbbb", "B", "oldtrunk")
+	jjRepo, runner := newTestRepo(b)
+	onRebase(runner, stack{before: []jj.Change{b}, after: []jj.Change{conflicted(b)}})
+	onConflicts(runner, "bbbbbbbb", Conflict{Change: conflicted(b), Files: []string{"main.go"}})
+	runner.On("log", "-r", "@").ReturnChanges(jjtest.Change("wwwwwwww", "", "aaaaaaaa"))
+	runner.On("edit").Fail("Error: Commit 1234abcd is immutable")
+	d := tuitest.New(t, NewModel(context.Background(), jjRepo, Options{
+		ExecProcess: func(*exec.Cmd, tea.ExecCallback) tea.Cmd {
+			t.Fatal("the editor shouldn't be opened")
+			return nil
+		},
+	}))
+
+	d.Init().Key("e")
+	assert.Contains(t, d.View(), "Commit 1234abcd is immutable")
+	assert.Equal(t, StateConflict, d.Model().(Model).bookmarks[0].State)
+	assert.False(t, d.Quit())
+}
+
+func ids(changes []jj.Change) []string {
+	var result []string
+	for _, change := range changes {
+		result = append(result, change.ID)
+	}
+	return result
+}
+
+func TestSyncRebaseError(t *testing.T) {
+	d, runner := newTestModel(t,
+		jjtest.Change("aaaaaaaa", "A", "oldtrunk"),
+		jjtest.Change("bbbbbbbb", "B", "oldtrunk"),
+	)
+	runner.On("rebase", "-s", "aaaaaaaa").Fail("Error: Revision `aaaaaaaa` doesn't exist")
+
+	d.Init()
+	require.Equal(t, PhaseComplete, phase(d))
+
+	// The combined rebase failed, so each stack was retried on its own.
+	assert.Equal(t, 3, runner.Called("rebase"))
+	assert.Equal(t, 2, runner.Called("rebase", "-s", "bbbbbbbb", "-d", "trunk()"), "combined and on its own")
+
+	m := d.Model().(Model)
+	assert.Equal(t, StateError, m.bookmarks[0].State)
+	assert.ErrorContains(t, m.bookmarks[0].Error, "doesn't exist")
+	assert.Equal(t, StateSuccess, m.bookmarks[1].State)
+	assert.EqualError(t, m.Err(), "1 stack(s) failed to rebase")
+}
+
+func TestSyncRebasesOntoUpstream(t *testing.T) {
+	a := jjtest.Change("aaaaaaaa", "A", "oldtrunk")
+	b := jjtest.Change("bbbbbbbb", "B", "oldrelease")
+	release := jjtest.Change("newrelease", "", "")
+
+	jjRepo, runner := newTestRepo(a, b)
+	runner.On("log", "-r", "(commit-oldtrunk | commit-oldrelease) & ::trunk()").ReturnChanges(jjtest.Change("oldtrunk", "", ""))
+	runner.On("log", "-r", `commit-oldrelease:: & remote_bookmarks(remote=exact:"origin") ~ (aaaaaaaa | bbbbbbbb)::`).Return("release-2.3\n")
+	runner.On("log", "-r", `"release-2.3"@origin`).ReturnChanges(release)
+	// b was cherry-picked onto the release branch.
+	b2 := jjtest.Change("bbbbbbb2", "B2", "bbbbbbbb")
+	onRebase(runner, stack{before: []jj.Change{b, b2}, after: []jj.Change{b2}})
+	d := tuitest.New(t, NewModel(context.Background(), jjRepo, Options{}))
+
+	d.Init()
+	require.Equal(t, PhaseComplete, phase(d))
+
+	// Each destination is rebased onto in its own operation.
+	assert.Equal(t, 2, runner.Called("rebase"))
+	assert.Equal(t, 1, runner.Called("rebase", "-s", "aaaaaaaa", "-d", "trunk()"))
+	assert.Equal(t, 1, runner.Called("rebase", "-s", "bbbbbbbb", "-d", `"release-2.3"@origin`))
+
+	m := d.Model().(Model)
+	assert.Equal(t, StateSuccess, m.bookmarks[0].State)
+	assert.Equal(t, StateSuccess, m.bookmarks[1].State)
+
+	view := d.View()
+	assert.Contains(t, view, "Rebased stacks:", "the header doesn't name one destination")
+	assert.Contains(t, view, "→ release-2.3")
+	assert.Contains(t, view, "→ main")
+	assert.Contains(t, view, "1 abandoned (already in release-2.3)")
+}
+
+func TestSyncOnto(t *testing.T) {
+	jjRepo, runner := newTestRepo(
+		jjtest.Change("aaaaaaaa", "A", "oldtrunk"),
+		jjtest.Change("bbbbbbbb", "B", "oldtrunk"),
+	)
+	runner.On("log", "-r", "release-2.3@origin").ReturnChanges(jjtest.Change("newrelease", "", ""))
+	d := tuitest.New(t, NewModel(context.Background(), jjRepo, Options{Onto: "release-2.3@origin"}))
+
+	d.Init()
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.Equal(t, 1, runner.Called("rebase", "-s", "aaaaaaaa", "-s", "bbbbbbbb", "-d", "release-2.3@origin"))
+	assert.Zero(t, runner.Called("log", "-r", "(commit-oldtrunk) & ::trunk()"), "upstreams aren't looked up")
+
+	view := d.View()
+	assert.Contains(t, view, "Rebased onto release-2.3@origin")
+	assert.NotContains(t, view, "→")
+}
+
+func TestSyncFetchError(t *testing.T) {
+	d, runner := newTestModel(t)
+	runner.On("git", "fetch").Fail("Error: failed to connect to remote")
+
+	d.Init()
+	assert.Equal(t, PhaseError, phase(d))
+	assert.True(t, d.Quit())
+	assert.Contains(t, d.View(), "Sync failed")
+	assert.Zero(t, runner.Called("rebase"))
+	assert.ErrorContains(t, d.Model().(Model).Err(), "failed to connect to remote")
+}
+
+func TestSyncSavesSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	a := jjtest.Change("aaaaaaaa", "A", "oldtrunk")
+
+	// Nothing to rebase, so there is nothing to undo.
+	jjRepo, _ := newTestRepo(jjtest.Change("aaaaaaaa", "A", "newtrunk"))
+	opts := Options{Snapshot: journal.NewSnapshot(dir, "sync", "op1")}
+	tuitest.New(t, NewModel(context.Background(), jjRepo, opts)).Init()
+
+	snapshot, err := journal.LoadSnapshot(dir)
+	require.NoError(t, err)
+	assert.Nil(t, snapshot)
+
+	jjRepo, runner := newTestRepo(a)
+	onRebase(runner, stack{before: []jj.Change{a}, after: []jj.Change{conflicted(a)}})
+	opts = Options{Snapshot: journal.NewSnapshot(dir, "sync", "op2")}
+	d := tuitest.New(t, NewModel(context.Background(), jjRepo, opts)).Init()
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.Contains(t, d.View(), "jj github undo")
+
+	snapshot, err = journal.LoadSnapshot(dir)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	assert.Equal(t, "sync", snapshot.Command)
+	assert.Equal(t, "op2", snapshot.OperationID)
+}
+
+func TestSyncRevset(t *testing.T) {
+	a := jjtest.Change("aaaaaaaa", "A", "oldtrunk")
+	b := jjtest.Change("bbbbbbbb", "B", "oldtrunk")
+	jjRepo, runner := newTestRepo(a, b)
+	runner.On("log", "-r", "roots(mutable() & ::(mine()))").ReturnChanges(b)
+
+	d := tuitest.New(t, NewModel(context.Background(), jjRepo, Options{Revset: "mine()"})).Init()
+	require.Equal(t, PhaseComplete, phase(d))
+
+	assert.Zero(t, runner.Called("rebase", "-s", "aaaaaaaa"))
+	assert.Equal(t, 1, runner.Called("rebase", "-s", "bbbbbbbb"))
+
+	m := d.Model().(Model)
+	assert.Equal(t, StateLeftInPlace, m.bookmarks[0].State)
+	assert.Equal(t, StateSuccess, m.bookmarks[1].State)
+	assert.Contains(t, d.View(), "left in place")
+	assert.Contains(t, d.View(), "1 stack(s) left in place.")
+}
+
+func TestSyncSelect(t *testing.T) {
+	a := jjtest.Change("aaaaaaaa", "A", "oldtrunk")
+	b := jjtest.Change("bbbbbbbb", "B", "oldtrunk")
+	c := jjtest.Change("cccccccc", "C", "oldtrunk")
+	jjRepo, runner := newTestRepo(a, b, c)
+	onRebase(runner, unchanged(b), unchanged(c))
+
+	d := tuitest.New(t, NewModel(context.Background(), jjRepo, Options{Select: true})).Init()
+	require.Equal(t, PhaseSelecting, phase(d))
+	assert.False(t, d.Quit())
+	assert.Contains(t, d.View(), "Select stacks to rebase")
+
+	// Everything starts selected. Toggling all selects everything unless
+	// everything is already selected.
+	d.Key("down").Key(" ")
+	d.Key("a").Key("a")
+	d.Key(" ").Key("j").Key(" ").Key("j")
+	m := d.Model().(Model)
+	assert.Equal(t, 2, m.cursor, "the cursor stops at the last stack")
+	assert.Equal(t, []bool{false, true, true}, []bool{m.bookmarks[0].Selected, m.bookmarks[1].Selected, m.bookmarks[2].Selected})
+	assert.Zero(t, runner.Called("rebase"))
+
+	d.Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.True(t, d.Quit())
+	assert.Zero(t, runner.Called("rebase", "-s", "aaaaaaaa"))
+	assert.Equal(t, 1, runner.Called("rebase", "-s", "bbbbbbbb"))
+	assert.Equal(t, 1, runner.Called("rebase", "-s", "cccccccc"))
+}
+
+func TestSyncSelectNothing(t *testing.T) {
+	jjRepo, runner := newTestRepo(jjtest.Change("aaaaaaaa", "A", "oldtrunk"))
+
+	d := tuitest.New(t, NewModel(context.Background(), jjRepo, Options{Select: true})).Init()
+	d.Key(" ").Key("enter")
+	require.Equal(t, PhaseComplete, phase(d))
+	assert.Zero(t, runner.Called("rebase"))
+	assert.Contains(t, d.View(), "1 stack(s) left in place.")
+	assert.NotContains(t, d.View(), "rebased successfully")
+}
@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/tui/tuitest"
+)
+
+// widths are the terminal widths golden files are rendered at.
+var widths = []int{40, 80, 120}
+
+func TestSyncGolden(t *testing.T) {
+	for _, tc := range []struct {
+		Name string
+		Run  func(t *testing.T, width int) *tuitest.Driver
+	}{
+		{
+			Name: "rebase",
+			Run: func(t *testing.T, width int) *tuitest.Driver {
+				b := jjtest.Change("bbbbbbbb", "A much longer description that needs to be truncated on narrow terminals", "oldtrunk")
+				c := jjtest.Change("cccccccc", "Add feature C", "oldtrunk")
+				a := jjtest.Change("aaaaaaaa", "Add feature A", "oldtrunk")
+				d, runner := newTestModel(t, a, b, c)
+				onRebase(runner,
+					unchanged(a),
+					stack{before: []jj.Change{b}, after: []jj.Change{conflicted(b)}},
+					stack{before: []jj.Change{c}},
+				)
+				onConflicts(runner, "bbbbbbbb", Conflict{Change: conflicted(b), Files: []string{"internal/app.go", "go.sum"}})
+				return d.Resize(width, 40).Init()
+			},
+		},
+		{
+			Name: "rebase-error",
+			Run: func(t *testing.T, width int) *tuitest.Driver {
+				d, runner := newTestModel(t, jjtest.Change("aaaaaaaa", "Add feature A", "oldtrunk"))
+				runner.On("rebase").Fail("Error: Revision `aaaaaaaa` doesn't exist")
+				return d.Resize(width, 40).Init()
+			},
+		},
+		{
+			Name: "select",
+			Run: func(t *testing.T, width int) *tuitest.Driver {
+				jjRepo, _ := newTestRepo(
+					jjtest.Change("aaaaaaaa", "Add feature A", "oldtrunk"),
+					jjtest.Change("bbbbbbbb", "Experiment kept on the old trunk", "oldtrunk"),
+				)
+				d := tuitest.New(t, NewModel(context.Background(), jjRepo, Options{Select: true}))
+				return d.Resize(width, 40).Init().Key("down").Key(" ").Key("enter")
+			},
+		},
+		{
+			Name: "up-to-date",
+			Run: func(t *testing.T, width int) *tuitest.Driver {
+				d, _ := newTestModel(t, jjtest.Change("aaaaaaaa", "Add feature A", "newtrunk"))
+				return d.Resize(width, 40).Init()
+			},
+		},
+		{
+			Name: "fetch-error",
+			Run: func(t *testing.T, width int) *tuitest.Driver {
+				d, runner := newTestModel(t)
+				runner.On("git", "fetch").Fail("Error: failed to connect to remote")
+				return d.Resize(width, 40).Init()
+			},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			for _, width := range widths {
+				t.Run(fmt.Sprintf("%dcols", width), func(t *testing.T) {
+					tc.Run(t, width).AssertGoldenFrames()
+				})
+			}
+		})
+	}
+}
@@ -4,12 +4,75 @@ import "github.com/charmbracelet/bubbles/key"
 
 // KeyMap defines the key bindings for the sync TUI
 type KeyMap struct {
-	Quit key.Binding
+	Up        key.Binding
+	Down      key.Binding
+	Toggle    key.Binding
+	ToggleAll key.Binding
+	Confirm   key.Binding
+	Resolve   key.Binding
+	Edit      key.Binding
+	Quit      key.Binding
 }
 
 // DefaultKeyMap returns the default key bindings
 func DefaultKeyMap() KeyMap {
+	keys := SelectKeyMap()
+	keys.Up.SetEnabled(false)
+	keys.Down.SetEnabled(false)
+	keys.Toggle.SetEnabled(false)
+	keys.ToggleAll.SetEnabled(false)
+	keys.Confirm.SetEnabled(false)
+	return keys
+}
+
+// SelectKeyMap returns keys shown while choosing which stacks to rebase
+func SelectKeyMap() KeyMap {
+	keys := allKeys()
+	keys.Resolve.SetEnabled(false)
+	keys.Edit.SetEnabled(false)
+	return keys
+}
+
+// ConflictKeyMap returns keys shown while following up on conflicts after
+// the rebase
+func ConflictKeyMap() KeyMap {
+	keys := allKeys()
+	keys.Toggle.SetEnabled(false)
+	keys.ToggleAll.SetEnabled(false)
+	keys.Confirm.SetEnabled(false)
+	return keys
+}
+
+func allKeys() KeyMap {
 	return KeyMap{
+		Up: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑/k", "up"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓/j", "down"),
+		),
+		Toggle: key.NewBinding(
+			key.WithKeys(" ", "x"),
+			key.WithHelp("space", "toggle"),
+		),
+		ToggleAll: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "toggle all"),
+		),
+		Confirm: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "rebase"),
+		),
+		Resolve: key.NewBinding(
+			key.WithKeys("enter", "r"),
+			key.WithHelp("enter", "jj resolve"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "jj edit and open $EDITOR"),
+		),
 		Quit: key.NewBinding(
 			key.WithKeys("q", "ctrl+c"),
 			key.WithHelp("q", "quit"),
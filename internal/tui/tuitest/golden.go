@@ -0,0 +1,48 @@
+package tuitest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// AssertGolden compares got with testdata/<test name>.golden. Run tests with
+// -update to write the golden files instead.
+func AssertGolden(t testing.TB, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", filepath.FromSlash(t.Name())+".golden")
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(got), 0o644))
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "run with -update to create the golden file")
+	assert.Equal(t, string(want), got, "run with -update to accept the new output")
+}
+
+// AssertGoldenFrames compares every frame the driver has rendered with the
+// golden file for the test.
+func (d *Driver) AssertGoldenFrames() {
+	d.t.Helper()
+
+	var sb strings.Builder
+	for i, frame := range d.frames {
+		fmt.Fprintf(&sb, "── frame %d ──\n", i)
+		sb.WriteString(frame)
+		if !strings.HasSuffix(frame, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	AssertGolden(d.t, sb.String())
+}
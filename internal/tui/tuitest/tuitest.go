@@ -0,0 +1,151 @@
+// Package tuitest drives bubbletea models deterministically in tests.
+package tuitest
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// maxMessages guards against models that never stop producing commands.
+const maxMessages = 10_000
+
+// Driver feeds messages to a model synchronously, running every command the
+// model returns until there is nothing left to do. Commands in a batch are run
+// one after another in order, so results are deterministic. Spinner ticks are
+// dropped, since they would otherwise keep the model busy forever.
+//
+// Every distinct view the model renders along the way is recorded as a frame.
+type Driver struct {
+	t      testing.TB
+	model  tea.Model
+	quit   bool
+	frames []string
+
+	// OnMsg is called with each message before it is delivered to the model.
+	OnMsg func(msg tea.Msg)
+}
+
+// New creates a driver for model. The model's Init command is not run until Init is called.
+//
+// Styles are rendered without colors so views are the same in every terminal.
+func New(t testing.TB, model tea.Model) *Driver {
+	lipgloss.SetColorProfile(termenv.Ascii)
+	lipgloss.SetHasDarkBackground(true)
+
+	return &Driver{t: t, model: model}
+}
+
+// Init runs the model's Init command and everything it leads to.
+func (d *Driver) Init() *Driver {
+	d.record()
+	d.run(d.model.Init())
+	return d
+}
+
+// Resize sends a window size message, as the terminal does on startup.
+func (d *Driver) Resize(width, height int) *Driver {
+	return d.Send(tea.WindowSizeMsg{Width: width, Height: height})
+}
+
+// Send delivers msg to the model and runs everything it leads to.
+func (d *Driver) Send(msg tea.Msg) *Driver {
+	d.deliver(msg)
+	return d
+}
+
+// Key sends a key press, e.g. "enter", "q" or "r".
+func (d *Driver) Key(k string) *Driver {
+	var msg tea.KeyMsg
+	switch k {
+	case "enter":
+		msg = tea.KeyMsg{Type: tea.KeyEnter}
+	case "ctrl+c":
+		msg = tea.KeyMsg{Type: tea.KeyCtrlC}
+	case "up":
+		msg = tea.KeyMsg{Type: tea.KeyUp}
+	case "down":
+		msg = tea.KeyMsg{Type: tea.KeyDown}
+	case " ", "space":
+		msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	default:
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+	}
+	return d.Send(msg)
+}
+
+// Model returns the current model.
+func (d *Driver) Model() tea.Model {
+	return d.model
+}
+
+// Quit returns true once the model has returned tea.Quit.
+func (d *Driver) Quit() bool {
+	return d.quit
+}
+
+// View renders the current model.
+func (d *Driver) View() string {
+	return d.model.View()
+}
+
+// Frames returns every distinct view rendered so far, oldest first.
+func (d *Driver) Frames() []string {
+	return append([]string(nil), d.frames...)
+}
+
+// record appends the current view as a frame if it changed.
+func (d *Driver) record() {
+	view := d.model.View()
+	if len(d.frames) == 0 || d.frames[len(d.frames)-1] != view {
+		d.frames = append(d.frames, view)
+	}
+}
+
+func (d *Driver) deliver(msg tea.Msg) {
+	if d.OnMsg != nil {
+		d.OnMsg(msg)
+	}
+
+	model, cmd := d.model.Update(msg)
+	d.model = model
+	d.record()
+	d.run(cmd)
+}
+
+func (d *Driver) run(cmd tea.Cmd) {
+	d.t.Helper()
+
+	queue := []tea.Cmd{cmd}
+	for n := 0; len(queue) > 0; n++ {
+		if n > maxMessages {
+			d.t.Fatalf("tuitest: model produced more than %d messages", maxMessages)
+		}
+
+		cmd := queue[0]
+		queue = queue[1:]
+		if cmd == nil {
+			continue
+		}
+
+		switch msg := cmd().(type) {
+		case nil:
+		case tea.BatchMsg:
+			queue = append(queue, msg...)
+		case tea.QuitMsg:
+			d.quit = true
+		case spinner.TickMsg:
+		default:
+			if d.OnMsg != nil {
+				d.OnMsg(msg)
+			}
+			model, next := d.model.Update(msg)
+			d.model = model
+			d.record()
+			queue = append(queue, next)
+		}
+	}
+}
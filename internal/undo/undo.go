@@ -0,0 +1,141 @@
+// Package undo reverts the last sync or submit run, restoring the jj
+// operation it started from and optionally cleaning up what it created on
+// GitHub.
+package undo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/journal"
+)
+
+// ErrNothingToUndo is returned by Load when no run has been recorded.
+var ErrNothingToUndo = errors.New("nothing to undo: no sync or submit has been recorded")
+
+// Repo is the jj functionality used to undo a run.
+type Repo interface {
+	RestoreOperation(id string) error
+}
+
+// Remote is the GitHub functionality used to revert a submit.
+type Remote interface {
+	ClosePullRequest(ctx context.Context, repo github.Repo, number int) error
+	DeleteBranch(ctx context.Context, repo github.Repo, branch string) error
+}
+
+// Load returns the snapshot of the last run recorded in stateDir.
+func Load(stateDir string) (*journal.Snapshot, error) {
+	snapshot, err := journal.LoadSnapshot(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, ErrNothingToUndo
+	}
+	return snapshot, nil
+}
+
+// Describe explains what undoing snapshot will do, including the changes on
+// GitHub that restoring the jj operation doesn't revert.
+func Describe(snapshot *journal.Snapshot) string {
+	var sb strings.Builder
+	verb := "Undo restores"
+	if snapshot.Restored {
+		verb = "The repository was already restored"
+	}
+	fmt.Fprintf(&sb, "%s to operation %s, from before `jj github %s` at %s.\n",
+		verb, shortOperation(snapshot.OperationID), snapshot.Command, snapshot.StartedAt.Format("2006-01-02 15:04:05"))
+
+	if !snapshot.HasRemoteEffects() {
+		return sb.String()
+	}
+
+	sb.WriteString("\nThe run also changed GitHub, which restoring the operation doesn't undo:\n")
+	for _, number := range snapshot.CreatedPRs {
+		fmt.Fprintf(&sb, "  opened pull request #%d\n", number)
+	}
+	for _, branch := range snapshot.CreatedBranches {
+		fmt.Fprintf(&sb, "  created branch %s\n", branch)
+	}
+	for _, branch := range snapshot.UpdatedBranches {
+		fmt.Fprintf(&sb, "  pushed to branch %s (not reverted)\n", branch)
+	}
+	if !snapshot.Restored && (len(snapshot.CreatedBranches) > 0 || len(snapshot.UpdatedBranches) > 0) {
+		sb.WriteString("\nRestoring also moves the origin bookmarks back to before the push, so run `jj git fetch` before pushing again.\n")
+	}
+	return sb.String()
+}
+
+// CanRevertRemote returns true if RevertRemote has anything to clean up.
+func CanRevertRemote(snapshot *journal.Snapshot) bool {
+	return len(snapshot.CreatedPRs) > 0 || len(snapshot.CreatedBranches) > 0
+}
+
+// Restore restores the jj operation from before the run. The snapshot is
+// removed if there is nothing to revert on GitHub, so the same run can't be
+// undone twice. Otherwise it is kept, marked as restored, until RevertRemote
+// succeeds or Decline is called.
+func Restore(repo Repo, snapshot *journal.Snapshot) error {
+	if snapshot.Restored {
+		return nil
+	}
+	if err := repo.RestoreOperation(snapshot.OperationID); err != nil {
+		return fmt.Errorf("restore operation: %w", err)
+	}
+	if !CanRevertRemote(snapshot) {
+		return snapshot.Remove()
+	}
+	snapshot.Restored = true
+	return snapshot.Save()
+}
+
+// Decline removes the snapshot of a restored run without reverting the
+// changes on GitHub.
+func Decline(snapshot *journal.Snapshot) error {
+	return snapshot.Remove()
+}
+
+// RevertRemote closes the pull requests and deletes the branches the run
+// created. Branches that existed before the run are left alone, since their
+// previous commits aren't known. Every revert is attempted, even if some fail.
+// The snapshot is removed once everything is reverted, otherwise it keeps only
+// what failed so the revert can be retried.
+func RevertRemote(ctx context.Context, gh Remote, repo github.Repo, snapshot *journal.Snapshot) error {
+	var errs []error
+	var prs []int
+	for _, number := range snapshot.CreatedPRs {
+		if err := gh.ClosePullRequest(ctx, repo, number); err != nil {
+			errs = append(errs, fmt.Errorf("close pull request #%d: %w", number, err))
+			prs = append(prs, number)
+		}
+	}
+	var branches []string
+	for _, branch := range snapshot.CreatedBranches {
+		if err := gh.DeleteBranch(ctx, repo, branch); err != nil {
+			errs = append(errs, fmt.Errorf("delete branch %s: %w", branch, err))
+			branches = append(branches, branch)
+		}
+	}
+	if len(errs) == 0 {
+		return snapshot.Remove()
+	}
+
+	snapshot.CreatedPRs = prs
+	snapshot.CreatedBranches = branches
+	if err := snapshot.Save(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// shortOperation abbreviates an operation ID the way `jj op log` does.
+func shortOperation(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,120 @@
+package undo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+func TestUndoSync(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Load(dir)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+
+	require.NoError(t, journal.NewSnapshot(dir, "sync", "0123456789abcdef").Save())
+	snapshot, err := Load(dir)
+	require.NoError(t, err)
+
+	desc := Describe(snapshot)
+	assert.Contains(t, desc, "operation 0123456789ab, from before `jj github sync`")
+	assert.NotContains(t, desc, "GitHub")
+	assert.False(t, CanRevertRemote(snapshot))
+
+	jjRepo, runner := jjtest.NewClient()
+	runner.On("operation", "restore")
+	require.NoError(t, Restore(jjRepo, snapshot))
+	assert.Equal(t, 1, runner.Called("operation", "restore", "0123456789abcdef"))
+
+	_, err = Load(dir)
+	assert.ErrorIs(t, err, ErrNothingToUndo, "a run can only be undone once")
+}
+
+func TestUndoRestoreFails(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, journal.NewSnapshot(dir, "sync", "op1").Save())
+	snapshot, err := Load(dir)
+	require.NoError(t, err)
+
+	jjRepo, runner := jjtest.NewClient()
+	runner.On("operation", "restore").Fail("Error: No operation ID matching \"op1\"")
+	assert.ErrorContains(t, Restore(jjRepo, snapshot), "No operation ID matching")
+
+	_, err = Load(dir)
+	assert.NoError(t, err, "the snapshot is kept so the undo can be retried")
+}
+
+func TestUndoSubmit(t *testing.T) {
+	ctx := context.Background()
+	server := githubtest.NewServer(t, testRepo)
+	server.AddPullRequest(github.PullRequestOptions{Title: "A", Branch: "push-a", Base: "main"}, "sha-a")
+	server.AddPullRequest(github.PullRequestOptions{Title: "B", Branch: "push-b", Base: "push-a"}, "sha-b")
+
+	dir := t.TempDir()
+	snapshot := journal.NewSnapshot(dir, "submit", "op1")
+	snapshot.Pushed("push-a", false)
+	snapshot.Pushed("push-b", true)
+	snapshot.CreatedPR(2)
+
+	desc := Describe(snapshot)
+	assert.Contains(t, desc, "opened pull request #2")
+	assert.Contains(t, desc, "created branch push-b")
+	assert.Contains(t, desc, "pushed to branch push-a (not reverted)")
+	assert.Contains(t, desc, "run `jj git fetch` before pushing again")
+	assert.True(t, CanRevertRemote(snapshot))
+
+	jjRepo, runner := jjtest.NewClient()
+	runner.On("operation", "restore")
+	require.NoError(t, Restore(jjRepo, snapshot))
+
+	loaded, err := Load(dir)
+	require.NoError(t, err, "the snapshot is kept until GitHub is reverted")
+	assert.True(t, loaded.Restored)
+	assert.Contains(t, Describe(loaded), "already restored to operation op1")
+
+	// A second undo only offers to revert GitHub.
+	require.NoError(t, Restore(jjRepo, loaded))
+	assert.Equal(t, 1, runner.Called("operation", "restore", "op1"))
+
+	require.NoError(t, RevertRemote(ctx, server.Client(t), testRepo, snapshot))
+	_, err = Load(dir)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+
+	prs := server.PullRequests()
+	assert.Equal(t, "open", prs[0].GetState(), "PRs from before the run are kept")
+	assert.Equal(t, "closed", prs[1].GetState())
+	assert.Equal(t, []string{"push-b"}, server.DeletedBranches())
+
+	// Every revert is attempted even if one fails.
+	snapshot.CreatedPR(99)
+	err = RevertRemote(ctx, server.Client(t), testRepo, snapshot)
+	assert.ErrorContains(t, err, "close pull request #99")
+	assert.ErrorContains(t, err, "delete branch push-b")
+
+	loaded, err = Load(dir)
+	require.NoError(t, err, "failed reverts can be retried")
+	assert.Equal(t, []int{99}, loaded.CreatedPRs)
+	assert.Equal(t, []string{"push-b"}, loaded.CreatedBranches)
+}
+
+func TestUndoDecline(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := journal.NewSnapshot(dir, "submit", "op1")
+	snapshot.Pushed("push-a", true)
+
+	jjRepo, runner := jjtest.NewClient()
+	runner.On("operation", "restore")
+	require.NoError(t, Restore(jjRepo, snapshot))
+	require.NoError(t, Decline(snapshot))
+
+	_, err := Load(dir)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+}
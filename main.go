@@ -5,15 +5,30 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
 	"github.com/urfave/cli/v2"
 
+	"github.com/cbrewster/jj-github/internal/adopt"
+	"github.com/cbrewster/jj-github/internal/browse"
+	"github.com/cbrewster/jj-github/internal/checkout"
+	"github.com/cbrewster/jj-github/internal/doctor"
 	"github.com/cbrewster/jj-github/internal/github"
 	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/journal"
+	"github.com/cbrewster/jj-github/internal/land"
+	"github.com/cbrewster/jj-github/internal/reviews"
+	"github.com/cbrewster/jj-github/internal/tui/components"
+	"github.com/cbrewster/jj-github/internal/tui/dashboard"
+	"github.com/cbrewster/jj-github/internal/tui/status"
 	"github.com/cbrewster/jj-github/internal/tui/submit"
 	"github.com/cbrewster/jj-github/internal/tui/sync"
+	"github.com/cbrewster/jj-github/internal/undo"
 )
 
 func main() {
@@ -22,22 +37,210 @@ func main() {
 		Usage: "Manage stacked pull requests with Jujutsu and GitHub",
 		Commands: []*cli.Command{
 			{
-				Name:  "sync",
-				Usage: "Fetch from remote and rebase bookmarks onto updated trunk",
+				Name:      "sync",
+				Usage:     "Fetch from remote and rebase stacks onto their updated branches",
+				ArgsUsage: "[revset]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "interactive",
+						Aliases: []string{"i"},
+						Usage:   "Choose which stacks to rebase",
+					},
+					&cli.StringFlag{
+						Name:  "onto",
+						Usage: "Rebase every stack onto `REVSET` instead of the branch it is based on",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					return runSync(c.Context)
+					return runSync(c.Context, c.Args().First(), c.Bool("interactive"), c.String("onto"))
 				},
 			},
 			{
 				Name:      "submit",
 				Usage:     "Submit revisions as pull requests to GitHub",
 				ArgsUsage: "[revset]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "resume",
+						Usage: "Continue the previous submit where it stopped",
+					},
+					&cli.BoolFlag{
+						Name:  "wait",
+						Usage: "Wait for the pull requests' checks to finish, failing if any fail",
+					},
+					&cli.StringFlag{
+						Name:  "auto-merge",
+						Usage: "Enable auto-merge with `METHOD` (merge, squash or rebase) for the pull request at the bottom of the stack",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.Bool("resume") && c.Args().Present() {
+						return fmt.Errorf("a revset cannot be given with --resume")
+					}
+					autoMerge := c.String("auto-merge")
+					if autoMerge != "" && !slices.Contains(github.MergeMethods, autoMerge) {
+						return fmt.Errorf("unknown merge method %q, expected one of %s", autoMerge, strings.Join(github.MergeMethods, ", "))
+					}
+
+					revset := "@"
+					if c.Args().First() != "" {
+						revset = c.Args().First()
+					}
+					return runSubmit(c.Context, revset, c.Bool("resume"), c.Bool("wait"), autoMerge)
+				},
+			},
+			{
+				Name:      "adopt",
+				Usage:     "Manage pull requests opened outside jj-github for revisions in the stack",
+				ArgsUsage: "[revset]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Adopt every matching pull request without asking",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					revset := "@"
+					if c.Args().First() != "" {
+						revset = c.Args().First()
+					}
+					return runAdopt(c.Context, revset, c.Bool("yes"))
+				},
+			},
+			{
+				Name:      "checkout",
+				Usage:     "Fetch the stack of a pull request and track its branches locally",
+				ArgsUsage: "<number or URL>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "new",
+						Usage: "Create a new revision on top of the stack with `jj new`",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected a pull request number or URL")
+					}
+					return runCheckout(c.Context, c.Args().First(), c.Bool("new"))
+				},
+			},
+			{
+				Name:      "open",
+				Usage:     "Open the pull requests for revisions in the browser",
+				ArgsUsage: "[revset]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "stack",
+						Usage: "Open the pull requests for the whole stack",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					revset := "@"
+					if c.Args().First() != "" {
+						revset = c.Args().First()
+					}
+					return runOpen(c.Context, revset, c.Bool("stack"))
+				},
+			},
+			{
+				Name:      "status",
+				Usage:     "Show the pull requests in the stack with the status of their checks",
+				ArgsUsage: "[revset]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "watch",
+						Usage: "Keep watching until the checks finish, failing if any fail",
+					},
+					&cli.BoolFlag{
+						Name:  "dashboard",
+						Usage: "Open a dashboard of the stack that refreshes until you quit",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Usage: "How often the dashboard refreshes with --dashboard",
+						Value: 30 * time.Second,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					revset := "@"
+					if c.Args().First() != "" {
+						revset = c.Args().First()
+					}
+					if c.Bool("dashboard") {
+						if c.Bool("watch") {
+							return fmt.Errorf("--watch cannot be used with --dashboard")
+						}
+						return runDashboard(c.Context, revset, c.Duration("interval"))
+					}
+					return runStatus(c.Context, revset, c.Bool("watch"))
+				},
+			},
+			{
+				Name:      "land",
+				Usage:     "Merge the pull request at the bottom of the stack and restack the rest",
+				ArgsUsage: "[revset]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "method",
+						Usage: "Merge `METHOD`: merge, squash or rebase. Ignored for branches with a merge queue",
+						Value: "squash",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if !slices.Contains(github.MergeMethods, c.String("method")) {
+						return fmt.Errorf("unknown merge method %q, expected one of %s", c.String("method"), strings.Join(github.MergeMethods, ", "))
+					}
+
+					revset := "@"
+					if c.Args().First() != "" {
+						revset = c.Args().First()
+					}
+					return runLand(c.Context, revset, c.String("method"))
+				},
+			},
+			{
+				Name:      "reviews",
+				Usage:     "Show review status and unresolved threads for the revisions in the stack",
+				ArgsUsage: "[revset]",
 				Action: func(c *cli.Context) error {
 					revset := "@"
 					if c.Args().First() != "" {
 						revset = c.Args().First()
 					}
-					return runSubmit(c.Context, revset)
+					return runReviews(c.Context, revset)
+				},
+			},
+			{
+				Name:  "undo",
+				Usage: "Restore the repository to before the last sync or submit",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Restore the repository, close pull requests and delete branches the submit created without asking",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return runUndo(c.Context, c.Bool("yes"))
+				},
+			},
+			{
+				Name:  "doctor",
+				Usage: "Check jj, GitHub authentication and repository configuration",
+				Action: func(c *cli.Context) error {
+					return runDoctor(c.Context)
+				},
+			},
+			{
+				Name:  "auth",
+				Usage: "Inspect GitHub authentication",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "status",
+						Usage: "Show where the GitHub token comes from and which scopes it has",
+						Action: func(c *cli.Context) error {
+							return runAuthStatus(c.Context)
+						},
+					},
 				},
 			},
 		},
@@ -45,30 +248,521 @@ func main() {
 
 	if err := app.Run(os.Args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if hint := jj.Hint(err); hint != "" {
+			fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+		}
 		os.Exit(1)
 	}
 }
 
-func runSync(ctx context.Context) error {
+// syncRevsetConfig is the jj config key for the revset sync uses when none is given.
+const syncRevsetConfig = "jj-github.sync-revset"
+
+func runSync(ctx context.Context, revset string, interactive bool, onto string) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	jjRepo := jj.NewClient(jj.ExecRunner{})
+	if err := checkJJ(jjRepo); err != nil {
+		return err
+	}
+
+	if revset == "" {
+		var err error
+		revset, err = jjRepo.GetConfig(syncRevsetConfig)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", syncRevsetConfig, err)
+		}
+	}
+
+	snapshot, err := newSnapshot(jjRepo, "sync")
+	if err != nil {
+		return err
+	}
+
+	model := sync.NewModel(ctx, jjRepo, sync.Options{
+		Snapshot: snapshot,
+		Revset:   revset,
+		Select:   interactive,
+		Onto:     onto,
+	})
+	p := tea.NewProgram(model)
+	final, err := p.Run()
+	if err != nil {
+		return err
+	}
+	if m, ok := final.(sync.Model); ok {
+		return m.Err()
+	}
+	return nil
+}
+
+func runSubmit(ctx context.Context, revset string, resume, wait bool, autoMerge string) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	ws, err := openWorkspace()
+	if err != nil {
+		return err
+	}
+
+	warnMissingScopes(ctx, ws.gh)
+
+	opts := submit.Options{StateDir: ws.stateDir, Wait: wait, AutoMerge: autoMerge, Branches: ws.branches}
+	if resume {
+		opts.Resume, err = journal.Load(opts.StateDir)
+		if err != nil {
+			return err
+		}
+		if opts.Resume == nil {
+			return fmt.Errorf("no interrupted submit to resume")
+		}
+
+		// Keep recording into the interrupted run's snapshot, so undo
+		// restores the operation from before it started.
+		opts.Snapshot, err = journal.LoadSnapshot(opts.StateDir)
+		if err != nil {
+			return err
+		}
+		if opts.Snapshot != nil && opts.Snapshot.Command != "submit" {
+			opts.Snapshot = nil
+		}
+	}
+	if opts.Snapshot == nil {
+		opts.Snapshot, err = newSnapshot(ws.jjRepo, "submit")
+		if err != nil {
+			return err
+		}
+	}
+
+	model := submit.NewModel(ctx, ws.jjRepo, ws.gh, ws.repo, revset, opts)
+	p := tea.NewProgram(model)
+	final, err := p.Run()
+	if err != nil {
+		return err
+	}
+	if m, ok := final.(submit.Model); ok {
+		return m.ChecksErr()
+	}
+	return nil
+}
+
+// checkJJ fails if the installed jj is missing features jj-github needs, and
+// warns if it is newer than the supported range.
+func checkJJ(jjRepo *jj.Client) error {
+	compat, err := jjRepo.CheckVersion()
+	if err != nil {
+		return fmt.Errorf("checking jj version: %w", err)
+	}
+	if err := compat.Err(); err != nil {
+		return fmt.Errorf("unsupported jj: %w\nRun `jj github doctor` for details", err)
+	}
+	if warning := compat.Warning(); warning != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	return nil
+}
+
+// warnMissingScopes warns if the token lacks scopes submit needs, before
+// anything is pushed. If auth can't be checked, submit reports the error.
+func warnMissingScopes(ctx context.Context, gh *github.Client) {
+	check := doctor.CheckAuth(ctx, func() (doctor.Auth, error) { return gh, nil })
+	if check.Status == doctor.StatusWarn {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", check.Hint)
+	}
+}
+
+func runDoctor(ctx context.Context) error {
+	checks := doctor.Run(ctx, jj.NewClient(jj.ExecRunner{}), func() (doctor.Auth, error) {
+		return github.NewClient()
+	})
+	fmt.Print(doctor.Render(checks))
+
+	if doctor.Failed(checks) {
+		return fmt.Errorf("some checks failed")
+	}
+	return nil
+}
+
+func runAuthStatus(ctx context.Context) error {
+	check := doctor.CheckAuth(ctx, func() (doctor.Auth, error) {
+		return github.NewClient()
+	})
+	fmt.Print(doctor.Render([]doctor.Check{check}))
+
+	if check.Status == doctor.StatusFail {
+		return fmt.Errorf("not authenticated with GitHub")
+	}
+	return nil
+}
+
+func runAdopt(ctx context.Context, revset string, yes bool) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	ws, err := openWorkspace()
+	if err != nil {
+		return err
+	}
+
+	matches, err := adopt.Find(ctx, ws.gh, ws.jjRepo, ws.repo, revset, ws.branches)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		fmt.Println("No open pull requests to adopt for the revisions in the stack.")
+		return nil
+	}
+
+	fmt.Printf("Found %d pull request(s) opened outside jj-github:\n\n%s\n", len(matches), adopt.Describe(matches))
+	fmt.Println("Submit will update their titles, bodies and bases from the revisions and add the stack comment.")
+	if !yes && !confirm("Adopt them and submit the stack?") {
+		return nil
+	}
+
+	if err := adopt.Adopt(ws.jjRepo, ws.stateDir, ws.branches, matches); err != nil {
+		return err
+	}
+
+	return runSubmit(ctx, revset, false, false, "")
+}
+
+func runCheckout(ctx context.Context, ref string, newOnTop bool) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	prRepo, number, err := github.ParsePullRequestRef(ref)
+	if err != nil {
+		return err
+	}
+
+	ws, err := openWorkspace()
+	if err != nil {
+		return err
+	}
+	if prRepo != (github.Repo{}) && !strings.EqualFold(prRepo.Owner+"/"+prRepo.Name, ws.repo.Owner+"/"+ws.repo.Name) {
+		return fmt.Errorf("pull request is in %s/%s, but origin is %s/%s", prRepo.Owner, prRepo.Name, ws.repo.Owner, ws.repo.Name)
+	}
+
+	stack, err := checkout.FindStack(ctx, ws.gh, ws.repo, number)
+	if err != nil {
+		return err
+	}
+
+	changes, err := checkout.Checkout(ws.jjRepo, stack)
+	if err != nil {
+		return err
+	}
+
+	trunkName, err := ws.jjRepo.GetTrunkName()
+	if err != nil {
+		return fmt.Errorf("getting trunk name: %w", err)
+	}
+
+	view := components.NewStack(changes, trunkName)
+	prs := make(map[string]int, len(stack))
+	for _, pr := range stack {
+		prs[pr.GetHead().GetRef()] = pr.GetNumber()
+	}
+	for _, change := range changes {
+		for _, bookmark := range change.Bookmarks {
+			if number, ok := prs[bookmark.Name]; ok {
+				view.SetRevisionPR(change.ID, number)
+			}
+		}
+	}
+
+	width, _, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		width = 80
+	}
+	fmt.Printf("Checked out %d pull request(s):\n", len(stack))
+	fmt.Print(view.View(components.NewSpinner(), components.ViewOptions{
+		RepoOwner: ws.repo.Owner,
+		RepoName:  ws.repo.Name,
+		Width:     width,
+	}))
+
+	if newOnTop {
+		top := checkout.Top(stack, number)
+		if err := ws.jjRepo.New(checkout.Revset(top)); err != nil {
+			return fmt.Errorf("creating a new revision on %s: %w", top, err)
+		}
+		fmt.Printf("\nWorking copy is now on top of %s.\n", top)
+	}
+	return nil
+}
+
+// workspace is the jj repository, its GitHub repository and the GitHub client
+// most commands start from.
+type workspace struct {
+	jjRepo   *jj.Client
+	repo     github.Repo
+	stateDir string
+	branches journal.Branches // Branches of adopted PRs, keyed by change ID
+	gh       *github.Client
+}
+
+// openWorkspace checks the installed jj, finds the GitHub repository of the
+// origin remote, loads the branches of adopted pull requests and creates a
+// GitHub client.
+func openWorkspace() (*workspace, error) {
+	jjRepo := jj.NewClient(jj.ExecRunner{})
+	if err := checkJJ(jjRepo); err != nil {
+		return nil, err
+	}
+
+	remote, err := jjRepo.GetRemote("origin")
+	if err != nil {
+		return nil, fmt.Errorf("getting remote: %w", err)
+	}
+
+	repo, err := github.GetRepoFromRemote(remote)
+	if err != nil {
+		return nil, fmt.Errorf("parsing remote: %w", err)
+	}
+
+	repoPath, err := jjRepo.GetRepoPath()
+	if err != nil {
+		return nil, fmt.Errorf("getting repo path: %w", err)
+	}
+	stateDir := journal.StateDir(repoPath)
+
+	branches, err := journal.LoadBranches(stateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	gh, err := github.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("creating GitHub client: %w", err)
+	}
+
+	return &workspace{jjRepo: jjRepo, repo: repo, stateDir: stateDir, branches: branches, gh: gh}, nil
+}
+
+// newSnapshot records the current jj operation before running command, so
+// that it can be undone. The snapshot is only saved once the run changes
+// something.
+func newSnapshot(jjRepo *jj.Client, command string) (*journal.Snapshot, error) {
+	repoPath, err := jjRepo.GetRepoPath()
+	if err != nil {
+		return nil, fmt.Errorf("getting repo path: %w", err)
+	}
+
+	op, err := jjRepo.CurrentOperation()
+	if err != nil {
+		return nil, fmt.Errorf("getting current operation: %w", err)
+	}
+
+	return journal.NewSnapshot(journal.StateDir(repoPath), command, op), nil
+}
+
+func runOpen(ctx context.Context, revset string, stack bool) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	ws, err := openWorkspace()
+	if err != nil {
+		return err
+	}
+
+	targets, err := browse.Resolve(ctx, ws.gh, ws.jjRepo, ws.repo, revset, stack, ws.branches)
+	if err != nil {
+		return err
+	}
+
+	browser, canOpen := browse.Browser()
+	open := func(url string) {
+		if !canOpen {
+			fmt.Println(url)
+			return
+		}
+		if err := browse.Open(browser, url); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open the browser: %v\n", err)
+			fmt.Println(url)
+		}
+	}
+
+	for _, target := range targets {
+		if target.PR != nil {
+			open(target.PR.GetHTMLURL())
+			continue
+		}
+
+		title, _, _ := strings.Cut(target.Change.Description, "\n")
+		fmt.Printf("%s %s has no pull request.\n", target.Change.ShortID, title)
+		url := browse.CompareURL(ws.repo, target)
+		if canOpen && confirm("Open a page to create one?") {
+			open(url)
+		} else if !canOpen {
+			fmt.Printf("Push it with `jj git push -c %s` and create one at:\n%s\n", target.Change.ShortID, url)
+		}
+	}
+	return nil
+}
+
+func runStatus(ctx context.Context, revset string, watch bool) error {
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	model := sync.NewModel(ctx)
+	ws, err := openWorkspace()
+	if err != nil {
+		return err
+	}
+
+	model := status.NewModel(ctx, ws.jjRepo, ws.gh, ws.repo, revset, status.Options{Watch: watch, Branches: ws.branches})
 	p := tea.NewProgram(model)
-	_, err := p.Run()
+	final, err := p.Run()
+	if err != nil {
+		return err
+	}
+	if m, ok := final.(status.Model); ok {
+		return m.Err()
+	}
+	return nil
+}
+
+func runDashboard(ctx context.Context, revset string, interval time.Duration) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	ws, err := openWorkspace()
+	if err != nil {
+		return err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("finding jj-github executable: %w", err)
+	}
+
+	opts := dashboard.Options{
+		Interval: interval,
+		Branches: ws.branches,
+		Command:  []string{self},
+	}
+	if browser, ok := browse.Browser(); ok {
+		opts.Open = func(url string) error { return browse.Open(browser, url) }
+	}
+
+	model := dashboard.NewModel(ctx, ws.jjRepo, ws.gh, ws.repo, revset, opts)
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	_, err = p.Run()
 	return err
 }
 
-func runSubmit(ctx context.Context, revset string) error {
+func runLand(ctx context.Context, revset, method string) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	ws, err := openWorkspace()
+	if err != nil {
+		return err
+	}
+
+	targets, err := browse.Resolve(ctx, ws.gh, ws.jjRepo, ws.repo, revset, true, ws.branches)
+	if err != nil {
+		return err
+	}
+
+	bottom, err := land.Bottom(targets)
+	if err != nil {
+		return err
+	}
+	if err := land.Check(targets, bottom); err != nil {
+		return err
+	}
+	// Print queue progress only when it changes, not on every poll.
+	var progress string
+	if err := land.Land(ctx, ws.gh, ws.repo, bottom, land.Options{
+		Method: method,
+		Progress: func(entry *github.MergeQueueEntry) {
+			if text := land.Describe(entry); text != progress {
+				progress = text
+				fmt.Printf("#%d: %s\n", bottom.PR.GetNumber(), text)
+			}
+		},
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("Landed pull request #%d.\n", bottom.PR.GetNumber())
+
+	// Rebase the rest of the stack onto the updated trunk, abandoning the
+	// landed revision, and retarget its pull requests.
+	top := targets[len(targets)-1]
+	if top.Change.ID == bottom.Change.ID {
+		return nil
+	}
+	if err := runSync(ctx, top.Change.ID, false, ""); err != nil {
+		return fmt.Errorf("restacking the rest of the stack: %w", err)
+	}
+	return runSubmit(ctx, top.Change.ID, false, false, "")
+}
+
+func runReviews(ctx context.Context, revset string) error {
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	ws, err := openWorkspace()
+	if err != nil {
+		return err
+	}
+
+	targets, err := browse.Resolve(ctx, ws.gh, ws.jjRepo, ws.repo, revset, true, ws.branches)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := reviews.Load(ctx, ws.gh, ws.repo, targets)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(reviews.Render(revisions))
+	if count := reviews.Unresolved(revisions); count > 0 {
+		fmt.Printf("\n%d unresolved thread(s). Edit the revision with `jj edit <change>` or `jj new <change>`, then run submit.\n", count)
+	}
+	return nil
+}
+
+func runUndo(ctx context.Context, yes bool) error {
+	jjRepo := jj.NewClient(jj.ExecRunner{})
+
+	repoPath, err := jjRepo.GetRepoPath()
+	if err != nil {
+		return fmt.Errorf("getting repo path: %w", err)
+	}
+
+	snapshot, err := undo.Load(journal.StateDir(repoPath))
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(undo.Describe(snapshot))
+	if !snapshot.Restored {
+		if !yes && !confirm("\nRestore the repository?") {
+			return nil
+		}
+		if err := undo.Restore(jjRepo, snapshot); err != nil {
+			return err
+		}
+		fmt.Println("Restored.")
+	}
+
+	if !undo.CanRevertRemote(snapshot) {
+		return nil
+	}
+	if !yes && !confirm("Close the pull requests and delete the branches this submit created?") {
+		return undo.Decline(snapshot)
+	}
+
 	gh, err := github.NewClient()
 	if err != nil {
 		return fmt.Errorf("creating GitHub client: %w", err)
 	}
 
-	remote, err := jj.GetRemote("origin")
+	remote, err := jjRepo.GetRemote("origin")
 	if err != nil {
 		return fmt.Errorf("getting remote: %w", err)
 	}
@@ -78,8 +772,19 @@ func runSubmit(ctx context.Context, revset string) error {
 		return fmt.Errorf("parsing remote: %w", err)
 	}
 
-	model := submit.NewModel(ctx, gh, repo, revset)
-	p := tea.NewProgram(model)
-	_, err = p.Run()
-	return err
+	if err := undo.RevertRemote(ctx, gh, repo, snapshot); err != nil {
+		return err
+	}
+	fmt.Println("Reverted the changes on GitHub.")
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	var answer string
+	_, _ = fmt.Scanln(&answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }